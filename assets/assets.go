@@ -0,0 +1,43 @@
+// Package assets embeds the fonts and images used by the game, so it can be run
+// from any directory.
+package assets
+
+import (
+	"bytes"
+	"embed"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Paths of the embedded files.
+const (
+	FontMedium = "ClearSans/ClearSans-Medium.ttf"
+	Icon       = "icon.png"
+)
+
+//go:embed ClearSans/ClearSans-Medium.ttf icon.png
+var files embed.FS
+
+// Extract writes the embedded files to a directory, for libraries which can only
+// load files from the disk. Files which are already up to date are left alone.
+func Extract(dir string) error {
+	return fs.WalkDir(files, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := files.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		dest := filepath.Join(dir, filepath.FromSlash(path))
+		if existing, err := os.ReadFile(dest); err == nil && bytes.Equal(existing, b) {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(dest, b, 0o644)
+	})
+}
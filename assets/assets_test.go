@@ -0,0 +1,21 @@
+package assets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	dir := t.TempDir()
+	for range 2 {
+		if err := Extract(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{FontMedium, Icon} {
+		if info, err := os.Stat(filepath.Join(dir, path)); err != nil || info.Size() == 0 {
+			t.Errorf("Expected %s to be extracted, got %v", path, err)
+		}
+	}
+}
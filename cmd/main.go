@@ -2,8 +2,13 @@ package main
 
 import (
 	"flag"
+	"math"
 	"os"
+	"path/filepath"
 
+	"github.com/z-riley/go-2048-battle/assets"
+	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/backend/store"
 	"github.com/z-riley/go-2048-battle/config"
 	"github.com/z-riley/go-2048-battle/debug"
 	"github.com/z-riley/go-2048-battle/log"
@@ -12,7 +17,29 @@ import (
 )
 
 func main() {
-	const path = "./assets/icon.png"
+	// Parse args
+	screenStr := flag.String("screen", string(screens.Title), "starting screen")
+	dataDir := flag.String("data-dir", "", "directory to keep saves and settings in, for running isolated profiles")
+	port := flag.Uint("port", 0, "port to host versus games on, instead of the port chosen in the host lobby")
+	flag.Parse()
+
+	if *dataDir != "" {
+		store.SetDir(*dataDir)
+	}
+	if *port > math.MaxUint16 {
+		log.Fatalf("Invalid port %d", *port)
+	}
+	screens.SetHostPort(uint16(*port))
+
+	// Fonts and images can only be loaded from the disk, so unpack them
+	assetDir := store.Path("assets")
+	if err := assets.Extract(assetDir); err != nil {
+		log.Println("Failed to extract assets:", err)
+	} else {
+		common.SetAssetDir(assetDir)
+	}
+
+	path := filepath.Join(assetDir, assets.Icon)
 	icon, err := os.Open(path)
 	if err != nil {
 		log.Println("Failed to load window icon:", path)
@@ -34,10 +61,6 @@ func main() {
 		win.RegisterKeybind(gogl.KeyLCtrl, gogl.KeyPress, func() { win.Quit() })
 	}
 
-	// Parse starting screen arg
-	screenStr := flag.String("screen", string(screens.Title), "starting screen")
-	flag.Parse()
-
 	// Create screens
 	screens.Init(win)
 	screens.SetScreen(screens.ID(*screenStr), nil)
@@ -0,0 +1,80 @@
+// Package ai chooses moves for a 2048 grid without needing a window. It can be
+// used to give hints, to control bots, or to measure how rule changes affect the
+// game.
+package ai
+
+import (
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// Strategy is a method of choosing moves.
+type Strategy interface {
+	// Choose returns the best move for the board within the budget. Returns false
+	// if no move changes the board.
+	Choose(b Board, budget Budget) (grid.Direction, bool)
+}
+
+// Budget limits how much work a strategy does to choose a move. A zero field
+// doesn't limit the strategy.
+type Budget struct {
+	// Depth is the number of moves to look ahead. For Monte Carlo strategies it is
+	// the maximum number of moves in each random game.
+	Depth int
+	// Time is the maximum time spent choosing a move.
+	Time time.Duration
+}
+
+// DefaultBudget is a budget which is fast enough to choose moves in real time.
+var DefaultBudget = Budget{Depth: 3, Time: 100 * time.Millisecond}
+
+// deadline returns when the budget runs out. The zero time is returned if the
+// budget has no time limit.
+func (b Budget) deadline() time.Time {
+	if b.Time <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(b.Time)
+}
+
+// Solver chooses moves for grids using a strategy.
+type Solver struct {
+	strategy Strategy
+	budget   Budget
+}
+
+// NewSolver constructs a new solver.
+func NewSolver(strategy Strategy, budget Budget) *Solver {
+	return &Solver{strategy: strategy, budget: budget}
+}
+
+// BestMove returns the best move for the grid. Returns false if no move is
+// possible.
+func (s *Solver) BestMove(g *grid.Grid) (grid.Direction, bool) {
+	return s.strategy.Choose(FromGrid(g), s.budget)
+}
+
+// Result contains the outcome of a game played by a solver.
+type Result struct {
+	Moves       int
+	Score       int
+	HighestTile int
+}
+
+// Play plays moves on the grid until no move is possible or maxMoves moves have
+// been made. If maxMoves is zero, the game is played until it is lost. The real
+// grid is used so the result reflects the current rules of the game.
+func (s *Solver) Play(g *grid.Grid, maxMoves int) Result {
+	var res Result
+	for maxMoves == 0 || res.Moves < maxMoves {
+		dir, ok := s.BestMove(g)
+		if !ok {
+			break
+		}
+		res.Score += g.Move(dir).Points
+		res.Moves++
+	}
+	res.HighestTile = g.HighestTile()
+	return res
+}
@@ -0,0 +1,181 @@
+package ai
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+func TestSlide(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		line       []int
+		want       []int
+		wantPoints int
+	}{
+		{
+			name:       "empty",
+			line:       []int{0, 0, 0, 0},
+			want:       []int{0, 0, 0, 0},
+			wantPoints: 0,
+		},
+		{
+			name:       "slide without combining",
+			line:       []int{0, 2, 0, 4},
+			want:       []int{2, 4, 0, 0},
+			wantPoints: 0,
+		},
+		{
+			name:       "combine once per tile",
+			line:       []int{2, 2, 2, 2},
+			want:       []int{4, 4, 0, 0},
+			wantPoints: 8,
+		},
+		{
+			name:       "combined tile cannot combine again",
+			line:       []int{4, 2, 2, 0},
+			want:       []int{4, 4, 0, 0},
+			wantPoints: 4,
+		},
+		{
+			name:       "long line",
+			line:       []int{8, 8, 0, 16, 2, 2},
+			want:       []int{16, 16, 4, 0, 0, 0},
+			wantPoints: 20,
+		},
+		{
+			name:       "tiles stop at a blocker",
+			line:       []int{0, 2, blocked, 2, 2},
+			want:       []int{2, 0, blocked, 4, 0},
+			wantPoints: 4,
+		},
+		{
+			name:       "special tiles slide without combining",
+			line:       []int{0, special, special, 2},
+			want:       []int{special, special, 2, 0},
+			wantPoints: 0,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := append([]int(nil), tc.line...)
+			points := slide(got)
+			if !reflect.DeepEqual(got, tc.want) || points != tc.wantPoints {
+				t.Errorf("Expected:\n<%v %v>\nGot:\n<%v %v>", tc.want, tc.wantPoints, got, points)
+			}
+		})
+	}
+}
+
+func TestBoardMatchesGrid(t *testing.T) {
+	// Play a game on a real grid and check that the board agrees with every move,
+	// ignoring the tile which the grid spawns
+	for _, size := range [][2]int{{4, 4}, {3, 5}, {6, 4}} {
+		g := grid.NewGridWithSize(size[0], size[1], 7)
+		for i := range 200 {
+			dir := directions[i%len(directions)]
+			before := FromGrid(g)
+			want, _, wantMoved := before.Move(dir)
+
+			res := g.Move(dir)
+			got := FromGrid(g)
+			moved := res.Moved
+			if res.Spawn != nil {
+				got[res.Spawn.Y][res.Spawn.X] = 0
+			}
+
+			if moved != wantMoved || !reflect.DeepEqual(got, want) {
+				t.Fatalf("Move %d %v on %dx%d:\nExpected:\n<%v>\nGot:\n<%v>", i, dir, size[0], size[1], want, got)
+			}
+			if g.Outcome() == grid.Lose {
+				break
+			}
+		}
+	}
+}
+
+func TestChooseWithNoMoves(t *testing.T) {
+	b := Board{
+		{2, 4, 2},
+		{4, 2, 4},
+		{2, 4, 2},
+	}
+	for _, strategy := range []Strategy{NewExpectimax(), NewMonteCarlo(1)} {
+		if dir, ok := strategy.Choose(b, DefaultBudget); ok {
+			t.Errorf("%T: expected no move, got %v", strategy, dir)
+		}
+	}
+}
+
+func TestChooseOnlyMove(t *testing.T) {
+	// Only moving down changes the board
+	b := Board{
+		{0, 0, 0},
+		{0, 0, 0},
+		{2, 4, 8},
+	}
+	for _, strategy := range []Strategy{NewExpectimax(), NewMonteCarlo(1)} {
+		b := b.Clone()
+		b[0][0] = 16
+		dir, ok := strategy.Choose(b, DefaultBudget)
+		if !ok {
+			t.Fatalf("%T: expected a move", strategy)
+		}
+		if _, _, moved := b.Move(dir); !moved {
+			t.Errorf("%T: chose %v, which doesn't change the board", strategy, dir)
+		}
+	}
+}
+
+func TestMonteCarloIsReproducible(t *testing.T) {
+	b := Board{
+		{2, 0, 0, 2},
+		{0, 4, 0, 0},
+		{0, 0, 8, 0},
+		{2, 0, 0, 0},
+	}
+	budget := Budget{Depth: 10}
+	for range 5 {
+		dir1, _ := NewMonteCarlo(99).Choose(b, budget)
+		dir2, _ := NewMonteCarlo(99).Choose(b, budget)
+		if dir1 != dir2 {
+			t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", dir1, dir2)
+		}
+	}
+}
+
+func TestExpectimaxBeatsRandom(t *testing.T) {
+	const moves = 150
+	solved := NewSolver(NewExpectimax(), Budget{Depth: 2}).Play(grid.NewGridWithSeed(3), moves)
+	random := NewSolver(NewMonteCarlo(3), Budget{Depth: 1}).Play(grid.NewGridWithSeed(3), moves)
+	if solved.HighestTile < random.HighestTile {
+		t.Errorf("Expected expectimax to reach at least tile %d, got %d", random.HighestTile, solved.HighestTile)
+	}
+}
+
+func BenchmarkExpectimax(b *testing.B) {
+	solver := NewSolver(NewExpectimax(), Budget{Depth: 3})
+	for b.Loop() {
+		solver.Play(grid.NewGridWithSeed(1), 50)
+	}
+}
+
+func BenchmarkMonteCarlo(b *testing.B) {
+	solver := NewSolver(NewMonteCarlo(1), Budget{Depth: 20})
+	for b.Loop() {
+		solver.Play(grid.NewGridWithSeed(1), 50)
+	}
+}
+
+func BenchmarkBoardMove(b *testing.B) {
+	g := grid.NewGridWithSeed(1)
+	for i := range 100 {
+		g.Move(directions[i%len(directions)])
+	}
+	board := FromGrid(g)
+	for b.Loop() {
+		for _, dir := range directions {
+			board.Move(dir)
+		}
+	}
+}
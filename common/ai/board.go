@@ -0,0 +1,191 @@
+package ai
+
+import (
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// directions contains every direction a board can be moved in.
+var directions = []grid.Direction{grid.DirUp, grid.DirDown, grid.DirLeft, grid.DirRight}
+
+// Board is a lightweight copy of the tile values on a grid, indexed by row then
+// column. Empty spaces are zero. Boards are cheap to copy so strategies can
+// explore many possible futures without touching the real grid.
+type Board [][]int
+
+const (
+	// blocked is the value of a space holding a blocker tile or a wall, which
+	// can't slide or combine. The board doesn't track when blockers unlock.
+	blocked = -1
+	// special is the value of a space holding a wildcard or bomb tile. The board
+	// lets them slide but never combine, so the search doesn't rely on them.
+	special = -2
+)
+
+// cell is the position of a space on a board.
+type cell struct{ x, y int }
+
+// FromGrid copies the tile values of a grid into a new board.
+func FromGrid(g *grid.Grid) Board {
+	b := make(Board, len(g.Tiles))
+	for i := range g.Tiles {
+		b[i] = make([]int, len(g.Tiles[i]))
+		for j := range g.Tiles[i] {
+			switch g.Tiles[i][j].Kind {
+			case grid.TileBlocker, grid.TileWall:
+				b[i][j] = blocked
+			case grid.TileWildcard, grid.TileBomb:
+				b[i][j] = special
+			default:
+				b[i][j] = g.Tiles[i][j].Val
+			}
+		}
+	}
+	return b
+}
+
+// Width returns the number of columns on the board.
+func (b Board) Width() int {
+	if len(b) == 0 {
+		return 0
+	}
+	return len(b[0])
+}
+
+// Height returns the number of rows on the board.
+func (b Board) Height() int {
+	return len(b)
+}
+
+// Clone returns a deep copy of the board.
+func (b Board) Clone() Board {
+	out := make(Board, len(b))
+	for i := range b {
+		out[i] = make([]int, len(b[i]))
+		copy(out[i], b[i])
+	}
+	return out
+}
+
+// Move returns the board after moving in the given direction, without spawning a
+// new tile. Also returns the points gained from combining tiles and whether any
+// tile moved. Boards which fit in a bitboard are moved with its lookup table.
+func (b Board) Move(dir grid.Direction) (Board, int, bool) {
+	if bb, ok := grid.BitboardFromValues(b); ok {
+		next, points := bb.Move(dir)
+		return fromBitboard(next), points, next != bb
+	}
+	return b.moveLines(dir)
+}
+
+// fromBitboard unpacks a bitboard into a new board.
+func fromBitboard(bb grid.Bitboard) Board {
+	const size = 4
+	vals := make([]int, size*size)
+	b := make(Board, size)
+	for y := range b {
+		b[y] = vals[y*size : (y+1)*size]
+		for x := range b[y] {
+			b[y][x] = bb.Tile(x, y)
+		}
+	}
+	return b
+}
+
+// moveLines moves the board by sliding each line of tiles in turn. It works for
+// boards of any size and tile values.
+func (b Board) moveLines(dir grid.Direction) (Board, int, bool) {
+	out := b.Clone()
+	lines, length := b.Height(), b.Width()
+	if dir == grid.DirUp || dir == grid.DirDown {
+		lines, length = b.Width(), b.Height()
+	}
+
+	points := 0
+	moved := false
+	line := make([]int, length)
+	for l := range lines {
+		for i := range length {
+			x, y := linePos(dir, l, i, length)
+			line[i] = b[y][x]
+		}
+		points += slide(line)
+		for i := range length {
+			x, y := linePos(dir, l, i, length)
+			if out[y][x] != line[i] {
+				moved = true
+			}
+			out[y][x] = line[i]
+		}
+	}
+	return out, points, moved
+}
+
+// CanMove returns whether any move changes the board.
+func (b Board) CanMove() bool {
+	for _, dir := range directions {
+		if _, _, moved := b.Move(dir); moved {
+			return true
+		}
+	}
+	return false
+}
+
+// emptyCells returns the position of every empty space on the board.
+func (b Board) emptyCells() []cell {
+	var cells []cell
+	for y := range b {
+		for x := range b[y] {
+			if b[y][x] == 0 {
+				cells = append(cells, cell{x, y})
+			}
+		}
+	}
+	return cells
+}
+
+// linePos returns the board position of a step along a line of tiles. Step 0 is
+// at the edge which the tiles are moving towards.
+func linePos(dir grid.Direction, line, step, length int) (int, int) {
+	switch dir {
+	case grid.DirLeft:
+		return step, line
+	case grid.DirRight:
+		return length - 1 - step, line
+	case grid.DirUp:
+		return line, step
+	default:
+		return line, length - 1 - step
+	}
+}
+
+// slide moves the tiles in a line towards step 0, combining equal tiles in the
+// same way as the grid does. A tile can only be combined once per move. The line
+// is modified in place. Returns the points gained from combining tiles.
+func slide(line []int) int {
+	points := 0
+	next := 0           // the step the next tile slides to
+	canCombine := false // whether the tile before next can be combined
+	for i := range line {
+		val := line[i]
+		if val == 0 {
+			continue
+		}
+		if val == blocked {
+			// Blockers stay where they are, so later tiles stop against them
+			next = i + 1
+			canCombine = false
+			continue
+		}
+		line[i] = 0
+		if canCombine && val > 0 && line[next-1] == val {
+			line[next-1] += val
+			points += line[next-1]
+			canCombine = false
+			continue
+		}
+		line[next] = val
+		next++
+		canCombine = true
+	}
+	return points
+}
@@ -0,0 +1,223 @@
+package ai
+
+import (
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+const (
+	// spawnFourChance is the chance of a new tile being a 4 instead of a 2.
+	spawnFourChance = 0.1
+	// minProbability is the probability below which a future isn't explored any
+	// further, because it is too unlikely to affect the choice of move.
+	minProbability = 0.0001
+	// deadlineCheckInterval is the number of positions searched between checking
+	// whether the time budget has run out.
+	deadlineCheckInterval = 1024
+)
+
+// Weights sets how much each feature of a board contributes to its score.
+type Weights struct {
+	Empty        float64 // rewards empty spaces
+	Monotonicity float64 // rewards rows and columns which increase or decrease steadily
+	Smoothness   float64 // rewards neighbouring tiles with similar values
+	MaxTile      float64 // rewards a high value tile
+}
+
+// DefaultWeights are heuristic weights which play the default grid well.
+var DefaultWeights = Weights{
+	Empty:        2.7,
+	Monotonicity: 1.0,
+	Smoothness:   0.1,
+	MaxTile:      1.0,
+}
+
+// Expectimax chooses moves by searching every possible future up to a depth,
+// averaging over where new tiles can spawn, and scoring the resulting boards
+// with heuristic weights. Satisfies the Strategy interface.
+type Expectimax struct {
+	Weights Weights
+}
+
+// NewExpectimax constructs a new expectimax strategy with the default weights.
+func NewExpectimax() *Expectimax {
+	return &Expectimax{Weights: DefaultWeights}
+}
+
+// Choose satisfies the Strategy interface. The search is deepened one move at a
+// time until the depth or time budget runs out, and the result of the deepest
+// completed search is used. A search of one move is always completed.
+func (e *Expectimax) Choose(b Board, budget Budget) (grid.Direction, bool) {
+	maxDepth := budget.Depth
+	if maxDepth <= 0 && budget.Time <= 0 {
+		maxDepth = DefaultBudget.Depth
+	}
+
+	s := &search{weights: e.Weights}
+	var best grid.Direction
+	found := false
+	for depth := 1; maxDepth <= 0 || depth <= maxDepth; depth++ {
+		if depth == 2 {
+			s.deadline = budget.deadline()
+		}
+		dir, ok := s.root(b, depth)
+		if s.aborted || !ok {
+			break
+		}
+		best, found = dir, true
+	}
+	return best, found
+}
+
+// search contains the state of an expectimax search.
+type search struct {
+	weights  Weights
+	deadline time.Time // zero if the search has no time limit
+	nodes    int       // the number of positions searched
+	aborted  bool      // whether the search ran out of time
+}
+
+// root returns the best move for the board, searching depth moves ahead.
+func (s *search) root(b Board, depth int) (grid.Direction, bool) {
+	var best grid.Direction
+	bestVal := math.Inf(-1)
+	found := false
+	for _, dir := range directions {
+		next, _, moved := b.Move(dir)
+		if !moved {
+			continue
+		}
+		val := s.chance(next, depth-1, 1)
+		if s.aborted {
+			return "", false
+		}
+		if val > bestVal {
+			best, bestVal, found = dir, val, true
+		}
+	}
+	return best, found
+}
+
+// max returns the value of the best move for the board.
+func (s *search) max(b Board, depth int, prob float64) float64 {
+	best := math.Inf(-1)
+	for _, dir := range directions {
+		next, _, moved := b.Move(dir)
+		if !moved {
+			continue
+		}
+		best = max(best, s.chance(next, depth-1, prob))
+		if s.aborted {
+			return 0
+		}
+	}
+	if math.IsInf(best, -1) {
+		// No move is possible, so the game is lost
+		return s.evaluate(b) - 1000
+	}
+	return best
+}
+
+// chance returns the expected value of the board after a new tile spawns.
+func (s *search) chance(b Board, depth int, prob float64) float64 {
+	s.nodes++
+	if s.nodes%deadlineCheckInterval == 0 && !s.deadline.IsZero() && time.Now().After(s.deadline) {
+		s.aborted = true
+		return 0
+	}
+
+	cells := b.emptyCells()
+	if depth <= 0 || prob < minProbability || len(cells) == 0 {
+		return s.evaluate(b)
+	}
+
+	total := 0.0
+	cellProb := prob / float64(len(cells))
+	for _, c := range cells {
+		b[c.y][c.x] = 2
+		total += (1 - spawnFourChance) * s.max(b, depth, cellProb*(1-spawnFourChance))
+		b[c.y][c.x] = 4
+		total += spawnFourChance * s.max(b, depth, cellProb*spawnFourChance)
+		b[c.y][c.x] = 0
+		if s.aborted {
+			return 0
+		}
+	}
+	return total / float64(len(cells))
+}
+
+// evaluate returns the heuristic score of a board.
+func (s *search) evaluate(b Board) float64 {
+	empty, highest := 0, 0
+	for y := range b {
+		for x := range b[y] {
+			if b[y][x] == 0 {
+				empty++
+			}
+			highest = max(highest, b[y][x])
+		}
+	}
+
+	return s.weights.Empty*math.Log(float64(empty)+1) +
+		s.weights.Monotonicity*monotonicity(b) +
+		s.weights.Smoothness*smoothness(b) +
+		s.weights.MaxTile*float64(log2(highest))
+}
+
+// monotonicity returns a penalty for rows and columns which change between
+// increasing and decreasing. Zero is the best possible value.
+func monotonicity(b Board) float64 {
+	total := 0.0
+	for _, dir := range []grid.Direction{grid.DirLeft, grid.DirUp} {
+		lines, length := b.Height(), b.Width()
+		if dir == grid.DirUp {
+			lines, length = b.Width(), b.Height()
+		}
+		for l := range lines {
+			inc, dec := 0.0, 0.0
+			for i := 1; i < length; i++ {
+				x1, y1 := linePos(dir, l, i-1, length)
+				x2, y2 := linePos(dir, l, i, length)
+				diff := float64(log2(b[y2][x2]) - log2(b[y1][x1]))
+				if diff > 0 {
+					inc += diff
+				} else {
+					dec -= diff
+				}
+			}
+			total -= min(inc, dec)
+		}
+	}
+	return total
+}
+
+// smoothness returns a penalty for neighbouring tiles with different values.
+// Zero is the best possible value.
+func smoothness(b Board) float64 {
+	total := 0.0
+	for y := range b {
+		for x := range b[y] {
+			if b[y][x] == 0 {
+				continue
+			}
+			if x+1 < len(b[y]) && b[y][x+1] != 0 {
+				total -= math.Abs(float64(log2(b[y][x]) - log2(b[y][x+1])))
+			}
+			if y+1 < len(b) && b[y+1][x] != 0 {
+				total -= math.Abs(float64(log2(b[y][x]) - log2(b[y+1][x])))
+			}
+		}
+	}
+	return total
+}
+
+// log2 returns the base 2 logarithm of a tile value, or zero for an empty space.
+func log2(val int) int {
+	if val <= 0 {
+		return 0
+	}
+	return bits.Len(uint(val)) - 1
+}
@@ -0,0 +1,123 @@
+package ai
+
+import (
+	"math"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// DefaultRollouts is the number of random games played for each move by a Monte
+// Carlo strategy when no number is given.
+const DefaultRollouts = 100
+
+// MonteCarlo chooses moves by playing many random games after each possible move
+// and choosing the move with the highest average score. Satisfies the Strategy
+// interface.
+type MonteCarlo struct {
+	// Rollouts is the maximum number of random games played for each move.
+	Rollouts int
+
+	rng *grid.RNG
+}
+
+// NewMonteCarlo constructs a new Monte Carlo strategy. Strategies created with the
+// same seed choose the same moves when given the same budget without a time limit.
+func NewMonteCarlo(seed int64) *MonteCarlo {
+	return &MonteCarlo{
+		Rollouts: DefaultRollouts,
+		rng:      grid.NewRNG(seed),
+	}
+}
+
+// Choose satisfies the Strategy interface. Random games are played for each move
+// in turn until every move has had Rollouts games or the time budget runs out.
+func (m *MonteCarlo) Choose(b Board, budget Budget) (grid.Direction, bool) {
+	type candidate struct {
+		dir    grid.Direction
+		board  Board
+		points int
+		total  int // the sum of the scores of every random game
+		games  int
+	}
+
+	var candidates []*candidate
+	for _, dir := range directions {
+		next, points, moved := b.Move(dir)
+		if moved {
+			candidates = append(candidates, &candidate{dir: dir, board: next, points: points})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	rollouts := m.Rollouts
+	if rollouts <= 0 {
+		rollouts = DefaultRollouts
+	}
+	deadline := budget.deadline()
+	for round := 0; round < rollouts; round++ {
+		// Every move gets at least one game so each has a score
+		if round > 0 && !deadline.IsZero() && time.Now().After(deadline) {
+			break
+		}
+		for _, c := range candidates {
+			c.total += c.points + m.rollout(c.board, budget.Depth)
+			c.games++
+		}
+	}
+
+	var best *candidate
+	bestAvg := math.Inf(-1)
+	for _, c := range candidates {
+		avg := float64(c.total) / float64(c.games)
+		if avg > bestAvg {
+			best, bestAvg = c, avg
+		}
+	}
+	return best.dir, true
+}
+
+// rollout plays random moves from the board until no move is possible or depth
+// moves have been made. If depth is zero, there is no limit on the number of
+// moves. Returns the points gained during the game.
+func (m *MonteCarlo) rollout(b Board, depth int) int {
+	b = b.Clone()
+	points := 0
+	for moves := 0; depth <= 0 || moves < depth; moves++ {
+		m.spawn(b)
+
+		// Choose a random move from the moves which change the board
+		type option struct {
+			board  Board
+			points int
+		}
+		var options []option
+		for _, dir := range directions {
+			if next, p, moved := b.Move(dir); moved {
+				options = append(options, option{next, p})
+			}
+		}
+		if len(options) == 0 {
+			break
+		}
+		choice := options[m.rng.IntN(len(options))]
+		b, points = choice.board, points+choice.points
+	}
+	return points
+}
+
+// spawn places a new tile in a random empty space on the board, in the same way
+// as the grid does.
+func (m *MonteCarlo) spawn(b Board) {
+	cells := b.emptyCells()
+	if len(cells) == 0 {
+		return
+	}
+	c := cells[m.rng.IntN(len(cells))]
+	b[c.y][c.x] = 2
+	if m.rng.Float64() < spawnFourChance {
+		b[c.y][c.x] = 4
+	}
+}
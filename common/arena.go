@@ -12,95 +12,100 @@ import (
 	"github.com/z-riley/go-2048-battle/common/backend/grid"
 	"github.com/z-riley/go-2048-battle/log"
 	"github.com/z-riley/gogl"
-	"golang.org/x/exp/constraints"
 )
 
 // Adjustable settings.
 const (
-	TileSizePx        float64 = 72   // the width and height of a tile, in pixels
+	TileSizePx        float64 = 72   // the width and height of a tile on a default sized grid, in pixels
 	TileCornerRadius  float64 = 3    // the radius, in pixels, of the rounded corners of the tiles
 	TileBoundryFactor float64 = 0.15 // the gap between tiles as a proportion of the tile size
 )
 
 // Derived constants.
 const (
-	ArenaSizePx   = tileSpacingPx*4 + TileSizePx*TileBoundryFactor // the width and height of arena, in pixels
-	tileSpacingPx = TileSizePx * (1 + TileBoundryFactor)
-	tileFont      = FontPathBold
-	numTiles      = grid.GridSize
+	ArenaSizePx = TileSizePx*(1+TileBoundryFactor)*grid.DefaultWidth +
+		TileSizePx*TileBoundryFactor // the width and height of the space the arena fits in, in pixels
 )
 
 // tile is a visual representation of a game tile.
 type tile struct {
 	tb      *gogl.TextBox
-	pos     coord // index of tile on the grid
-	destroy bool  // flag for self-destruction
+	pos     coord     // index of tile on the grid
+	uuid    uuid.UUID // identity of the game tile being shown
+	destroy bool      // flag for self-destruction
 }
 
-// newTile constructs a new tile with the correct style.
-func newTile(sizePx float64, pos gogl.Vec, val int, posIdx coord) *tile {
-	return &tile{
+// newTile constructs a new tile with the correct style. The font is scaled
+// relative to a tile of size fullSizePx.
+func newTile(sizePx, fullSizePx float64, pos gogl.Vec, val int, kind grid.TileKind, posIdx coord, id uuid.UUID) *tile {
+	t := &tile{
 		tb: gogl.NewTextBox(gogl.NewCurvedRect(
 			sizePx, sizePx, TileCornerRadius, pos,
-		).SetStyle(gogl.Style{Colour: tileColour(val)}), strconv.Itoa(val), tileFont).
-			SetTextSize(tileFontSize(val)).
-			SetTextColour(tileTextColour(val)),
-		pos: posIdx,
+		), "", FontPathBold).
+			SetTextSize(tileFontSize(val) * fullSizePx / TileSizePx),
+		pos:  posIdx,
+		uuid: id,
 	}
+	t.setKind(kind, val)
+	return t
+}
+
+// setKind styles the tile as a tile of the given kind and value.
+func (t *tile) setKind(kind grid.TileKind, val int) {
+	colour, text, textColour := tileColour(val), strconv.Itoa(val), tileTextColour(val)
+	switch kind {
+	case grid.TileBlocker:
+		colour, textColour = BlockerTileColour, LightGreyTextColour
+	case grid.TileWall:
+		colour, text = WallTileColour, ""
+	case grid.TileWildcard:
+		colour, text, textColour = WildcardTileColour, "?", WhiteFontColour
+	case grid.TileBomb:
+		colour, text, textColour = BombTileColour, "*", WhiteFontColour
+	case grid.TileDoubling:
+		colour, textColour = DoublingTileColour, WhiteFontColour
+	}
+	t.tb.Shape.(*gogl.CurvedRect).SetStyle(gogl.Style{Colour: colour})
+	t.tb.SetText(text).SetTextColour(textColour)
 }
 
 // animationData contains animations and the current game state.
 type animationState struct {
 	animations []animation
 	gameState  backend.Game
+	reverse    bool // whether the animations play a move backwards
 }
 
-// Arena displays the grid of a game.
+// Arena displays the grid of a game. The grid is scaled to fit within a square
+// of ArenaSizePx, regardless of the number of tiles.
 type Arena struct {
-	pos         gogl.Vec                             // pixel position of the arena anchor
-	tiles       []*tile                              // every non-zero tile
-	bgTiles     [numTiles][numTiles]*gogl.CurvedRect // every grid space
-	background  *gogl.CurvedRect                     // the background of the arena
-	latestState backend.Game                         // used to detect changes in game state (for animations etc...)
-	animationCh chan animationState                  // for sending animations to animator goroutine
+	boxPos        gogl.Vec             // pixel position of the top-left of the space the arena fits in
+	pos           gogl.Vec             // pixel position of the arena anchor
+	width, height int                  // number of columns and rows in the grid
+	tileSizePx    float64              // the width and height of a tile, in pixels
+	tileSpacingPx float64              // the distance between the origins of adjacent tiles, in pixels
+	tiles         []*tile              // every non-zero tile
+	bgTiles       [][]*gogl.CurvedRect // every grid space
+	background    *gogl.CurvedRect     // the background of the arena
+	latestState   backend.Game         // used to detect changes in game state (for animations etc...)
+	animationCh   chan animationState  // for sending animations to animator goroutine
 }
 
 // NewArena constructs a new arena widget. pos is the top-left pixel of the
-// top-left tile (excluding the arena background).
+// top-left tile of a default sized grid (excluding the arena background).
 func NewArena(pos gogl.Vec) *Arena {
-	// Generate background tiles
-	bgTiles := [numTiles][numTiles]*gogl.CurvedRect{}
-	for i := range numTiles {
-		for j := range numTiles {
-			bgTiles[j][i] = gogl.NewCurvedRect(
-				TileSizePx, TileSizePx, TileCornerRadius,
-				gogl.Vec{
-					X: pos.X + float64(j)*tileSpacingPx,
-					Y: pos.Y + float64(i)*tileSpacingPx,
-				},
-			)
-			bgTiles[j][i].SetStyle(gogl.Style{Colour: TileBackgroundColour})
-		}
-	}
-
-	arenaBG := gogl.NewCurvedRect(
-		ArenaSizePx, ArenaSizePx,
-		TileCornerRadius,
-		gogl.Vec{
+	a := Arena{
+		boxPos: gogl.Vec{
 			X: pos.X - TileSizePx*TileBoundryFactor,
 			Y: pos.Y - TileSizePx*TileBoundryFactor,
 		},
-	)
-	arenaBG.SetStyle(gogl.Style{Colour: ArenaBackgroundColour})
-
-	a := Arena{
-		pos:         pos,
-		tiles:       make([]*tile, 0, numTiles*numTiles),
-		bgTiles:     bgTiles,
-		background:  arenaBG,
-		latestState: backend.Game{Grid: &grid.Grid{Tiles: [4][4]grid.Tile{}}},
+		tiles: make([]*tile, 0, grid.DefaultWidth*grid.DefaultHeight),
+		latestState: backend.Game{Grid: &grid.Grid{
+			Tiles: grid.NewTiles(grid.DefaultWidth, grid.DefaultHeight),
+		}},
 		animationCh: make(chan animationState, 50),
 	}
+	a.resize(grid.DefaultWidth, grid.DefaultHeight)
 
 	// Begin listening to animation channel
 	go a.handleAnimations()
@@ -108,6 +113,55 @@ func NewArena(pos gogl.Vec) *Arena {
 	return &a
 }
 
+// resize lays out the arena for a grid with the given number of columns and rows.
+// The tiles are scaled so the largest dimension fills the arena space, and the
+// grid is centred within it. Any existing tiles are removed.
+func (a *Arena) resize(width, height int) {
+	a.width, a.height = width, height
+
+	// Scale tiles so the largest dimension fits into the arena space
+	a.tileSizePx = ArenaSizePx / (float64(max(width, height))*(1+TileBoundryFactor) + TileBoundryFactor)
+	a.tileSpacingPx = a.tileSizePx * (1 + TileBoundryFactor)
+	boundaryPx := a.tileSizePx * TileBoundryFactor
+
+	// Centre the grid within the arena space
+	bgWidth := a.tileSpacingPx*float64(width) + boundaryPx
+	bgHeight := a.tileSpacingPx*float64(height) + boundaryPx
+	bgPos := gogl.Vec{
+		X: a.boxPos.X + (ArenaSizePx-bgWidth)/2,
+		Y: a.boxPos.Y + (ArenaSizePx-bgHeight)/2,
+	}
+	a.pos = gogl.Vec{X: bgPos.X + boundaryPx, Y: bgPos.Y + boundaryPx}
+
+	a.background = gogl.NewCurvedRect(bgWidth, bgHeight, TileCornerRadius, bgPos)
+	a.background.SetStyle(gogl.Style{Colour: ArenaBackgroundColour})
+
+	// Generate background tiles
+	a.bgTiles = make([][]*gogl.CurvedRect, height)
+	for i := range height {
+		a.bgTiles[i] = make([]*gogl.CurvedRect, width)
+		for j := range width {
+			a.bgTiles[i][j] = gogl.NewCurvedRect(
+				a.tileSizePx, a.tileSizePx, TileCornerRadius,
+				a.tilePos(coord{j, i}),
+			)
+			a.bgTiles[i][j].SetStyle(gogl.Style{Colour: TileBackgroundColour})
+		}
+	}
+
+	a.tiles = make([]*tile, 0, width*height)
+}
+
+// fitGrid resizes the arena if the grid of the given game is a different size.
+// Returns true if the arena was resized.
+func (a *Arena) fitGrid(g backend.Game) bool {
+	if g.Grid.Width() == a.width && g.Grid.Height() == a.height {
+		return false
+	}
+	a.resize(g.Grid.Width(), g.Grid.Height())
+	return true
+}
+
 // Destroy tears down the arena.
 func (a *Arena) Destroy() {}
 
@@ -115,9 +169,9 @@ func (a *Arena) Destroy() {}
 func (a *Arena) Draw(buf *gogl.FrameBuffer) {
 	a.background.Draw(buf)
 
-	for i := range numTiles {
-		for j := range numTiles {
-			a.bgTiles[j][i].Draw(buf)
+	for i := range a.bgTiles {
+		for j := range a.bgTiles[i] {
+			a.bgTiles[i][j].Draw(buf)
 		}
 	}
 
@@ -126,47 +180,51 @@ func (a *Arena) Draw(buf *gogl.FrameBuffer) {
 	}
 }
 
-// Pos returns the top left pixel coordinate of the whole arena.
+// Pos returns the top left pixel coordinate of the space the arena fits in.
 func (a *Arena) Pos() gogl.Vec {
-	return a.background.GetPos()
+	return a.boxPos
 }
 
-// Width returns the total width of the arena.
+// Width returns the total width of the space the arena fits in.
 func (a *Arena) Width() float64 {
-	return a.background.Width()
+	return ArenaSizePx
 }
 
-// Height returns the total width of the arena.
+// Height returns the total height of the space the arena fits in.
 func (a *Arena) Height() float64 {
-	return a.background.Height()
+	return ArenaSizePx
 }
 
 // Load updates the arena to match the backend game data.
 func (a *Arena) Load(g backend.Game) {
+	a.fitGrid(g)
+
 	var newTiles []*tile
-	for i := range numTiles {
-		for j := range numTiles {
-			val := g.Grid.Tiles[i][j].Val
-			if val != 0 {
-				newTiles = append(newTiles,
-					newTile(
-						TileSizePx,
-						gogl.Vec{
-							X: a.pos.X + float64(j)*tileSpacingPx,
-							Y: a.pos.Y + float64(i)*tileSpacingPx,
-						},
-						val,
-						coord{j, i},
-					))
+	for i := range g.Grid.Tiles {
+		for j := range g.Grid.Tiles[i] {
+			tile := g.Grid.Tiles[i][j]
+			if !tile.Empty() {
+				newTiles = append(newTiles, newTile(
+					a.tileSizePx,
+					a.tileSizePx,
+					a.tilePos(coord{j, i}),
+					tile.Val,
+					tile.Kind,
+					coord{j, i},
+					tile.UUID,
+				))
 			}
 		}
 	}
 	a.tiles = newTiles
+	a.setLatestState(g)
 }
 
 // Reset clears the current game data from the arena.
 func (a *Arena) Reset() {
-	a.tiles = make([]*tile, 0, numTiles*numTiles)
+	a.tiles = make([]*tile, 0, a.width*a.height)
+	a.latestState.Grid.Tiles = grid.NewTiles(a.width, a.height)
+	a.latestState.Transitions = nil
 	a.SetNormal()
 }
 
@@ -193,42 +251,83 @@ func (a *Arena) SetWin() {
 
 // Update animates the arena to match the given game state.
 func (a *Arena) Update(game backend.Game) {
-	defer func() {
-		// Update the local state upon exit
-		a.latestState.Grid.Tiles = game.Grid.Tiles
-	}()
+	defer a.setLatestState(game)
 
 	// Return early if the grid hasn't changed
 	if grid.EqualGrid(a.latestState.Grid.Tiles, game.Grid.Tiles) {
 		return
 	}
 
-	// Calculate the movement of each tile
-	tileAnimations := generateAnimations(a.latestState.Grid.Tiles, game.Grid.Tiles, game.Grid.LastMove)
-	if len(tileAnimations) == 0 {
+	// A grid of a different size can't be animated from the previous one
+	if a.fitGrid(game) {
+		a.Load(game)
+		return
+	}
+
+	// The move can only be animated if it was made from the grid being shown. If
+	// not (e.g. moves were missed), jump straight to the new grid
+	if !transitionsFit(game.Transitions, a.latestState.Grid.Tiles, game.Grid.Tiles) {
+		a.Load(game)
 		return
 	}
 
 	// Send the animations for the turn down the animation channel
-	a.animationCh <- animationState{tileAnimations, game}
+	a.animationCh <- animationState{animations: moveAnimations(game.Transitions), gameState: game}
+}
+
+// Rewind animates the arena backwards to the given game state, which must be the
+// state before the latest move shown by the arena (e.g. after the move is undone).
+func (a *Arena) Rewind(game backend.Game) {
+	undone := a.latestState.Transitions
+	defer a.setLatestState(game)
+
+	// Return early if the grid hasn't changed
+	if grid.EqualGrid(a.latestState.Grid.Tiles, game.Grid.Tiles) {
+		return
+	}
+
+	// A grid of a different size can't be animated from the previous one
+	if a.fitGrid(game) {
+		a.Load(game)
+		return
+	}
+
+	if !transitionsFit(undone, game.Grid.Tiles, a.latestState.Grid.Tiles) {
+		a.Load(game)
+		return
+	}
+
+	a.animationCh <- animationState{animations: rewindAnimations(undone), gameState: game, reverse: true}
+}
+
+// setLatestState updates the local state of the arena.
+func (a *Arena) setLatestState(game backend.Game) {
+	a.latestState.Grid.Tiles = game.Grid.Tiles
+	a.latestState.Transitions = game.Transitions
 }
 
 // handleAnimations executes animations from the animation channel.
 func (a *Arena) handleAnimations() {
 	for animationState := range a.animationCh {
 		// Listen to errors being produced by animations
-		errCh := make(chan error, numTiles*numTiles)
+		errCh := make(chan error, len(animationState.animations)+1)
 
-		// Animate stage 1: tiles moving and combining
+		// Animate stage 1: tiles moving and combining, or tiles disappearing if the
+		// move is being played backwards
 		var wg sync.WaitGroup
 		for _, animation := range animationState.animations {
 			switch animation := animation.(type) {
 			case moveAnimation:
-				wg.Add(1)
-				go a.animateMove(animation, errCh, &wg)
+				if !animationState.reverse {
+					wg.Add(1)
+					go a.animateMove(animation, errCh, &wg)
+				}
 			case moveToCombineAnimation:
 				wg.Add(1)
 				go a.animateMoveToCombine(animation, errCh, &wg)
+			case despawnAnimation:
+				wg.Add(1)
+				go a.animateDespawn(animation, errCh, &wg)
 			}
 		}
 		wg.Wait()
@@ -244,15 +343,55 @@ func (a *Arena) handleAnimations() {
 		// Remove tiles that have been marked for destruction
 		a.trimTiles()
 
-		// Animate stage 2: spawn new tiles
+		// Animate stage 1b: tiles cleared by bombs disappearing, or reappearing if
+		// the move is being played backwards
+		for _, animation := range animationState.animations {
+			if animation, ok := animation.(clearAnimation); ok {
+				wg.Add(1)
+				if animationState.reverse {
+					go a.animateSpawn(spawnAnimation{
+						uuid:   animation.uuid,
+						dest:   animation.origin,
+						newVal: animation.val,
+						kind:   animation.kind,
+					}, errCh, &wg)
+				} else {
+					go a.animateDespawn(despawnAnimation{uuid: animation.uuid, origin: animation.origin}, errCh, &wg)
+				}
+			}
+		}
+		wg.Wait()
+
+		// Handle errors from stage 1b
+		select {
+		case err := <-errCh:
+			log.Printf("Error \"%v\". Resetting to latest game state\n", err)
+			a.Load(animationState.gameState)
+		default:
+		}
+		a.trimTiles()
+
+		// Animate stage 2: spawn new tiles, or tiles moving back to where they came
+		// from if the move is being played backwards
 		for _, animation := range animationState.animations {
 			switch animation := animation.(type) {
+			case moveAnimation:
+				if animationState.reverse {
+					wg.Add(1)
+					go a.animateMove(animation, errCh, &wg)
+				}
+			case splitAnimation:
+				wg.Add(1)
+				go a.animateSplit(animation, errCh, &wg)
 			case spawnAnimation:
 				wg.Add(1)
 				go a.animateSpawn(animation, errCh, &wg)
 			case newFromCombineAnimation:
 				wg.Add(1)
 				go a.animateNewFromCombine(animation, errCh, &wg)
+			case lockAnimation:
+				wg.Add(1)
+				go a.animateLock(animation, errCh, &wg)
 			}
 		}
 		wg.Wait()
@@ -265,14 +404,6 @@ func (a *Arena) handleAnimations() {
 		default:
 		}
 		close(errCh)
-
-		// Check number of tiles
-		uiTiles := len(a.tiles)
-		backendTiles := animationState.gameState.Grid.NumTiles()
-		if uiTiles != backendTiles {
-			log.Println("Found tile count mismatch. Reloading grid")
-			a.Load(animationState.gameState)
-		}
 	}
 }
 
@@ -283,7 +414,7 @@ func (a *Arena) animateMove(animation moveAnimation, errCh chan error, wg *sync.
 	origin, dest := animation.origin, animation.dest
 
 	// Move origin tile to destination
-	tile, err := a.tileAtIdx(origin)
+	tile, err := a.tileWithUUID(animation.uuid)
 	if err != nil {
 		errCh <- fmt.Errorf("animateMove could not find origin tile at %v", origin)
 		return
@@ -308,7 +439,7 @@ func (a *Arena) animateMoveToCombine(animation moveToCombineAnimation, errCh cha
 	origin, dest := animation.origin, animation.dest
 
 	// Move origin tile to destination
-	originTile, err := a.tileAtIdx(origin)
+	originTile, err := a.tileWithUUID(animation.uuid)
 	if err != nil {
 		errCh <- fmt.Errorf("animateMoveToCombine could not find origin tile at %v", origin)
 		return
@@ -322,12 +453,8 @@ func (a *Arena) animateMoveToCombine(animation moveToCombineAnimation, errCh cha
 		time.Sleep(5 * time.Millisecond)
 	}
 
-	// Mark tiles for destruction. The combined tile will be newly spawned separately
-	destTile, err := a.tileAtIdx(dest)
-	if err == nil {
-		// Mark tile if it exists
-		destTile.destroy = true
-	}
+	// Mark tile for destruction. The tile it combined with is destroyed by its own
+	// animation, and the combined tile will be newly spawned separately
 	originTile.destroy = true
 }
 
@@ -345,24 +472,25 @@ func (a *Arena) animateSpawn(animation spawnAnimation, errCh chan error, wg *syn
 	}
 
 	// Make a small new tile
-	const originalSize = TileSizePx / 6
+	originalSize := a.tileSizePx / 6
 	newTile := newTile(
 		originalSize,
-		gogl.Vec{
-			X: a.pos.X + float64(dest.x)*tileSpacingPx + (TileSizePx-originalSize)/2,
-			Y: a.pos.Y + float64(dest.y)*tileSpacingPx + (TileSizePx-originalSize)/2,
-		},
+		a.tileSizePx,
+		gogl.Add(a.tilePos(dest), gogl.Vec{
+			X: (a.tileSizePx - originalSize) / 2,
+			Y: (a.tileSizePx - originalSize) / 2,
+		}),
 		newVal,
+		animation.kind,
 		dest,
+		animation.uuid,
 	)
 	a.tiles = append(a.tiles, newTile)
 
 	// Animate tile growing to normal size
-	const (
-		growPx   = (TileSizePx - originalSize) / 2
-		steps    = 10
-		stepSize = growPx / steps
-	)
+	const steps = 10
+	growPx := (a.tileSizePx - originalSize) / 2
+	stepSize := growPx / steps
 	shape := newTile.tb.Shape.(*gogl.CurvedRect)
 	originalPos := shape.GetPos() // position of shape before animation starts
 	for i := float64(0); i <= growPx; i += stepSize {
@@ -387,13 +515,13 @@ func (a *Arena) animateNewFromCombine(animation newFromCombineAnimation, errCh c
 
 	// Make a new tile
 	newTile := newTile(
-		TileSizePx,
-		gogl.Vec{
-			X: a.pos.X + float64(dest.x)*tileSpacingPx,
-			Y: a.pos.Y + float64(dest.y)*tileSpacingPx,
-		},
+		a.tileSizePx,
+		a.tileSizePx,
+		a.tilePos(dest),
 		newVal,
+		grid.TileNormal,
 		dest,
+		animation.uuid,
 	)
 	a.tiles = append(a.tiles, newTile)
 
@@ -403,19 +531,94 @@ func (a *Arena) animateNewFromCombine(animation newFromCombineAnimation, errCh c
 	originalPos := shape.GetPos() // position of shape before animation starts
 	for i := float64(1); i <= expandPx; i++ {
 		shape.SetPos(gogl.Sub(originalPos, gogl.Vec{X: i, Y: i}))
-		shape.SetHeight(TileSizePx + i*2)
-		shape.SetWidth(TileSizePx + i*2)
+		shape.SetHeight(a.tileSizePx + i*2)
+		shape.SetWidth(a.tileSizePx + i*2)
 		time.Sleep(10 * time.Millisecond)
 	}
 	time.Sleep(30 * time.Millisecond)
 	for i := float64(expandPx) - 1; i > 0; i-- {
 		shape.SetPos(gogl.Sub(originalPos, gogl.Vec{X: i, Y: i}))
-		shape.SetHeight(TileSizePx + i*2)
-		shape.SetWidth(TileSizePx + i*2)
+		shape.SetHeight(a.tileSizePx + i*2)
+		shape.SetWidth(a.tileSizePx + i*2)
 		time.Sleep(10 * time.Millisecond)
 	}
 }
 
+// animateLock animates a tile being locked or unlocked in place.
+func (a *Arena) animateLock(animation lockAnimation, errCh chan error, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	t, err := a.tileWithUUID(animation.uuid)
+	if err != nil {
+		errCh <- fmt.Errorf("animateLock could not find tile at %v", animation.origin)
+		return
+	}
+
+	// Flash the tile before it changes
+	shape := t.tb.Shape.(*gogl.CurvedRect)
+	shape.SetStyle(gogl.Style{Colour: WhiteFontColour})
+	time.Sleep(60 * time.Millisecond)
+	kind := grid.TileNormal
+	if animation.locked {
+		kind = grid.TileBlocker
+	}
+	t.setKind(kind, animation.val)
+}
+
+// animateDespawn animates a tile shrinking until it disappears.
+func (a *Arena) animateDespawn(animation despawnAnimation, errCh chan error, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	t, err := a.tileWithUUID(animation.uuid)
+	if err != nil {
+		errCh <- fmt.Errorf("animateDespawn could not find tile at %v", animation.origin)
+		return
+	}
+
+	// Animate tile shrinking from its normal size
+	const steps = 10
+	shape := t.tb.Shape.(*gogl.CurvedRect)
+	originalPos := shape.GetPos() // position of shape before animation starts
+	stepSize := a.tileSizePx / 2 / steps
+	for i := float64(0); i < a.tileSizePx/2; i += stepSize {
+		shape.SetPos(gogl.Add(originalPos, gogl.Vec{X: i, Y: i}))
+		shape.SetHeight(a.tileSizePx - i*2)
+		shape.SetWidth(a.tileSizePx - i*2)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.destroy = true
+}
+
+// animateSplit animates a tile coming out of a combined tile and moving back to
+// where it came from.
+func (a *Arena) animateSplit(animation splitAnimation, errCh chan error, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	origin, dest := animation.origin, animation.dest
+
+	newTile := newTile(
+		a.tileSizePx,
+		a.tileSizePx,
+		a.tilePos(origin),
+		animation.newVal,
+		animation.kind,
+		origin,
+		animation.uuid,
+	)
+	a.tiles = append(a.tiles, newTile)
+
+	moveVec := gogl.Sub(a.tilePos(dest), a.tilePos(origin))
+	const steps = 10
+	moveStep := moveVec.SetMag(moveVec.Mag() / steps)
+	for range steps {
+		newTile.tb.Move(moveStep)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	newTile.pos = dest
+}
+
 // tileAtIdx returns a reference to the first found tile at a given position on the grid.
 // If the tile doesn't exist, an error is returned.
 func (a *Arena) tileAtIdx(pos coord) (*tile, error) {
@@ -427,11 +630,22 @@ func (a *Arena) tileAtIdx(pos coord) (*tile, error) {
 	return nil, fmt.Errorf("could not find tile at pos: %v", pos)
 }
 
+// tileWithUUID returns a reference to the tile showing the game tile with the given
+// UUID. If the tile doesn't exist, an error is returned.
+func (a *Arena) tileWithUUID(id uuid.UUID) (*tile, error) {
+	for i := range a.tiles {
+		if a.tiles[i].uuid == id && !a.tiles[i].destroy {
+			return a.tiles[i], nil
+		}
+	}
+	return nil, fmt.Errorf("could not find tile with UUID: %v", id)
+}
+
 // tilePos generates the pixel position of a tile on the grid based on its x and y index.
 func (a *Arena) tilePos(pos coord) gogl.Vec {
 	return gogl.Vec{
-		X: a.pos.X + float64(pos.x)*tileSpacingPx,
-		Y: a.pos.Y + float64(pos.y)*tileSpacingPx,
+		X: a.pos.X + float64(pos.x)*a.tileSpacingPx,
+		Y: a.pos.Y + float64(pos.y)*a.tileSpacingPx,
 	}
 }
 
@@ -486,8 +700,9 @@ type animation interface {
 // moveAnimation represents the movement of a tile from one position to another, without
 // combining. Satisfies the animation interface.
 type moveAnimation struct {
-	origin coord // tile index
-	dest   coord // tile index
+	uuid   uuid.UUID // the moving tile
+	origin coord     // tile index
+	dest   coord     // tile index
 }
 
 // Origin satisfies the animation interface.
@@ -512,8 +727,10 @@ func (a moveAnimation) String() string {
 
 // spawnAnimation represents a new tile spawning. Satisfies the animation interface.
 type spawnAnimation struct {
-	dest   coord // tile index
-	newVal int   // value of a newly spawned tile. 0 if N/A
+	uuid   uuid.UUID     // the new tile
+	dest   coord         // tile index
+	newVal int           // value of a newly spawned tile. 0 if N/A
+	kind   grid.TileKind // the kind of the new tile
 }
 
 // Origin satisfies the animation interface.
@@ -539,8 +756,9 @@ func (a spawnAnimation) String() string {
 // moveToCombineAnimation represents the movement of a tile into another, to
 // combine it. Satisfies the animation interface.
 type moveToCombineAnimation struct {
-	origin coord // tile index
-	dest   coord // tile index
+	uuid   uuid.UUID // the moving tile
+	origin coord     // tile index
+	dest   coord     // tile index
 }
 
 // Origin satisfies the animation interface.
@@ -566,8 +784,9 @@ func (a moveToCombineAnimation) String() string {
 // newFromCombineAnimation represents a new tile being created from a combination.
 // Satisfies the animation interface.
 type newFromCombineAnimation struct {
-	dest   coord // tile index
-	newVal int   // the value of the newly made tile
+	uuid   uuid.UUID // the new tile
+	dest   coord     // tile index
+	newVal int       // the value of the newly made tile
 }
 
 // Origin satisfies the animation interface.
@@ -590,350 +809,244 @@ func (a newFromCombineAnimation) String() string {
 	return fmt.Sprint("new-from-combine at ", a.dest)
 }
 
-// generateAnimations generates animation data for transitioning between grid states.
-func generateAnimations(before, after [numTiles][numTiles]grid.Tile, dir grid.Direction) []animation {
-	var animations []animation
-
-	if dir == grid.DirLeft || dir == grid.DirRight {
-		// Horizontal move; evaluate row-by-row
-		for i := range before {
-			rowAnimations := generateRowAnimations(before[i], after[i], dir)
-
-			for _, rowAnimation := range rowAnimations {
-				switch a := rowAnimation.(type) {
-				case moveRowAnimation:
-					animations = append(animations, moveAnimation{
-						origin: coord{must(a.Origin()), i},
-						dest:   coord{a.Dest(), i},
-					})
-				case spawnRowAnimation:
-					animations = append(animations, spawnAnimation{
-						dest:   coord{a.Dest(), i},
-						newVal: must(a.NewVal()),
-					})
-				case moveToCombineRowAnimation:
-					animations = append(animations, moveToCombineAnimation{
-						origin: coord{must(a.Origin()), i},
-						dest:   coord{a.Dest(), i},
-					})
-				case newFromCombineRowAnimation:
-					animations = append(animations, newFromCombineAnimation{
-						dest:   coord{a.Dest(), i},
-						newVal: must(a.NewVal()),
-					})
-				}
-			}
-		}
-	} else {
-		// Vertical move; evaluate column-by-column
-		for i := range before {
-			rowAnimations := generateRowAnimations(
-				[numTiles]grid.Tile{before[0][i], before[1][i], before[2][i], before[3][i]},
-				[numTiles]grid.Tile{after[0][i], after[1][i], after[2][i], after[3][i]},
-				dir,
-			)
-
-			for _, rowAnimation := range rowAnimations {
-				switch a := rowAnimation.(type) {
-				case moveRowAnimation:
-					animations = append(animations, moveAnimation{
-						origin: coord{i, must(a.Origin())},
-						dest:   coord{i, a.Dest()},
-					})
-				case spawnRowAnimation:
-					animations = append(animations, spawnAnimation{
-						dest:   coord{i, a.Dest()},
-						newVal: must(a.NewVal()),
-					})
-				case moveToCombineRowAnimation:
-					animations = append(animations, moveToCombineAnimation{
-						origin: coord{i, must(a.Origin())},
-						dest:   coord{i, a.Dest()},
-					})
-				case newFromCombineRowAnimation:
-					animations = append(animations, newFromCombineAnimation{
-						dest:   coord{i, a.Dest()},
-						newVal: must(a.NewVal()),
-					})
-				}
-			}
-		}
-	}
-
-	return animations
-}
-
-// rowAnimation provides data for animating a row of tiles.
-type rowAnimation interface {
-	// Origin returns the start index of the tile. Error if not applicable.
-	Origin() (int, error)
-	// Dest returns the final index of the tile.
-	Dest() int
-	// NewVal returns the new value resulting from a the animation. Error if not applicable.
-	NewVal() (int, error)
-}
-
-// moveAnimation represents the movement of a tile from one position to another, without
-// combining. Satisfies the animation interface.
-type moveRowAnimation struct {
-	origin int // tile index
-	dest   int // tile index
+// despawnAnimation represents a tile disappearing. Satisfies the animation interface.
+type despawnAnimation struct {
+	uuid   uuid.UUID // the disappearing tile
+	origin coord     // tile index
 }
 
-// Origin satisfies rowAnimation.
-func (a moveRowAnimation) Origin() (int, error) {
+// Origin satisfies the animation interface.
+func (a despawnAnimation) Origin() (coord, error) {
 	return a.origin, nil
 }
 
-// Dest satisfies rowAnimation.
-func (a moveRowAnimation) Dest() int {
-	return a.dest
+// Dest satisfies the animation interface.
+func (a despawnAnimation) Dest() coord {
+	return a.origin
 }
 
-// NewVal satisfies rowAnimation.
-func (a moveRowAnimation) NewVal() (int, error) {
+// NewVal satisfies the animation interface.
+func (a despawnAnimation) NewVal() (int, error) {
 	return 0, errFieldDoesNotExist
 }
 
-// String satisfies rowAnimation.
-func (a moveRowAnimation) String() string {
-	return fmt.Sprint("move from ", a.origin, " to ", a.dest)
+// String satisfies the animation interface.
+func (a despawnAnimation) String() string {
+	return fmt.Sprint("despawn at ", a.origin)
 }
 
-// spawnRowAnimation represents a new tile spawning. Satisfies the animation interface.
-type spawnRowAnimation struct {
-	dest   int // tile index
-	newVal int // value of a newly spawned tile. 0 if N/A
+// splitAnimation represents a tile separating from a combined tile and moving back
+// to its original position. Satisfies the animation interface.
+type splitAnimation struct {
+	uuid   uuid.UUID     // the separated tile
+	origin coord         // tile index
+	dest   coord         // tile index
+	newVal int           // the value of the separated tile
+	kind   grid.TileKind // the kind of the separated tile
 }
 
-// Origin satisfies rowAnimation.
-func (a spawnRowAnimation) Origin() (int, error) {
-	return -1, errFieldDoesNotExist
+// Origin satisfies the animation interface.
+func (a splitAnimation) Origin() (coord, error) {
+	return a.origin, nil
 }
 
-// Dest satisfies rowAnimation.
-func (a spawnRowAnimation) Dest() int {
+// Dest satisfies the animation interface.
+func (a splitAnimation) Dest() coord {
 	return a.dest
 }
 
-// NewVal satisfies rowAnimation.
-func (a spawnRowAnimation) NewVal() (int, error) {
+// NewVal satisfies the animation interface.
+func (a splitAnimation) NewVal() (int, error) {
 	return a.newVal, nil
 }
 
-// String satisfies rowAnimation.
-func (a spawnRowAnimation) String() string {
-	return fmt.Sprint("spawn at ", a.dest)
+// String satisfies the animation interface.
+func (a splitAnimation) String() string {
+	return fmt.Sprint("split from ", a.origin, " to ", a.dest)
 }
 
-// moveToCombineRowAnimation represents the movement of a tile into another, to
-// combine it. Satisfies the rowAnimation interface.
-type moveToCombineRowAnimation struct {
-	origin int // tile index
-	dest   int // tile index
+// lockAnimation represents a tile being locked or unlocked without moving.
+// Satisfies the animation interface.
+type lockAnimation struct {
+	uuid   uuid.UUID // the tile
+	origin coord     // tile index
+	val    int       // the value of the tile
+	locked bool      // whether the tile is locked after the animation
 }
 
-// Origin satisfies rowAnimation.
-func (a moveToCombineRowAnimation) Origin() (int, error) {
+// Origin satisfies the animation interface.
+func (a lockAnimation) Origin() (coord, error) {
 	return a.origin, nil
 }
 
-// Dest satisfies rowAnimation.
-func (a moveToCombineRowAnimation) Dest() int {
-	return a.dest
+// Dest satisfies the animation interface.
+func (a lockAnimation) Dest() coord {
+	return a.origin
 }
 
-// NewVal satisfies rowAnimation.
-func (a moveToCombineRowAnimation) NewVal() (int, error) {
-	return -1, errFieldDoesNotExist
+// NewVal satisfies the animation interface.
+func (a lockAnimation) NewVal() (int, error) {
+	return a.val, nil
 }
 
-// String satisfies rowAnimation.
-func (a moveToCombineRowAnimation) String() string {
-	return fmt.Sprint("move-to-combine from ", a.origin, " to ", a.dest)
+// String satisfies the animation interface.
+func (a lockAnimation) String() string {
+	if a.locked {
+		return fmt.Sprint("lock at ", a.origin)
+	}
+	return fmt.Sprint("unlock at ", a.origin)
 }
 
-// newFromCombineRowAnimation represents a new tile being created from a combination.
-// Satisfies the rowAnimation interface.
-type newFromCombineRowAnimation struct {
-	dest   int // tile index
-	newVal int // the value of the newly made tile
+// clearAnimation represents a tile being cleared by a bomb without moving.
+// Satisfies the animation interface.
+type clearAnimation struct {
+	uuid   uuid.UUID     // the cleared tile
+	origin coord         // tile index
+	val    int           // the value of the tile
+	kind   grid.TileKind // the kind of the tile
 }
 
-// Origin satisfies rowAnimation.
-func (a newFromCombineRowAnimation) Origin() (int, error) {
-	return -1, errFieldDoesNotExist
+// Origin satisfies the animation interface.
+func (a clearAnimation) Origin() (coord, error) {
+	return a.origin, nil
 }
 
-// Dest satisfies rowAnimation.
-func (a newFromCombineRowAnimation) Dest() int {
-	return a.dest
+// Dest satisfies the animation interface.
+func (a clearAnimation) Dest() coord {
+	return a.origin
 }
 
-// NewVal satisfies rowAnimation.
-func (a newFromCombineRowAnimation) NewVal() (int, error) {
-	return a.newVal, nil
+// NewVal satisfies the animation interface.
+func (a clearAnimation) NewVal() (int, error) {
+	return 0, errFieldDoesNotExist
 }
 
-// String satisfies rowAnimation.
-func (a newFromCombineRowAnimation) String() string {
-	return fmt.Sprint("new-from-combine at ", a.dest)
+// String satisfies the animation interface.
+func (a clearAnimation) String() string {
+	return fmt.Sprint("clear at ", a.origin)
 }
 
-// generateAnimations generates animation data for a row of tiles.
-func generateRowAnimations(before, after [numTiles]grid.Tile, dir grid.Direction) []rowAnimation {
-	var rowAnimations []rowAnimation
-
-	// Build a map of each tile's "before" position, indexed by their UUID
-	beforeUUIDs := make(map[uuid.UUID]int, numTiles)
-	for x := range before {
-		beforeUUIDs[before[x].UUID] = x
+// moveAnimations generates animation data for playing a move from the transitions
+// of its tiles.
+func moveAnimations(transitions []backend.Transition) []animation {
+	var animations []animation
+	for _, t := range transitions {
+		origin, dest := toCoord(t.From), toCoord(t.To)
+		switch t.Kind {
+		case backend.TransitionMove:
+			animations = append(animations, moveAnimation{uuid: t.UUID, origin: origin, dest: dest})
+		case backend.TransitionMerge:
+			animations = append(animations, moveToCombineAnimation{uuid: t.UUID, origin: origin, dest: dest})
+		case backend.TransitionCombine:
+			animations = append(animations, newFromCombineAnimation{uuid: t.UUID, dest: dest, newVal: t.Val})
+		case backend.TransitionSpawn:
+			animations = append(animations, spawnAnimation{uuid: t.UUID, dest: dest, newVal: t.Val, kind: t.Tile})
+		case backend.TransitionBlock:
+			animations = append(animations, spawnAnimation{uuid: t.UUID, dest: dest, newVal: t.Val, kind: grid.TileBlocker})
+		case backend.TransitionUnlock:
+			animations = append(animations, lockAnimation{uuid: t.UUID, origin: dest, val: t.Val})
+		case backend.TransitionClear:
+			animations = append(animations, clearAnimation{uuid: t.UUID, origin: dest, val: t.Val, kind: t.Tile})
+		}
 	}
+	return animations
+}
 
-	for x := range after {
-		// Like UUIDs indicates that a tile has moved
-		beforePos, ok := beforeUUIDs[after[x].UUID]
-		if ok && !(beforePos == x) {
-			rowAnimations = append(rowAnimations, moveRowAnimation{
-				origin: beforePos,
-				dest:   x,
-			})
+// rewindAnimations generates animation data for playing a move backwards from the
+// transitions of its tiles.
+func rewindAnimations(transitions []backend.Transition) []animation {
+	var animations []animation
+	for _, t := range transitions {
+		origin, dest := toCoord(t.To), toCoord(t.From)
+		switch t.Kind {
+		case backend.TransitionMove:
+			// The tile moves back to where it came from
+			animations = append(animations, moveAnimation{uuid: t.UUID, origin: origin, dest: dest})
+		case backend.TransitionMerge:
+			// The tile splits out of the combined tile
+			animations = append(animations, splitAnimation{uuid: t.UUID, origin: origin, dest: dest, newVal: t.Val, kind: t.Tile})
+		case backend.TransitionCombine, backend.TransitionSpawn, backend.TransitionBlock:
+			// Tiles which didn't exist before the move disappear
+			animations = append(animations, despawnAnimation{uuid: t.UUID, origin: origin})
+		case backend.TransitionUnlock:
+			// The tile becomes a blocker again
+			animations = append(animations, lockAnimation{uuid: t.UUID, origin: origin, val: t.Val, locked: true})
+		case backend.TransitionClear:
+			// The tile reappears where it was cleared, before moving back
+			animations = append(animations, clearAnimation{uuid: t.UUID, origin: origin, val: t.Val, kind: t.Tile})
 		}
+	}
+	return animations
+}
 
-		// Tiles with the Cmb flag set are from combinations
-		if after[x].Cmb {
-			// Newly formed tile from combination
-			rowAnimations = append(rowAnimations, newFromCombineRowAnimation{
-				dest:   x,
-				newVal: after[x].Val,
-			})
-
-			// The tiles that combined in the last turn can be ascertained from which tiles are
-			// missing, and the direction of movement
-
-			// Get the other tiles in the row that went missing after the move
-			missingTiles := make(map[uuid.UUID]grid.Tile)
-			for _, tile := range before {
-				if tile.Val != 0 {
-					missingTiles[tile.UUID] = tile
-				}
+// transitionsFit returns whether applying the transitions to the tiles of before
+// results in exactly the tiles of after.
+func transitionsFit(transitions []backend.Transition, before, after [][]grid.Tile) bool {
+	// Find which tile should be at each position once the transitions are applied
+	want := make(map[coord]uuid.UUID)
+	for i := range before {
+		for j := range before[i] {
+			if !before[i][j].Empty() {
+				want[coord{j, i}] = before[i][j].UUID
 			}
-			for _, tile := range after {
-				delete(missingTiles, tile.UUID)
+		}
+	}
+	for _, t := range transitions {
+		if t.NewTile() || t.Kind == backend.TransitionClear {
+			continue
+		}
+		if id, ok := want[toCoord(t.From)]; !ok || id != t.UUID {
+			return false
+		}
+		delete(want, toCoord(t.From))
+	}
+	// Tiles land in their new positions, then bombs clear tiles, then new tiles
+	// fill the spaces
+	land := func(newTiles bool) bool {
+		for _, t := range transitions {
+			if t.Kind == backend.TransitionMerge || t.Kind == backend.TransitionClear || t.NewTile() != newTiles {
+				continue
 			}
-
-			// Count the number of combinations as a result of the move
-			var numCombines int
-			for _, tile := range after {
-				if tile.Cmb {
-					numCombines++
-				}
-			}
-
-			// Get the positions of the tiles that went missing after the move
-			for uuid := range missingTiles {
-				origin := beforeUUIDs[uuid]
-				dest := x
-
-				isLegalMove := func(origin, dest int, dir grid.Direction) bool {
-					if origin == dest {
-						return false
-					}
-
-					// If there 4 tiles before, the maximum travel distance cannot be
-					// more than 2 in the direction of travel
-					const maxTravelDist = 2
-					travelDist := abs(dest - origin)
-
-					isFourLikeTiles := numCombines == 2 &&
-						before[0].Val == before[1].Val &&
-						before[0].Val == before[2].Val &&
-						before[0].Val == before[3].Val
-
-					isTwoPairsOfTiles := !isFourLikeTiles && numCombines == 2 &&
-						before[0].Val == before[1].Val &&
-						before[2].Val == before[3].Val
-
-					switch dir {
-					case grid.DirLeft, grid.DirUp:
-						// Index should get smaller
-						if origin < dest {
-							return false
-						}
-						// Manual exclusion for 2,2,2,2 situations
-						if isFourLikeTiles {
-							if travelDist > maxTravelDist {
-								return false
-							}
-							if origin == 2 && dest == 0 {
-								return false
-							}
-						}
-						// Manual exclusion for 2,2,4,4 situations
-						if isTwoPairsOfTiles {
-							if origin == 2 && dest == 0 {
-								return false
-							}
-							if origin == 3 && dest == 0 {
-								return false
-							}
-						}
-
-					case grid.DirRight, grid.DirDown:
-						// Index value should get larger
-						if origin > dest {
-							return false
-						}
-						// Manual exclusion for 2,2,2,2 and 2,2,4,4 situations
-						if isFourLikeTiles || isTwoPairsOfTiles {
-							if travelDist > maxTravelDist {
-								return false
-							}
-							if origin == 1 && dest == 3 {
-								return false
-							}
-						}
-					}
-
-					return true
-				}(origin, dest, dir)
-
-				if isLegalMove {
-					rowAnimations = append(rowAnimations, moveToCombineRowAnimation{
-						origin: origin,
-						dest:   dest,
-					})
-				}
+			if _, ok := want[toCoord(t.To)]; ok {
+				return false
 			}
+			want[toCoord(t.To)] = t.UUID
 		}
+		return true
 	}
-
-	// Non-combined tiles with unique UUIDs are newly spawned
-	for x := range after {
-		_, ok := beforeUUIDs[after[x].UUID]
-		if !ok && after[x].Val != 0 && !after[x].Cmb {
-			rowAnimations = append(rowAnimations, spawnRowAnimation{
-				dest:   x,
-				newVal: after[x].Val,
-			})
+	if !land(false) {
+		return false
+	}
+	for _, t := range transitions {
+		if t.Kind != backend.TransitionClear {
+			continue
 		}
+		if id, ok := want[toCoord(t.To)]; !ok || id != t.UUID {
+			return false
+		}
+		delete(want, toCoord(t.To))
+	}
+	if !land(true) {
+		return false
 	}
 
-	return rowAnimations
-}
-
-// must panics if err is not nil.
-func must[T any](val T, err error) T {
-	if err != nil {
-		panic(err)
+	// Compare them with the tiles which are actually there
+	numTiles := 0
+	for i := range after {
+		for j := range after[i] {
+			if after[i][j].Empty() {
+				continue
+			}
+			numTiles++
+			if id, ok := want[coord{j, i}]; !ok || id != after[i][j].UUID {
+				return false
+			}
+		}
 	}
-	return val
+	return numTiles == len(want)
 }
 
-// abs returns the absolute value of a signed integer.
-func abs[T constraints.Signed](a T) T {
-	if a >= 0 {
-		return a
-	}
-	return -a
+// toCoord converts a grid position into a coordinate.
+func toCoord(pos grid.Pos) coord {
+	return coord{pos.X, pos.Y}
 }
@@ -5,175 +5,98 @@ import (
 	"testing"
 
 	"github.com/google/uuid"
+	"github.com/z-riley/go-2048-battle/common/backend"
 	"github.com/z-riley/go-2048-battle/common/backend/grid"
 )
 
-func TestGenerateRowAnimations(t *testing.T) {
-	type tc struct {
-		name          string
-		before, after [numTiles]grid.Tile
-		dir           grid.Direction
-		want          []rowAnimation
-	}
-
+func TestMoveAnimations(t *testing.T) {
 	// Generate some UUIDs to use
-	id := make([]uuid.UUID, 4*4)
+	id := make([]uuid.UUID, 8)
 	for i := range id {
 		id[i] = uuid.Must(uuid.NewV7())
 	}
 
-	for _, tc := range []tc{
-		{
-			name: "Moving tiles",
-			before: [4]grid.Tile{
-				{Val: 0, Cmb: false, UUID: id[0]},
-				{Val: 2, Cmb: false, UUID: id[1]},
-				{Val: 0, Cmb: false, UUID: id[2]},
-				{Val: 4, Cmb: false, UUID: id[3]},
-			},
-			after: [4]grid.Tile{
-				{Val: 2, Cmb: false, UUID: id[1]},
-				{Val: 4, Cmb: false, UUID: id[3]},
-				{Val: 0, Cmb: false, UUID: id[6]},
-				{Val: 0, Cmb: false, UUID: id[7]},
-			},
-			dir: grid.DirLeft,
-			want: []rowAnimation{
-				moveRowAnimation{
-					origin: 1,
-					dest:   0,
-				},
-				moveRowAnimation{
-					origin: 3,
-					dest:   1,
-				},
-			},
-		},
-		{
-			name: "Combining 2 tiles with spawn",
-			before: [4]grid.Tile{
-				{Val: 0, Cmb: false, UUID: id[0]},
-				{Val: 2, Cmb: false, UUID: id[1]},
-				{Val: 0, Cmb: false, UUID: id[2]},
-				{Val: 2, Cmb: false, UUID: id[3]},
-			},
-			after: [4]grid.Tile{
-				{Val: 4, Cmb: true, UUID: id[4]},
-				{Val: 0, Cmb: false, UUID: id[5]},
-				{Val: 0, Cmb: false, UUID: id[6]},
-				{Val: 2, Cmb: false, UUID: id[7]},
-			},
-			dir: grid.DirUp,
-			want: []rowAnimation{
-				newFromCombineRowAnimation{
-					dest:   0,
-					newVal: 4,
-				},
-				moveToCombineRowAnimation{
-					origin: 1,
-					dest:   0,
-				},
-				moveToCombineRowAnimation{
-					origin: 3,
-					dest:   0,
-				},
-				spawnRowAnimation{
-					dest:   3,
-					newVal: 2,
-				},
-			},
-		},
+	transitions := []backend.Transition{
+		{Kind: backend.TransitionMove, UUID: id[0], Val: 8, From: grid.Pos{X: 3, Y: 1}, To: grid.Pos{X: 0, Y: 1}},
+		{Kind: backend.TransitionMerge, UUID: id[1], Val: 2, From: grid.Pos{X: 0, Y: 0}, To: grid.Pos{X: 0, Y: 0}, MergedInto: id[3]},
+		{Kind: backend.TransitionMerge, UUID: id[2], Val: 2, From: grid.Pos{X: 2, Y: 0}, To: grid.Pos{X: 0, Y: 0}, MergedInto: id[3]},
+		{Kind: backend.TransitionCombine, UUID: id[3], Val: 4, From: grid.Pos{X: 0, Y: 0}, To: grid.Pos{X: 0, Y: 0}},
+		{Kind: backend.TransitionSpawn, UUID: id[4], Val: 2, From: grid.Pos{X: 3, Y: 3}, To: grid.Pos{X: 3, Y: 3}},
+		{Kind: backend.TransitionUnlock, UUID: id[5], Val: 2, From: grid.Pos{X: 1, Y: 2}, To: grid.Pos{X: 1, Y: 2}},
+		{Kind: backend.TransitionBlock, UUID: id[6], Val: 2, From: grid.Pos{X: 2, Y: 2}, To: grid.Pos{X: 2, Y: 2}},
+		{Kind: backend.TransitionClear, UUID: id[7], Tile: grid.TileWildcard, From: grid.Pos{X: 3, Y: 0}, To: grid.Pos{X: 3, Y: 0}},
+	}
+
+	for _, tc := range []struct {
+		name string
+		got  []animation
+		want []animation
+	}{
 		{
-			name: "Combining 2 sets of 2 tiles",
-			before: [4]grid.Tile{
-				{Val: 2, Cmb: false, UUID: id[0]},
-				{Val: 2, Cmb: false, UUID: id[1]},
-				{Val: 4, Cmb: false, UUID: id[2]},
-				{Val: 4, Cmb: false, UUID: id[3]},
-			},
-			after: [4]grid.Tile{
-				{Val: 0, Cmb: false, UUID: id[4]},
-				{Val: 0, Cmb: false, UUID: id[5]},
-				{Val: 4, Cmb: true, UUID: id[6]},
-				{Val: 8, Cmb: true, UUID: id[7]},
-			},
-			dir: grid.DirRight,
-			want: []rowAnimation{
-				newFromCombineRowAnimation{
-					dest:   2,
-					newVal: 4,
-				},
-				moveToCombineRowAnimation{
-					origin: 0,
-					dest:   2,
-				},
-				moveToCombineRowAnimation{
-					origin: 1,
-					dest:   2,
-				},
-				newFromCombineRowAnimation{
-					dest:   3,
-					newVal: 8,
-				},
-				moveToCombineRowAnimation{
-					origin: 2,
-					dest:   3,
-				},
+			name: "forwards",
+			got:  moveAnimations(transitions),
+			want: []animation{
+				moveAnimation{uuid: id[0], origin: coord{3, 1}, dest: coord{0, 1}},
+				moveToCombineAnimation{uuid: id[1], origin: coord{0, 0}, dest: coord{0, 0}},
+				moveToCombineAnimation{uuid: id[2], origin: coord{2, 0}, dest: coord{0, 0}},
+				newFromCombineAnimation{uuid: id[3], dest: coord{0, 0}, newVal: 4},
+				spawnAnimation{uuid: id[4], dest: coord{3, 3}, newVal: 2},
+				lockAnimation{uuid: id[5], origin: coord{1, 2}, val: 2},
+				spawnAnimation{uuid: id[6], dest: coord{2, 2}, newVal: 2, kind: grid.TileBlocker},
+				clearAnimation{uuid: id[7], origin: coord{3, 0}, kind: grid.TileWildcard},
 			},
 		},
 		{
-			name: "Combining 4 similar tiles",
-			before: [4]grid.Tile{
-				{Val: 2, Cmb: false, UUID: id[0]},
-				{Val: 2, Cmb: false, UUID: id[1]},
-				{Val: 2, Cmb: false, UUID: id[2]},
-				{Val: 2, Cmb: false, UUID: id[3]},
-			},
-			after: [4]grid.Tile{
-				{Val: 0, Cmb: false, UUID: id[4]},
-				{Val: 0, Cmb: false, UUID: id[5]},
-				{Val: 4, Cmb: true, UUID: id[6]},
-				{Val: 8, Cmb: true, UUID: id[7]},
-			},
-			dir: grid.DirDown,
-			want: []rowAnimation{
-				newFromCombineRowAnimation{
-					dest:   2,
-					newVal: 4,
-				},
-				moveToCombineRowAnimation{
-					origin: 0,
-					dest:   2,
-				},
-				moveToCombineRowAnimation{
-					origin: 1,
-					dest:   2,
-				},
-				newFromCombineRowAnimation{
-					dest:   3,
-					newVal: 8,
-				},
-				moveToCombineRowAnimation{
-					origin: 2,
-					dest:   3,
-				},
+			name: "backwards",
+			got:  rewindAnimations(transitions),
+			want: []animation{
+				moveAnimation{uuid: id[0], origin: coord{0, 1}, dest: coord{3, 1}},
+				splitAnimation{uuid: id[1], origin: coord{0, 0}, dest: coord{0, 0}, newVal: 2},
+				splitAnimation{uuid: id[2], origin: coord{0, 0}, dest: coord{2, 0}, newVal: 2},
+				despawnAnimation{uuid: id[3], origin: coord{0, 0}},
+				despawnAnimation{uuid: id[4], origin: coord{3, 3}},
+				lockAnimation{uuid: id[5], origin: coord{1, 2}, val: 2, locked: true},
+				despawnAnimation{uuid: id[6], origin: coord{2, 2}},
+				clearAnimation{uuid: id[7], origin: coord{3, 0}, kind: grid.TileWildcard},
 			},
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			got := generateRowAnimations(tc.before, tc.after, tc.dir)
-			if !reflect.DeepEqual(sliceToMap(got), sliceToMap(tc.want)) {
-				t.Fatalf("Got %v, want %v", got, tc.want)
+			if !reflect.DeepEqual(tc.got, tc.want) {
+				t.Fatalf("Got %v, want %v", tc.got, tc.want)
 			}
 		})
 	}
 }
 
-// sliceToMap converts a slice into a map where each key is an element of the slice.
-func sliceToMap[T comparable](slice []T) map[T]struct{} {
-	m := make(map[T]struct{})
-	for _, element := range slice {
-		m[element] = struct{}{}
+func TestTransitionsFit(t *testing.T) {
+	for _, opts := range []*backend.Opts{
+		// Every move of a game on a non-default sized grid can be animated
+		{Seed: 3, Width: 5, Height: 3, UndoDepth: 1},
+		// Every move of a game with special tiles can be animated
+		{Seed: 5, Width: 5, Height: 4, UndoDepth: 1, Specials: true},
+	} {
+		testTransitionsFit(t, backend.NewGame(opts))
+	}
+}
+
+func testTransitionsFit(t *testing.T, game *backend.Game) {
+	for i := range 100 {
+		before := game.Grid.Clone().Tiles
+		res := game.ExecuteMove([]grid.Direction{grid.DirUp, grid.DirLeft, grid.DirDown, grid.DirRight}[i%4])
+		if !res.Moved {
+			continue
+		}
+		if !transitionsFit(game.Transitions, before, game.Grid.Tiles) {
+			t.Fatalf("Move %d: transitions don't fit:\n<%v>", i, game.Transitions)
+		}
+
+		// The transitions of a move can't be played from any other grid
+		if transitionsFit(game.Transitions, game.Grid.Tiles, game.Grid.Tiles) {
+			t.Fatalf("Move %d: transitions fit the wrong grid", i)
+		}
+		if game.Grid.Outcome() == grid.Lose {
+			break
+		}
 	}
-	return m
 }
@@ -1,8 +1,14 @@
 package backend
 
 import (
+	"cmp"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
 
+	"github.com/brunoga/deep"
 	"github.com/z-riley/go-2048-battle/common/backend/grid"
 	"github.com/z-riley/go-2048-battle/common/backend/store"
 	"github.com/z-riley/go-2048-battle/log"
@@ -14,14 +20,95 @@ type Game struct {
 	Score     int        `json:"score"`
 	HighScore int        `json:"highScore"`
 	Timer     *Timer     `json:"time"`
+	UndosUsed int        `json:"undosUsed"`        // the number of moves undone in the current game
+	Replay    *Replay    `json:"replay,omitempty"` // the recording of the current game
+	Assisted  bool       `json:"assisted"`         // whether the player had help (e.g. hints) in the current game
+	Mode      Mode       `json:"mode"`             // the rules the game is played by
+	Moves     int        `json:"moves"`            // the number of moves made in the current game
+	Target    int        `json:"target"`           // the value of the tile which wins the game
+	Battle    bool       `json:"battle"`           // whether big combinations attack the opponent
+	Started   time.Time  `json:"started"`          // when the current game started
 
-	store *store.Store
-	opts  *Opts
+	// History contains every action made since the game was created, including
+	// restarts, if the game keeps its history. It lets the game be checked with
+	// Verify.
+	History []Action `json:"history,omitempty"`
+
+	// HighScores and BestTimes contain the records for every mode which has been
+	// played, keyed by Mode.Key. HighScore is the high score of the current mode.
+	HighScores map[string]int           `json:"highScores,omitempty"`
+	BestTimes  map[string]time.Duration `json:"bestTimes,omitempty"`
+
+	// Transitions describes how each tile changed during the move which led to
+	// the current grid. At the start of a game, every tile is listed as spawned.
+	Transitions []Transition `json:"transitions,omitempty"`
+
+	// PrevHighScore is the high score before the current game started. Assisted
+	// games don't count towards the high score, so it is restored if the game
+	// becomes assisted.
+	PrevHighScore int `json:"prevHighScore"`
+
+	store     *store.Store
+	replays   map[string]*store.Store // stores of replays which may still be being written, by filename
+	opts      *Opts
+	attack    Attack     // the attack built up since it was last taken
+	actions   []Action   // the actions made since they were last taken, if the game is synchronised
+	undoStack []snapshot // previous game states, most recent last
+	redoStack []snapshot // undone game states, most recent last
 }
 
+// snapshot contains the state of a game at a point in time.
+type snapshot struct {
+	grid        *grid.Grid
+	score       int
+	moves       int
+	transitions []Transition
+}
+
+// DefaultUndoDepth is the number of moves which can be undone when no options are
+// given to NewGame.
+const DefaultUndoDepth = 16
+
 // Opts contains the configuration for the backend game.
 type Opts struct {
 	SaveToDisk bool
+	// SaveFile is the file which the game is saved in, relative to the data
+	// directory. If empty, DefaultSaveFile is used.
+	SaveFile string
+	// StatsFile is the file which the results of finished games are recorded in,
+	// relative to the data directory. If empty, DefaultStatsFile is used. Results
+	// are only recorded if SaveToDisk is enabled.
+	StatsFile string
+	// Seed seeds the random tile spawning. If zero, a random seed is used.
+	Seed int64
+	// Width and Height set the number of columns and rows in the grid. If zero,
+	// the default grid size is used.
+	Width, Height int
+	// UndoDepth is the maximum number of moves which can be undone. Undo is
+	// disabled if zero.
+	UndoDepth int
+	// Record enables recording the game so it can be played back. Recordings
+	// are saved as replays when the game ends if SaveToDisk is enabled.
+	Record bool
+	// Mode sets the rules of the game. If unset, the game is classic mode. A
+	// game loaded from the save file keeps its saved mode.
+	Mode Mode
+	// Target is the value of the tile which wins the game. If zero, the default
+	// target is used. A game loaded from the save file keeps its saved target.
+	Target int
+	// Battle enables attacking the opponent in a multiplayer game. Combining big
+	// tiles builds up an attack, which is taken with TakeAttack.
+	Battle bool
+	// Specials enables special tiles, such as walls, wildcards and bombs. A game
+	// loaded from the save file keeps its saved setting.
+	Specials bool
+	// Sync keeps every action made so it can be taken with TakeActions and played
+	// on another copy of the game, such as the host's copy of a guest's game.
+	Sync bool
+	// History keeps every action made since the game was created in the game's
+	// History, so another player can check the game could really have been
+	// played.
+	History bool
 }
 
 // NewGame returns the top-level struct for the game. If opts are nil, the
@@ -30,69 +117,380 @@ func NewGame(opts *Opts) *Game {
 	if opts == nil {
 		opts = &Opts{
 			SaveToDisk: true,
+			UndoDepth:  DefaultUndoDepth,
+			Record:     true,
 		}
 	}
 
-	g := &Game{
-		Grid:  grid.NewGrid(),
-		Score: 0,
-		Timer: NewTimer(),
-		store: store.NewStore(".save.bruh"),
-		opts:  opts,
-	}
-
+	g := newGame(opts)
 	if g.opts.SaveToDisk {
-		err := g.Load()
-		if err != nil {
-			log.Println("No save file found. Creating new one")
+		if err := g.Load(); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				log.Println("No save file found. Creating new one")
+			} else {
+				// Keep the unusable save file so it isn't lost, and start afresh
+				log.Printf("Failed to load save file: %v\n", err)
+				if path, err := g.store.Backup(); err != nil {
+					log.Printf("Failed to back up save file: %v\n", err)
+				} else {
+					log.Printf("Backed up save file to %s\n", path)
+				}
+				g = newGame(opts)
+			}
 			if err := g.Save(); err != nil {
-				panic(err)
+				log.Printf("Failed to create save file: %v\n", err)
 			}
 		}
 	}
 
+	// Games loaded from a save continue their existing recording
+	if g.opts.Record && g.Replay == nil {
+		g.Replay = newReplay(g)
+	}
+
+	return g
+}
+
+// newGame constructs a new game according to the options, without loading the
+// save file.
+func newGame(opts *Opts) *Game {
+	g := &Game{
+		Grid:    newGrid(opts),
+		Score:   0,
+		Timer:   NewTimer(),
+		Mode:    opts.Mode.orDefault(),
+		Target:  targetOrDefault(opts.Target),
+		Battle:  opts.Battle,
+		Started: time.Now(),
+		store:   store.NewStore(cmp.Or(opts.SaveFile, DefaultSaveFile)),
+		opts:    opts,
+	}
+	g.Transitions = spawnTransitions(g.Grid)
+	return g
+}
+
+// targetOrDefault returns the target tile, or the default target if it is unset
+// or invalid.
+func targetOrDefault(target int) int {
+	if !grid.ValidTarget(target) {
+		return grid.DefaultTarget
+	}
+	return target
+}
+
+// newGrid constructs a grid according to the options. Unset options are replaced
+// by their defaults.
+func newGrid(opts *Opts) *grid.Grid {
+	seed := opts.Seed
+	if seed == 0 {
+		seed = grid.RandomSeed()
+	}
+	width, height := opts.Width, opts.Height
+	if width == 0 || height == 0 {
+		width, height = grid.DefaultWidth, grid.DefaultHeight
+	}
+	g := grid.NewGridWithSize(width, height, seed)
+	if opts.Specials {
+		g.Specials = true
+		g.Reset()
+	}
 	return g
 }
 
 // Reset resets the game.
 func (g *Game) Reset() *Game {
+	g.saveReplay()
 	g.Grid.Reset()
 	g.Score = 0
 	g.Timer.Reset().Pause()
+	g.newGameState()
 	return g
 }
 
+// Resize resets the game with a grid of a new size.
+func (g *Game) Resize(width, height int) *Game {
+	g.saveReplay()
+	g.Grid.Resize(width, height)
+	g.Score = 0
+	g.Timer.Reset().Pause()
+	g.newGameState()
+	return g
+}
+
+// SetSpecials starts a new game with special tiles enabled or disabled.
+func (g *Game) SetSpecials(on bool) *Game {
+	g.Grid.Specials = on
+	return g.Reset()
+}
+
 // Reset resets the game whilst preserving the current timer state.
 func (g *Game) ResetKeepTimer() *Game {
+	g.saveReplay()
 	g.Grid.Reset()
 	g.Score = 0
+	g.newGameState()
 	return g
 }
 
-// ExecuteMove carries out a move in the given direction.
-func (g *Game) ExecuteMove(dir grid.Direction) {
-	pointsGained := g.Grid.Move(dir)
+// newGameState resets the state which is kept for the duration of one game.
+func (g *Game) newGameState() {
+	g.Assisted = false
+	g.Moves = 0
+	g.Started = time.Now()
+	g.Transitions = spawnTransitions(g.Grid)
+	g.PrevHighScore = g.HighScore
+	g.attack = Attack{}
+	g.actions = nil
+	g.clearHistory()
+	g.restartRecording()
+	if g.opts.History {
+		g.History = append(g.History, Action{Type: ActionReset, Time: time.Now()})
+	}
+}
+
+// MarkAssisted flags the current game as assisted. Any high score set during the
+// game is reverted.
+func (g *Game) MarkAssisted() {
+	if g.Assisted {
+		return
+	}
+	g.Assisted = true
+	g.setHighScore(g.PrevHighScore)
+	g.autosave()
+}
+
+// ExecuteMove carries out a move in the given direction. Returns a description of
+// everything which happened during the move.
+func (g *Game) ExecuteMove(dir grid.Direction) grid.MoveResult {
+	// Moves made by the game itself cannot fail
+	res, _ := g.executeMove(func() (grid.MoveResult, error) {
+		return g.Grid.Move(dir), nil
+	})
+	return res
+}
+
+// executeMove carries out a move using the given function to move the grid, then
+// updates the rest of the game state accordingly. Returns an error if the game is
+// over.
+func (g *Game) executeMove(move func() (grid.MoveResult, error)) (grid.MoveResult, error) {
+	if g.Over() {
+		return grid.MoveResult{}, errors.New("game is over")
+	}
+
+	before := g.snapshot()
+	res, err := move()
+	if err != nil {
+		g.restore(before)
+		return res, err
+	}
+	if res.Moved {
+		g.pushHistory(before)
+		g.recordMove(res)
+		g.Transitions = Transitions(res)
+		g.Moves++
+		if g.Battle {
+			g.attack.Blockers += attackFor(res).Blockers
+		}
+	}
 
 	// Update score
-	g.Score += pointsGained
-	if g.Score > g.HighScore {
-		g.HighScore = g.Score
+	g.Score += res.Points
+	if g.Score > g.HighScore && !g.Assisted {
+		g.setHighScore(g.Score)
 	}
 
-	if g.Grid.Outcome() == grid.Lose {
-		g.Timer.Pause()
+	if g.Over() {
+		g.finish()
 	} else {
 		g.Timer.Resume()
 	}
 
 	// Note: The game should save on exit anyway but save after move just in case
-	if g.opts.SaveToDisk {
-		go func() {
-			if err := g.Save(); err != nil {
-				panic(err)
-			}
-		}()
+	g.autosave()
+	return res, nil
+}
+
+// CanUndo returns whether there is a move which can be undone.
+func (g *Game) CanUndo() bool {
+	return len(g.undoStack) > 0
+}
+
+// CanRedo returns whether there is an undone move which can be redone.
+func (g *Game) CanRedo() bool {
+	return len(g.redoStack) > 0
+}
+
+// Undo reverts the game to its state before the last move. Returns false if
+// there is no move to undo.
+func (g *Game) Undo() bool {
+	if !g.CanUndo() {
+		return false
 	}
+
+	g.redoStack = append(g.redoStack, g.snapshot())
+	g.restore(g.undoStack[len(g.undoStack)-1])
+	g.undoStack = g.undoStack[:len(g.undoStack)-1]
+	g.UndosUsed++
+	g.record(Action{Type: ActionUndo})
+
+	g.autosave()
+	return true
+}
+
+// Redo re-applies the last undone move. Returns false if there is no move to redo.
+func (g *Game) Redo() bool {
+	if !g.CanRedo() {
+		return false
+	}
+
+	g.undoStack = append(g.undoStack, g.snapshot())
+	g.restore(g.redoStack[len(g.redoStack)-1])
+	g.redoStack = g.redoStack[:len(g.redoStack)-1]
+	g.record(Action{Type: ActionRedo})
+
+	g.autosave()
+	return true
+}
+
+// snapshot captures the current state of the game.
+func (g *Game) snapshot() snapshot {
+	return snapshot{grid: g.Grid.Clone(), score: g.Score, moves: g.Moves, transitions: g.Transitions}
+}
+
+// restore sets the game to the state of a snapshot.
+func (g *Game) restore(s snapshot) {
+	g.Grid = s.grid
+	g.Score = s.score
+	g.Moves = s.moves
+	g.Transitions = s.transitions
+}
+
+// pushHistory records a game state which can be returned to with Undo. The oldest
+// states are discarded once the undo depth is reached. Any undone moves can no
+// longer be redone.
+func (g *Game) pushHistory(s snapshot) {
+	if g.opts.UndoDepth <= 0 {
+		return
+	}
+	g.undoStack = append(g.undoStack, s)
+	if len(g.undoStack) > g.opts.UndoDepth {
+		g.undoStack = g.undoStack[len(g.undoStack)-g.opts.UndoDepth:]
+	}
+	g.redoStack = nil
+}
+
+// clearHistory forgets every previous game state.
+func (g *Game) clearHistory() {
+	g.undoStack = nil
+	g.redoStack = nil
+	g.UndosUsed = 0
+}
+
+// recordMove records a move made in the game.
+func (g *Game) recordMove(res grid.MoveResult) {
+	action := Action{Type: ActionMove, Dir: res.Dir}
+	if res.Spawn != nil {
+		// Playback gives the tile a new identity, so only its position and value
+		// are recorded
+		action.Spawn = &grid.Spawn{X: res.Spawn.X, Y: res.Spawn.Y, Val: res.Spawn.Val, Kind: res.Spawn.Kind}
+	}
+	g.record(action)
+}
+
+// record adds an action to the game's recording, if it is being recorded, keeps
+// it for TakeActions if the game is synchronised, and adds it to the game's
+// history if the game keeps one.
+func (g *Game) record(action Action) {
+	if g.Replay != nil {
+		g.Replay.record(action)
+	}
+	action.Time = time.Now()
+	if g.opts.Sync {
+		g.actions = append(g.actions, action)
+	}
+	if g.opts.History {
+		g.History = append(g.History, action)
+	}
+}
+
+// restartRecording starts a new recording from the current state of the game, if
+// the game is being recorded.
+func (g *Game) restartRecording() {
+	if g.opts.Record {
+		g.Replay = newReplay(g)
+	}
+}
+
+// saveReplay saves the game's recording in the background if it has any actions
+// and saving to disk is enabled. Saving the same recording again overwrites the
+// previous file. The replay is written by the time the game is next saved.
+func (g *Game) saveReplay() {
+	if g.Replay == nil || len(g.Replay.Actions) == 0 || !g.opts.SaveToDisk {
+		return
+	}
+	g.Replay.Score = g.Score
+	g.Replay.Assisted = g.Assisted
+	j, err := json.Marshal(g.Replay)
+	if err != nil {
+		log.Println("Failed to serialise replay:", err)
+		return
+	}
+	filename := g.Replay.Filename()
+	s, ok := g.replays[filename]
+	if !ok {
+		if g.replays == nil {
+			g.replays = make(map[string]*store.Store)
+		}
+		s = replayStore(filename)
+		g.replays[filename] = s
+	}
+	s.Queue(j)
+}
+
+// flushReplays waits for replays being saved in the background to be written.
+func (g *Game) flushReplays() error {
+	var errs []error
+	for filename, s := range g.replays {
+		if err := s.Flush(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to save replay %s: %w", filename, err))
+		}
+	}
+	g.replays = nil
+	return errors.Join(errs...)
+}
+
+// recordResult records the result of the game if saving to disk is enabled.
+func (g *Game) recordResult() {
+	if !g.opts.SaveToDisk {
+		return
+	}
+	if err := RecordResult(cmp.Or(g.opts.StatsFile, DefaultStatsFile), g.Result()); err != nil {
+		log.Println("Failed to record result:", err)
+	}
+}
+
+// autosave saves the game in the background if saving to disk is enabled. The game
+// state is captured straight away, and saves made in quick succession are combined
+// so only the latest state is written.
+func (g *Game) autosave() {
+	if !g.opts.SaveToDisk {
+		return
+	}
+	b, err := g.saveFile()
+	if err != nil {
+		log.Printf("Failed to save game: %v\n", err)
+		return
+	}
+	g.store.Queue(b)
+}
+
+// Snapshot returns a deep copy of the game's state, such as for drawing it. The
+// copy doesn't share the game's save file or replays, so it can be taken whilst
+// they're being written in the background, but it can't be saved.
+func (g *Game) Snapshot() Game {
+	c := *g
+	c.store, c.replays = nil, nil
+	return deep.MustCopy(c)
 }
 
 // Serialise converts the current game state into JSON.
@@ -105,25 +503,49 @@ func (g *Game) Deserialise(j []byte) error {
 	return json.Unmarshal(j, &g)
 }
 
-// Save saves the game state to the save file.
-func (g Game) Save() error {
-	j, err := g.Serialise()
+// Save saves the game state to the save file. It waits for any saves being made in
+// the background to finish first, so an older state can't overwrite it, and for
+// any replays being saved in the background.
+func (g *Game) Save() error {
+	b, err := g.saveFile()
 	if err != nil {
 		return err
 	}
-	return g.store.SaveBytes(j)
+	g.store.Queue(b)
+	return errors.Join(g.store.Flush(), g.flushReplays())
+}
+
+// saveFile returns the contents of the save file for the current game state.
+func (g *Game) saveFile() ([]byte, error) {
+	j, err := g.Serialise()
+	if err != nil {
+		return nil, err
+	}
+	return encodeSave(j)
 }
 
-// Load loads the game state from the save file.
+// Load loads the game state from the save file, upgrading saves from older
+// versions of the game. Returns an error wrapping os.ErrNotExist if there is no
+// save file, or another error if the save file can't be used.
 func (g *Game) Load() error {
 	b, err := g.store.ReadBytes()
 	if err != nil {
 		return err
 	}
-	err = g.Deserialise(b)
+	j, err := decodeSave(b)
 	if err != nil {
 		return err
 	}
+	err = g.Deserialise(j)
+	if err != nil {
+		return err
+	}
+	g.Mode = g.Mode.orDefault()
+	g.Target = targetOrDefault(g.Target)
+	if g.Started.IsZero() {
+		// Saves from before results were recorded don't have a start time
+		g.Started = time.Now()
+	}
 	// Cmb flags are required to be unset for the animations to work correctly
 	g.Grid.ClearCmbFlags()
 	return nil
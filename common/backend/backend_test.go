@@ -1,15 +1,56 @@
 package backend
 
 import (
+	"encoding/json"
+	"os"
+	"reflect"
 	"testing"
 	"time"
+
+	"github.com/brunoga/deep"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/backend/store"
 )
 
+func TestMain(m *testing.M) {
+	// Keep saves and replays out of the user's data directory
+	d, err := os.MkdirTemp("", "backend-test")
+	if err != nil {
+		panic(err)
+	}
+	store.SetDir(d)
+	code := m.Run()
+	_ = os.RemoveAll(d)
+	os.Exit(code)
+}
+
+func TestDeepCopy(t *testing.T) {
+	// The screens copy games to draw them
+	for _, opts := range []*Opts{nil, {SaveToDisk: true}, {SaveToDisk: true, Record: true}} {
+		game := NewGame(opts)
+		game.ExecuteMove(grid.DirUp)
+		game.Reset()
+
+		// Snapshots can be taken whilst the game is being saved in the background
+		if snapshot := game.Snapshot(); snapshot.Grid.Debug() != game.Grid.Debug() {
+			t.Errorf("Expected snapshot:\n<%v>\nGot:\n<%v>", game.Grid.Debug(), snapshot.Grid.Debug())
+		}
+
+		if err := game.Save(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := deep.Copy(*game); err != nil {
+			t.Errorf("Failed to copy game with options %+v: %v", opts, err)
+		}
+	}
+}
+
 func TestSerialiseDeserialise(t *testing.T) {
 	// Create a game and let the timer change value
 	game := NewGame(nil)
 	game.Timer.Reset().Resume()
 	time.Sleep(100 * time.Millisecond)
+	game.Timer.Pause()
 	b, err := game.Serialise()
 	if err != nil {
 		t.Error(err)
@@ -34,3 +75,214 @@ func TestSerialiseDeserialise(t *testing.T) {
 		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", expected, got)
 	}
 }
+
+func TestSeededGamesAreReproducible(t *testing.T) {
+	moves := []grid.Direction{
+		grid.DirUp, grid.DirLeft, grid.DirDown, grid.DirRight,
+		grid.DirLeft, grid.DirLeft, grid.DirUp, grid.DirRight,
+		grid.DirDown, grid.DirDown, grid.DirLeft, grid.DirUp,
+	}
+
+	game1 := NewGame(&Opts{Seed: 1234})
+	game2 := NewGame(&Opts{Seed: 1234})
+	for _, dir := range moves {
+		game1.ExecuteMove(dir)
+		game2.ExecuteMove(dir)
+	}
+
+	if game1.Score != game2.Score {
+		t.Errorf("Expected score:\n<%v>\nGot:\n<%v>", game1.Score, game2.Score)
+	}
+	if game1.Grid.Debug() != game2.Grid.Debug() {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", game1.Grid.Debug(), game2.Grid.Debug())
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	game := NewGame(&Opts{Seed: 99, UndoDepth: 2})
+
+	// Record the state of the game after each move
+	var states []string
+	var scores []int
+	states = append(states, game.Grid.Debug())
+	scores = append(scores, game.Score)
+	for _, dir := range []grid.Direction{grid.DirLeft, grid.DirUp, grid.DirRight} {
+		game.ExecuteMove(dir)
+		states = append(states, game.Grid.Debug())
+		scores = append(scores, game.Score)
+	}
+
+	// Only the last two moves can be undone
+	for i := len(states) - 2; i >= 1; i-- {
+		if !game.Undo() {
+			t.Fatalf("Expected undo %d to succeed", i)
+		}
+		if game.Grid.Debug() != states[i] || game.Score != scores[i] {
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", states[i], game.Grid.Debug())
+		}
+	}
+	if game.Undo() {
+		t.Error("Expected undo beyond the undo depth to fail")
+	}
+	if game.UndosUsed != 2 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 2, game.UndosUsed)
+	}
+
+	// Redo back to the latest state
+	for i := 2; i < len(states); i++ {
+		if !game.Redo() {
+			t.Fatalf("Expected redo %d to succeed", i)
+		}
+		if game.Grid.Debug() != states[i] || game.Score != scores[i] {
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", states[i], game.Grid.Debug())
+		}
+	}
+	if game.Redo() {
+		t.Error("Expected redo with no undone moves to fail")
+	}
+}
+
+func TestUndoDisabled(t *testing.T) {
+	game := NewGame(&Opts{Seed: 99})
+	game.ExecuteMove(grid.DirLeft)
+	game.ExecuteMove(grid.DirUp)
+	if game.Undo() {
+		t.Error("Expected undo to be disabled")
+	}
+}
+
+func TestReplayPlayback(t *testing.T) {
+	game := NewGame(&Opts{Seed: 42, UndoDepth: 4, Record: true})
+	for _, dir := range []grid.Direction{
+		grid.DirLeft, grid.DirUp, grid.DirRight, grid.DirDown,
+		grid.DirLeft, grid.DirLeft, grid.DirUp, grid.DirRight,
+	} {
+		game.ExecuteMove(dir)
+	}
+	game.Undo()
+	game.Undo()
+	game.Redo()
+	game.ExecuteMove(grid.DirDown)
+
+	// Round trip the replay through JSON as if it was saved to disk
+	b, err := json.Marshal(game.Replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replay Replay
+	if err := json.Unmarshal(b, &replay); err != nil {
+		t.Fatal(err)
+	}
+
+	player := NewPlayer(&replay)
+	for !player.Done() {
+		if err := player.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := player.Game()
+	if got.Grid.Debug() != game.Grid.Debug() {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", game.Grid.Debug(), got.Grid.Debug())
+	}
+	if got.Score != game.Score {
+		t.Errorf("Expected score:\n<%v>\nGot:\n<%v>", game.Score, got.Score)
+	}
+}
+
+func TestAssistedGamesDontSetHighScore(t *testing.T) {
+	game := NewGame(&Opts{Seed: 5})
+	game.HighScore = 1000
+	game.Reset()
+
+	// Give the game a high score, then have it revert when assisted
+	game.Score = 2000
+	game.ExecuteMove(grid.DirLeft)
+	game.ExecuteMove(grid.DirUp)
+	if game.HighScore < 2000 {
+		t.Fatalf("Expected high score of at least 2000, got %d", game.HighScore)
+	}
+	game.MarkAssisted()
+	if game.HighScore != 1000 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 1000, game.HighScore)
+	}
+	game.ExecuteMove(grid.DirRight)
+	game.ExecuteMove(grid.DirDown)
+	if game.HighScore != 1000 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 1000, game.HighScore)
+	}
+
+	// The next game is not assisted
+	game.Reset()
+	if game.Assisted {
+		t.Error("Expected new game to not be assisted")
+	}
+}
+
+func TestScoreCountsEveryCombination(t *testing.T) {
+	game := NewGame(&Opts{Seed: 1})
+	game.Grid.Tiles = [][]grid.Tile{
+		{{Val: 2}, {Val: 2}, {Val: 4}, {Val: 4}},
+		{{Val: 8}, {Val: 8}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+	}
+
+	res := game.ExecuteMove(grid.DirLeft)
+	if len(res.Merges) != 3 {
+		t.Errorf("Expected 3 merges, got <%+v>", res.Merges)
+	}
+	if game.Score != 4+8+16 {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 4+8+16, game.Score)
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	game := NewGame(&Opts{Seed: 1, UndoDepth: 4})
+	game.Grid.Tiles = [][]grid.Tile{
+		{{Val: 2}, {Val: 2}, {Val: 4}, {Val: 0}},
+		{{Val: 0}, {Val: 8}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+	}
+	before := game.Grid.Clone().Tiles
+	start := game.Transitions
+
+	game.ExecuteMove(grid.DirLeft)
+	kinds := make(map[TransitionKind]int)
+	for _, tr := range game.Transitions {
+		kinds[tr.Kind]++
+		if tr.Kind == TransitionMerge && game.Grid.Tiles[tr.To.Y][tr.To.X].UUID != tr.MergedInto {
+			t.Errorf("Expected %v to merge into the tile at %v", tr.UUID, tr.To)
+		}
+		if !tr.NewTile() && before[tr.From.Y][tr.From.X].UUID != tr.UUID {
+			t.Errorf("Expected %v to start at %v", tr.UUID, tr.From)
+		}
+	}
+	want := map[TransitionKind]int{
+		TransitionMove:    2,
+		TransitionMerge:   2,
+		TransitionCombine: 1,
+		TransitionSpawn:   1,
+	}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", want, kinds)
+	}
+
+	// Undo and redo restore the transitions of the move which led to each grid
+	moved := game.Transitions
+	game.Undo()
+	if !reflect.DeepEqual(game.Transitions, start) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", start, game.Transitions)
+	}
+	game.Redo()
+	if !reflect.DeepEqual(game.Transitions, moved) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", moved, game.Transitions)
+	}
+
+	// A new game spawns its starting tiles
+	game.Reset()
+	if len(game.Transitions) != 2 || game.Transitions[0].Kind != TransitionSpawn {
+		t.Errorf("Expected two spawned tiles, got <%v>", game.Transitions)
+	}
+}
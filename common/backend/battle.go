@@ -0,0 +1,89 @@
+package backend
+
+import (
+	"errors"
+	"math/bits"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// AttackThreshold is the lowest value of a combined tile which attacks the
+// opponent in a battle.
+const AttackThreshold = 64
+
+// Attack is the garbage sent to the opponent in a battle.
+type Attack struct {
+	Blockers int `json:"blockers"` // the number of blocker tiles to place on the opponent's grid
+}
+
+// attackFor returns the attack caused by a move. Every combined tile of at least
+// AttackThreshold sends one blocker, plus one more for each doubling above the
+// threshold.
+func attackFor(res grid.MoveResult) Attack {
+	var a Attack
+	for _, m := range res.Merges {
+		if m.Val < AttackThreshold {
+			continue
+		}
+		a.Blockers += bits.Len(uint(m.Val / AttackThreshold))
+	}
+	return a
+}
+
+// TakeAttack returns the attack built up by the moves made since it was last
+// taken, so it can be sent to the opponent. Returns false if there is nothing to
+// send.
+func (g *Game) TakeAttack() (Attack, bool) {
+	a := g.attack
+	g.attack = Attack{}
+	return a, a.Blockers > 0
+}
+
+// ReceiveAttack places the garbage sent by the opponent on the grid. Blockers are
+// placed in random empty positions, so a full grid takes no garbage. The moves
+// made before the attack can no longer be undone.
+func (g *Game) ReceiveAttack(a Attack) {
+	if a.Blockers <= 0 {
+		return
+	}
+	_ = g.placeBlockers(func() ([]grid.Spawn, error) {
+		return g.Grid.AddBlockers(a.Blockers), nil
+	})
+}
+
+// placeBlockers places blocker tiles on the grid using the given function, and
+// updates the game accordingly. Returns an error if the game is over or the
+// blockers cannot be placed.
+func (g *Game) placeBlockers(place func() ([]grid.Spawn, error)) error {
+	if g.Over() {
+		return errors.New("game is over")
+	}
+
+	before := g.snapshot()
+	placed, err := place()
+	if err != nil {
+		g.restore(before)
+		return err
+	}
+	if len(placed) == 0 {
+		return nil
+	}
+
+	// The blockers weren't placed by a move, so moves from before them can't be
+	// taken back
+	g.undoStack, g.redoStack = nil, nil
+	g.Transitions = blockTransitions(placed)
+	blockers := make([]grid.Spawn, len(placed))
+	for i, b := range placed {
+		// Playback gives the tiles new identities, so only their positions and
+		// values are recorded
+		blockers[i] = grid.Spawn{X: b.X, Y: b.Y, Val: b.Val}
+	}
+	g.record(Action{Type: ActionAttack, Blockers: blockers})
+
+	if g.Over() {
+		g.finish()
+	}
+	g.autosave()
+	return nil
+}
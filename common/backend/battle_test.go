@@ -0,0 +1,101 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+func TestAttackFor(t *testing.T) {
+	for _, tc := range []struct {
+		merges []int
+		want   int
+	}{
+		{merges: nil, want: 0},
+		{merges: []int{4, 32}, want: 0},
+		{merges: []int{AttackThreshold}, want: 1},
+		{merges: []int{AttackThreshold * 4}, want: 3},
+		{merges: []int{AttackThreshold, AttackThreshold * 2}, want: 3},
+	} {
+		var res grid.MoveResult
+		for _, val := range tc.merges {
+			res.Merges = append(res.Merges, grid.Merge{Val: val})
+		}
+		if got := attackFor(res).Blockers; got != tc.want {
+			t.Errorf("Merges %v: expected %d blockers, got %d", tc.merges, tc.want, got)
+		}
+	}
+}
+
+func TestBattle(t *testing.T) {
+	tiles := func() [][]grid.Tile {
+		return [][]grid.Tile{
+			{{Val: 64}, {Val: 64}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		}
+	}
+
+	// Only battles build up attacks
+	classic := NewGame(&Opts{Seed: 1})
+	classic.Grid.Tiles = tiles()
+	classic.ExecuteMove(grid.DirLeft)
+	if _, ok := classic.TakeAttack(); ok {
+		t.Error("Expected a classic game to not attack")
+	}
+
+	attacker := NewGame(&Opts{Seed: 1, Battle: true})
+	attacker.Grid.Tiles = tiles()
+	attacker.ExecuteMove(grid.DirLeft)
+	attack, ok := attacker.TakeAttack()
+	if !ok || attack.Blockers != 2 {
+		t.Fatalf("Expected an attack of 2 blockers, got %v", attack)
+	}
+	if _, ok := attacker.TakeAttack(); ok {
+		t.Error("Expected the attack to only be taken once")
+	}
+
+	// The attack lands on the opponent's grid, and can't be undone
+	defender := NewGame(&Opts{Seed: 2, Battle: true, UndoDepth: 4, Record: true})
+	defender.ExecuteMove(grid.DirLeft)
+	defender.ExecuteMove(grid.DirUp)
+	defender.ReceiveAttack(attack)
+	if n := countBlockers(defender.Grid); n != attack.Blockers {
+		t.Fatalf("Expected %d blockers on the grid, got %d", attack.Blockers, n)
+	}
+	for _, tr := range defender.Transitions {
+		if tr.Kind != TransitionBlock {
+			t.Errorf("Expected only block transitions, got %v", tr)
+		}
+	}
+	if defender.CanUndo() {
+		t.Error("Expected moves before an attack to not be undoable")
+	}
+
+	// The attack is played back from the recording
+	defender.ExecuteMove(grid.DirRight)
+	p := NewPlayer(defender.Replay)
+	for !p.Done() {
+		if err := p.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := p.Game()
+	if got.Grid.Debug() != defender.Grid.Debug() || countBlockers(got.Grid) != countBlockers(defender.Grid) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", defender.Grid.Debug(), got.Grid.Debug())
+	}
+}
+
+// countBlockers returns the number of blocker tiles on a grid.
+func countBlockers(g *grid.Grid) int {
+	n := 0
+	for y := range g.Tiles {
+		for x := range g.Tiles[y] {
+			if g.Tiles[y][x].Locked() {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,307 @@
+package grid
+
+import (
+	"math/bits"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// bitboardSize is the number of rows and columns in a bitboard.
+	bitboardSize = 4
+	// maxBitboardExp is the largest tile exponent which fits in a bitboard cell.
+	maxBitboardExp = 15
+)
+
+// Bitboard is a 4x4 grid packed into 64 bits, which is fast to copy and move.
+// Each cell takes 4 bits holding the base 2 logarithm of the tile's value, or
+// zero if the cell is empty. Row y occupies bits [16y, 16y+16), and column x
+// occupies bits [4x, 4x+4) of its row.
+type Bitboard uint64
+
+// rowMove contains the result of moving a row of a bitboard towards column 0.
+type rowMove struct {
+	row    uint16   // the row after the move
+	points int      // the points gained from combining tiles
+	dest   [4]uint8 // the column each tile moves to
+	merged uint8    // bit x is set if the tile in column x after the move is a combination
+}
+
+// rowMoves contains the result of moving every possible row towards column 0.
+var rowMoves [1 << 16]rowMove
+
+func init() {
+	for row := range len(rowMoves) {
+		rowMoves[row] = computeRowMove(uint16(row))
+	}
+}
+
+// computeRowMove moves a row towards column 0, combining tiles in the same way as
+// moveStep. Tiles with the largest exponent aren't combined, because the result
+// wouldn't fit in a cell.
+func computeRowMove(row uint16) rowMove {
+	var m rowMove
+	next := 0           // the column the next tile slides to
+	canCombine := false // whether the tile before next can be combined
+	var out [bitboardSize]uint16
+	for x := range bitboardSize {
+		exp := (row >> (4 * x)) & 0xF
+		if exp == 0 {
+			continue
+		}
+		if canCombine && out[next-1] == exp && exp < maxBitboardExp {
+			out[next-1]++
+			m.points += 1 << out[next-1]
+			m.dest[x] = uint8(next - 1)
+			m.merged |= 1 << (next - 1)
+			canCombine = false
+			continue
+		}
+		out[next] = exp
+		m.dest[x] = uint8(next)
+		next++
+		canCombine = true
+	}
+	for x := range bitboardSize {
+		m.row |= out[x] << (4 * x)
+	}
+	return m
+}
+
+// BitboardFromTiles packs a set of tiles into a bitboard. Returns false if the
+// tiles aren't a 4x4 grid, if any tile's value isn't a power of 2 less than
+// 2^15, or if any tile isn't a normal number tile.
+func BitboardFromTiles(tiles [][]Tile) (Bitboard, bool) {
+	if len(tiles) != bitboardSize {
+		return 0, false
+	}
+	var b Bitboard
+	for y := range tiles {
+		if len(tiles[y]) != bitboardSize {
+			return 0, false
+		}
+		for x := range tiles[y] {
+			if tiles[y][x].Kind != TileNormal {
+				return 0, false
+			}
+			exp, ok := bitboardExp(tiles[y][x].Val)
+			if !ok {
+				return 0, false
+			}
+			b |= Bitboard(exp) << (16*y + 4*x)
+		}
+	}
+	return b, true
+}
+
+// BitboardFromValues packs tile values, indexed by row then column, into a
+// bitboard. Empty spaces are zero. Returns false if the values aren't a 4x4 grid,
+// or if any value isn't zero or a power of 2 less than 2^15.
+func BitboardFromValues(vals [][]int) (Bitboard, bool) {
+	if len(vals) != bitboardSize {
+		return 0, false
+	}
+	var b Bitboard
+	for y := range vals {
+		if len(vals[y]) != bitboardSize {
+			return 0, false
+		}
+		for x, val := range vals[y] {
+			exp, ok := bitboardExp(val)
+			if !ok {
+				return 0, false
+			}
+			b |= Bitboard(exp) << (16*y + 4*x)
+		}
+	}
+	return b, true
+}
+
+// bitboardExp returns the exponent which a tile value is kept as in a bitboard
+// cell. Returns false if the value can't be kept in a cell.
+func bitboardExp(val int) (int, bool) {
+	if val == emptyTile {
+		return 0, true
+	}
+	if val < 2 || bits.OnesCount(uint(val)) != 1 {
+		return 0, false
+	}
+	// The largest tile can't be combined because the result wouldn't fit in a
+	// cell, so it isn't allowed on a bitboard
+	exp := bits.TrailingZeros(uint(val))
+	if exp >= maxBitboardExp {
+		return 0, false
+	}
+	return exp, true
+}
+
+// Tiles unpacks the bitboard into a new set of tiles. Every tile is given a new
+// UUID.
+func (b Bitboard) Tiles() [][]Tile {
+	tiles := NewTiles(bitboardSize, bitboardSize)
+	for y := range tiles {
+		for x := range tiles[y] {
+			tiles[y][x].Val = b.Tile(x, y)
+		}
+	}
+	return tiles
+}
+
+// Tile returns the value of the tile at a position, or zero if it is empty.
+func (b Bitboard) Tile(x, y int) int {
+	exp := (b >> (16*y + 4*x)) & 0xF
+	if exp == 0 {
+		return emptyTile
+	}
+	return 1 << exp
+}
+
+// Move returns the bitboard after moving in the given direction, without spawning
+// a new tile, and the points gained from combining tiles.
+func (b Bitboard) Move(dir Direction) (Bitboard, int) {
+	vertical := dir == DirUp || dir == DirDown
+	if vertical {
+		b = b.transpose()
+	}
+	reverse := dir == DirRight || dir == DirDown
+
+	var out Bitboard
+	points := 0
+	for y := range bitboardSize {
+		row := uint16(b >> (16 * y))
+		if reverse {
+			row = reverseRow(row)
+		}
+		m := &rowMoves[row]
+		if reverse {
+			out |= Bitboard(reverseRow(m.row)) << (16 * y)
+		} else {
+			out |= Bitboard(m.row) << (16 * y)
+		}
+		points += m.points
+	}
+
+	if vertical {
+		out = out.transpose()
+	}
+	return out, points
+}
+
+// transpose swaps the rows and columns of the bitboard.
+func (b Bitboard) transpose() Bitboard {
+	a1 := b & 0xF0F00F0FF0F00F0F
+	a2 := b & 0x0000F0F00000F0F0
+	a3 := b & 0x0F0F00000F0F0000
+	a := a1 | (a2 << 12) | (a3 >> 12)
+	b1 := a & 0xFF00FF0000FF00FF
+	b2 := a & 0x00FF00FF00000000
+	b3 := a & 0x00000000FF00FF00
+	return b1 | (b2 >> 24) | (b3 << 24)
+}
+
+// reverseRow reverses the order of the cells in a row.
+func reverseRow(row uint16) uint16 {
+	return row>>12 | (row>>4)&0x00F0 | (row<<4)&0x0F00 | row<<12
+}
+
+// bitboardPos returns the grid position of a step along a line of a 4x4 grid.
+// Step 0 is at the edge which the tiles are moving towards.
+func bitboardPos(dir Direction, line, step int) (int, int) {
+	switch dir {
+	case DirLeft:
+		return step, line
+	case DirRight:
+		return bitboardSize - 1 - step, line
+	case DirUp:
+		return line, step
+	default:
+		return line, bitboardSize - 1 - step
+	}
+}
+
+// moveBitboard moves the tiles of a 4x4 grid using the row lookup table. The
+// identity of each tile is kept as in moveStep: moved tiles keep their UUID and
+// combined tiles get a new UUID and the Cmb flag. Unlike moveStep, emptied spaces
+// are left without a UUID, since nothing is drawn for them and generating one
+// for each space took half the time of the move. Returns a description of the
+// move, without a spawned tile.
+func (g *Grid) moveBitboard(b Bitboard, dir Direction) MoveResult {
+	cells := make([]Tile, bitboardSize*bitboardSize)
+	next := make([][]Tile, bitboardSize)
+	for y := range next {
+		next[y] = cells[y*bitboardSize : (y+1)*bitboardSize]
+	}
+	res := MoveResult{Dir: dir}
+	for line := range bitboardSize {
+		// Read the line as a row moving towards column 0
+		var row uint16
+		for step := range bitboardSize {
+			x, y := bitboardPos(dir, line, step)
+			row |= uint16((b>>(16*y+4*x))&0xF) << (4 * step)
+		}
+		m := &rowMoves[row]
+		if m.row != row {
+			res.Moved = true
+		}
+		res.Points += m.points
+
+		// Place each tile at its destination
+		filled := uint8(0)
+		var merges [bitboardSize]int // the index in res.Merges of the combination at each step
+		for step := range bitboardSize {
+			x, y := bitboardPos(dir, line, step)
+			src := g.Tiles[y][x]
+			if src.Val == emptyTile {
+				continue
+			}
+			dest := int(m.dest[step])
+			destX, destY := bitboardPos(dir, line, dest)
+			tileMove := TileMove{
+				UUID: src.UUID,
+				Val:  src.Val,
+				From: Pos{X: x, Y: y},
+				To:   Pos{X: destX, Y: destY},
+			}
+			switch {
+			case m.merged&(1<<dest) == 0:
+				next[destY][destX] = src
+				if dest != step {
+					res.Moves = append(res.Moves, tileMove)
+				}
+			case filled&(1<<dest) == 0:
+				// The first tile of a combination creates the new tile
+				next[destY][destX] = Tile{
+					Val:  src.Val * 2,
+					Cmb:  true,
+					UUID: uuid.Must(uuid.NewV7()),
+				}
+				merges[dest] = len(res.Merges)
+				res.Merges = append(res.Merges, Merge{
+					Sources: [2]TileMove{tileMove},
+					UUID:    next[destY][destX].UUID,
+					Val:     next[destY][destX].Val,
+					To:      tileMove.To,
+				})
+			default:
+				res.Merges[merges[dest]].Sources[1] = tileMove
+			}
+			filled |= 1 << dest
+		}
+
+		// Spaces which were already empty keep their identity
+		for step := range bitboardSize {
+			if filled&(1<<step) != 0 {
+				continue
+			}
+			x, y := bitboardPos(dir, line, step)
+			if g.Tiles[y][x].Val == emptyTile {
+				next[y][x] = g.Tiles[y][x]
+			}
+		}
+	}
+
+	if res.Moved {
+		g.Tiles = next
+	}
+	return res
+}
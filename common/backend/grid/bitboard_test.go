@@ -0,0 +1,210 @@
+package grid
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBitboardRoundTrip(t *testing.T) {
+	tiles := [][]Tile{
+		{{Val: 0}, {Val: 2}, {Val: 4}, {Val: 8}},
+		{{Val: 16}, {Val: 32}, {Val: 64}, {Val: 128}},
+		{{Val: 256}, {Val: 512}, {Val: 1024}, {Val: 2048}},
+		{{Val: 4096}, {Val: 8192}, {Val: 16384}, {Val: 0}},
+	}
+
+	b, ok := BitboardFromTiles(tiles)
+	if !ok {
+		t.Fatal("Expected tiles to fit in a bitboard")
+	}
+	if got := b.Tiles(); !gridsAreEqual(tiles, got) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", tiles, got)
+	}
+}
+
+func TestBitboardFromTilesRejects(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		tiles [][]Tile
+	}{
+		{
+			name:  "wrong size",
+			tiles: NewTiles(5, 4),
+		},
+		{
+			name: "not a power of 2",
+			tiles: [][]Tile{
+				{{Val: 3}, {Val: 0}, {Val: 0}, {Val: 0}},
+				{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+				{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+				{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+			},
+		},
+		{
+			name: "too large to combine",
+			tiles: [][]Tile{
+				{{Val: 32768}, {Val: 0}, {Val: 0}, {Val: 0}},
+				{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+				{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+				{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, ok := BitboardFromTiles(tc.tiles); ok {
+				t.Error("Expected tiles to be rejected")
+			}
+		})
+	}
+}
+
+func TestBitboardTranspose(t *testing.T) {
+	tiles := [][]Tile{
+		{{Val: 2}, {Val: 4}, {Val: 8}, {Val: 16}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 32}},
+	}
+	b, _ := BitboardFromTiles(tiles)
+
+	expected := transpose(tiles)
+	if got := b.transpose().Tiles(); !gridsAreEqual(expected, got) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", expected, got)
+	}
+}
+
+func TestBitboardMatchesReference(t *testing.T) {
+	// Play a long game and compare the move engines on every position reached
+	g := NewGridWithSeed(11)
+	for i := range 2000 {
+		if g.Outcome() == Lose {
+			g.Reset()
+		}
+
+		for _, dir := range []Direction{DirUp, DirDown, DirLeft, DirRight} {
+			b, ok := BitboardFromTiles(g.Tiles)
+			if !ok {
+				t.Fatalf("Expected move %d to fit in a bitboard", i)
+			}
+
+			reference := g.Clone()
+			reference.ClearCmbFlags()
+			want := reference.moveReference(dir)
+
+			fast := g.Clone()
+			fast.ClearCmbFlags()
+			got := fast.moveBitboard(b, dir)
+
+			if !sameResult(got, want) {
+				t.Fatalf("Move %d %v:\nExpected:\n<%+v>\nGot:\n<%+v>", i, dir, want, got)
+			}
+			if !gridsAreEqual(reference.Tiles, fast.Tiles) {
+				t.Fatalf("Move %d %v:\nExpected:\n<%v>\nGot:\n<%v>", i, dir, reference.Debug(), fast.Debug())
+			}
+			if after, points := b.Move(dir); after != mustBitboard(t, fast.Tiles) || points != want.Points {
+				t.Fatalf("Move %d %v: bitboard doesn't match the grid", i, dir)
+			}
+			checkIdentities(t, g.Tiles, fast.Tiles)
+		}
+
+		g.Move([]Direction{DirUp, DirLeft, DirDown, DirRight}[i%4])
+	}
+}
+
+// sameResult returns whether two move results describe the same move. Combined
+// tiles are given new UUIDs by each engine, so their UUIDs aren't compared.
+func sameResult(r1, r2 MoveResult) bool {
+	if r1.Dir != r2.Dir || r1.Moved != r2.Moved || r1.Points != r2.Points ||
+		!reflect.DeepEqual(r1.Moves, r2.Moves) || len(r1.Merges) != len(r2.Merges) {
+		return false
+	}
+	for i := range r1.Merges {
+		m1, m2 := r1.Merges[i], r2.Merges[i]
+		if m1.Sources != m2.Sources || m1.Val != m2.Val || m1.To != m2.To {
+			return false
+		}
+	}
+	return true
+}
+
+// checkIdentities checks that tiles kept their UUIDs in the way the arena expects
+// after a move.
+func checkIdentities(t *testing.T, before, after [][]Tile) {
+	t.Helper()
+
+	beforePos := make(map[uuid.UUID][2]int)
+	for y := range before {
+		for x := range before[y] {
+			beforePos[before[y][x].UUID] = [2]int{x, y}
+		}
+	}
+	for y := range after {
+		for x := range after[y] {
+			tile := after[y][x]
+			pos, existed := beforePos[tile.UUID]
+			switch {
+			case tile.Cmb && existed:
+				t.Fatalf("Combined tile at {%d,%d} reused a UUID", x, y)
+			case tile.Val == 0 && tile.UUID != uuid.Nil && existed && pos != [2]int{x, y}:
+				t.Fatalf("Empty space at {%d,%d} took its UUID from %v", x, y, pos)
+			case tile.Val != 0 && !tile.Cmb && !existed:
+				t.Fatalf("Moved tile at {%d,%d} has a new UUID", x, y)
+			}
+		}
+	}
+}
+
+// mustBitboard packs tiles into a bitboard, failing the test if they don't fit.
+func mustBitboard(t *testing.T, tiles [][]Tile) Bitboard {
+	t.Helper()
+	b, ok := BitboardFromTiles(tiles)
+	if !ok {
+		t.Fatal("Expected tiles to fit in a bitboard")
+	}
+	return b
+}
+
+// benchmarkTiles returns the tiles of a grid partway through a game.
+func benchmarkTiles() [][]Tile {
+	g := NewGridWithSeed(1)
+	for i := range 100 {
+		g.Move([]Direction{DirUp, DirLeft, DirDown, DirRight}[i%4])
+	}
+	return g.Tiles
+}
+
+func BenchmarkMoveReference(b *testing.B) {
+	tiles := benchmarkTiles()
+	g := &Grid{}
+	for b.Loop() {
+		for _, dir := range []Direction{DirUp, DirDown, DirLeft, DirRight} {
+			g.Tiles = (&Grid{Tiles: tiles}).Clone().Tiles
+			g.ClearCmbFlags()
+			g.moveReference(dir)
+		}
+	}
+}
+
+func BenchmarkMoveBitboard(b *testing.B) {
+	tiles := benchmarkTiles()
+	g := &Grid{}
+	for b.Loop() {
+		for _, dir := range []Direction{DirUp, DirDown, DirLeft, DirRight} {
+			g.Tiles = (&Grid{Tiles: tiles}).Clone().Tiles
+			g.ClearCmbFlags()
+			board, _ := BitboardFromTiles(g.Tiles)
+			g.moveBitboard(board, dir)
+		}
+	}
+}
+
+func BenchmarkBitboardMove(b *testing.B) {
+	board, _ := BitboardFromTiles(benchmarkTiles())
+	for b.Loop() {
+		for _, dir := range []Direction{DirUp, DirDown, DirLeft, DirRight} {
+			board.Move(dir)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package grid
+
+const (
+	// BlockerVal is the value of the number on a blocker tile.
+	BlockerVal = 2
+	// BlockerLock is the number of moves a blocker tile stays locked for.
+	BlockerLock = 5
+)
+
+// AddBlockers places up to n locked blocker tiles in random empty positions.
+// Returns the blockers which were placed, which is fewer than n if the grid
+// fills up.
+func (g *Grid) AddBlockers(n int) []Spawn {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var empty []Pos
+	for y := range g.Tiles {
+		for x := range g.Tiles[y] {
+			if g.Tiles[y][x].Empty() {
+				empty = append(empty, Pos{X: x, Y: y})
+			}
+		}
+	}
+
+	rng := g.rng()
+	var placed []Spawn
+	for range min(n, len(empty)) {
+		i := rng.IntN(len(empty))
+		pos := empty[i]
+		empty = append(empty[:i], empty[i+1:]...)
+
+		// The position is known to be empty so placing the tile cannot fail
+		blocker, _ := g.placeBlocker(Spawn{X: pos.X, Y: pos.Y, Val: BlockerVal})
+		placed = append(placed, blocker)
+	}
+	return placed
+}
+
+// PlaceBlockers places locked blocker tiles at the given positions. It is used to
+// play back recorded games. Returns an error if any position is occupied.
+func (g *Grid) PlaceBlockers(blockers []Spawn) ([]Spawn, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var placed []Spawn
+	for _, b := range blockers {
+		blocker, err := g.placeBlocker(b)
+		if err != nil {
+			return placed, err
+		}
+		placed = append(placed, blocker)
+	}
+	return placed, nil
+}
+
+// placeBlocker places a locked blocker tile on the grid, giving it a new identity.
+// Returns the tile which was placed, or an error if the position is off the grid or
+// already occupied.
+func (g *Grid) placeBlocker(spawn Spawn) (Spawn, error) {
+	placed, err := g.placeTile(spawn)
+	if err != nil {
+		return Spawn{}, err
+	}
+	g.Tiles[placed.Y][placed.X].Kind = TileBlocker
+	g.Tiles[placed.Y][placed.X].Lock = BlockerLock
+	return placed, nil
+}
+
+// countDownLocks counts down the lock of every blocker tile by one move. Blockers
+// whose lock reaches zero become normal tiles. Returns the tiles which unlocked.
+func (g *Grid) countDownLocks() []Unlock {
+	var unlocks []Unlock
+	for y := range g.Tiles {
+		for x := range g.Tiles[y] {
+			t := &g.Tiles[y][x]
+			if !t.Locked() {
+				continue
+			}
+			t.Lock--
+			if t.Lock > 0 {
+				continue
+			}
+			t.Kind, t.Lock = TileNormal, 0
+			unlocks = append(unlocks, Unlock{UUID: t.UUID, Val: t.Val, Pos: Pos{X: x, Y: y}})
+		}
+	}
+	return unlocks
+}
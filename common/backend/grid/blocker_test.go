@@ -0,0 +1,72 @@
+package grid
+
+import (
+	"testing"
+)
+
+func TestBlockers(t *testing.T) {
+	g := NewGridWithSize(4, 4, 1)
+	g.Tiles = [][]Tile{
+		{{Val: 2}, {Val: 0}, {Val: 0}, {Val: 2}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+	}
+	if _, err := g.PlaceBlockers([]Spawn{{X: 1, Y: 0, Val: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.PlaceBlockers([]Spawn{{X: 1, Y: 0, Val: 2}}); err == nil {
+		t.Error("Expected an error when placing a blocker on an occupied position")
+	}
+	blocker := g.Tiles[0][1]
+
+	// The blocker stops tiles sliding past it, and doesn't combine with them
+	res := g.move(DirLeft)
+	if !res.Moved {
+		t.Fatal("Expected the tiles to move")
+	}
+	if g.Tiles[0][0].Val != 2 || !g.Tiles[0][1].Locked() || g.Tiles[0][2].Val != 2 {
+		t.Fatalf("Expected the right tile to stop at the blocker, got:\n%s", g.Debug())
+	}
+	if len(res.Merges) != 0 || res.Points != 0 {
+		t.Errorf("Expected no merges, got %v", res.Merges)
+	}
+
+	// The blocker unlocks once enough moves have been made
+	dirs := []Direction{DirUp, DirDown}
+	var unlocks []Unlock
+	for i := 1; i < BlockerLock; i++ {
+		if !g.Tiles[0][1].Locked() {
+			t.Fatalf("Expected blocker to be locked after %d moves", i)
+		}
+		unlocks = g.move(dirs[i%2]).Unlocks
+	}
+	if len(unlocks) != 1 || unlocks[0].UUID != blocker.UUID {
+		t.Fatalf("Expected the blocker to unlock, got %v", unlocks)
+	}
+	for y := range g.Tiles {
+		for x := range g.Tiles[y] {
+			if g.Tiles[y][x].UUID == blocker.UUID && g.Tiles[y][x].Locked() {
+				t.Errorf("Expected tile at {%d,%d} to be unlocked", x, y)
+			}
+		}
+	}
+}
+
+func TestBlockersCantCombine(t *testing.T) {
+	// The only equal neighbours are a blocker and a tile, so the grid is lost
+	g := Grid{
+		Tiles: [][]Tile{
+			{{Val: 2, Kind: TileBlocker, Lock: 1}, {Val: 2}, {Val: 4}, {Val: 2}},
+			{{Val: 4}, {Val: 8}, {Val: 2}, {Val: 4}},
+			{{Val: 2}, {Val: 4}, {Val: 8}, {Val: 2}},
+			{{Val: 4}, {Val: 2}, {Val: 4}, {Val: 8}},
+		},
+	}
+	if !g.isLoss() {
+		t.Error("Expected a grid which can only combine with a blocker to be lost")
+	}
+	if _, ok := BitboardFromTiles(g.Tiles); ok {
+		t.Error("Expected a grid with a blocker to not fit on a bitboard")
+	}
+}
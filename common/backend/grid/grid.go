@@ -1,7 +1,7 @@
 package grid
 
 import (
-	"math/rand"
+	"fmt"
 	"reflect"
 	"strconv"
 	"sync"
@@ -10,30 +10,99 @@ import (
 )
 
 const (
-	GridSize   = 4
-	gridWidth  = GridSize
-	gridHeight = GridSize
+	// DefaultWidth is the default number of columns in a grid.
+	DefaultWidth = 4
+	// DefaultHeight is the default number of rows in a grid.
+	DefaultHeight = 4
+	// MinSize is the minimum number of rows or columns in a grid.
+	MinSize = 3
+	// MaxSize is the maximum number of rows or columns in a grid.
+	MaxSize = 8
+	// DefaultTarget is the value of the tile which wins a game by default.
+	DefaultTarget = 2048
+	// MinTarget is the lowest tile value which can be set as the target.
+	MinTarget = 8
 )
 
 // Grid contains the tiles for the game. Position {0,0} is the top left square.
+// Tiles are indexed by row, then column.
 type Grid struct {
 	mu    sync.Mutex
-	Tiles [gridWidth][gridHeight]Tile `json:"tiles"`
+	Tiles [][]Tile `json:"tiles"`
+	Seed  int64    `json:"seed"` // the seed which the grid's randomness was generated from
+	RNG   *RNG     `json:"rng"`  // the source of randomness for spawning tiles
+
+	// Specials enables spawning special tiles, and placing a wall at the start
+	// of a game.
+	Specials bool `json:"specials,omitempty"`
 
 	LastMove Direction
 }
 
-// NewGrid constructs a new grid.
+// Spawn describes a tile which was spawned onto the grid.
+type Spawn struct {
+	X    int       `json:"x"`
+	Y    int       `json:"y"`
+	Val  int       `json:"val"`
+	UUID uuid.UUID `json:"uuid,omitzero"` // the identity given to the new tile
+	Kind TileKind  `json:"kind,omitempty"`
+}
+
+// NewGrid constructs a new grid with a random seed.
 func NewGrid() *Grid {
+	return NewGridWithSeed(RandomSeed())
+}
+
+// NewGridWithSeed constructs a new grid which owns its own source of randomness.
+// Grids created with the same seed produce identical tiles when given the same
+// sequence of moves.
+func NewGridWithSeed(seed int64) *Grid {
+	return NewGridWithSize(DefaultWidth, DefaultHeight, seed)
+}
+
+// NewGridWithSize constructs a new grid with the given number of columns and rows.
+// Panics if either dimension is outside of the range [MinSize, MaxSize].
+func NewGridWithSize(width, height int, seed int64) *Grid {
+	if !ValidSize(width, height) {
+		panic(fmt.Sprintf("invalid grid size %dx%d", width, height))
+	}
+
 	g := Grid{
 		mu:    sync.Mutex{},
-		Tiles: NewTiles(),
+		Tiles: NewTiles(width, height),
+		Seed:  seed,
+		RNG:   NewRNG(seed),
 	}
 	g.Reset()
 
 	return &g
 }
 
+// ValidSize returns whether a grid can have the given dimensions.
+func ValidSize(width, height int) bool {
+	return width >= MinSize && width <= MaxSize &&
+		height >= MinSize && height <= MaxSize
+}
+
+// ValidTarget returns whether a tile value can be set as the target which wins a
+// game. Targets must be a power of 2 which can be reached from a new tile.
+func ValidTarget(target int) bool {
+	return target >= MinTarget && target&(target-1) == 0
+}
+
+// Width returns the number of columns in the grid.
+func (g *Grid) Width() int {
+	if len(g.Tiles) == 0 {
+		return 0
+	}
+	return len(g.Tiles[0])
+}
+
+// Height returns the number of rows in the grid.
+func (g *Grid) Height() int {
+	return len(g.Tiles)
+}
+
 // Direction represents a direction that the player can move the tiles in.
 type Direction string
 
@@ -44,31 +113,91 @@ const (
 	DirRight Direction = "right"
 )
 
+// ValidDirection returns whether the tiles can be moved in the given direction.
+func ValidDirection(dir Direction) bool {
+	switch dir {
+	case DirUp, DirDown, DirLeft, DirRight:
+		return true
+	default:
+		return false
+	}
+}
+
 // Move attempts to move in the specified direction, spawning a new tile if appropriate.
-func (g *Grid) Move(dir Direction) int {
+// Returns a description of everything which happened during the move.
+func (g *Grid) Move(dir Direction) MoveResult {
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	didMove, pointsGained := g.move(dir)
-	if didMove {
-		g.spawnTile()
+	res := g.move(dir)
+	if res.Moved {
+		spawn := g.spawnTile()
+		res.Spawn = &spawn
 	}
 	g.LastMove = dir
-	return pointsGained
+	return res
+}
+
+// MoveWithSpawn attempts to move in the specified direction, spawning the given
+// tile instead of a random one if appropriate. It is used to play back recorded
+// games. Returns an error if the spawn position is not empty after the move.
+func (g *Grid) MoveWithSpawn(dir Direction, spawn Spawn) (MoveResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	res := g.move(dir)
+	g.LastMove = dir
+	if res.Moved {
+		placed, err := g.placeTile(spawn)
+		if err != nil {
+			return res, err
+		}
+		res.Spawn = &placed
+	}
+	return res, nil
 }
 
 // Reset resets the grid to a start-of-game state, spawning two '2' tiles in random locations.
+// The size of the grid is preserved.
 func (g *Grid) Reset() {
-	g.Tiles = NewTiles()
+	width, height := g.Width(), g.Height()
+	if width == 0 || height == 0 {
+		width, height = DefaultWidth, DefaultHeight
+	}
+	g.Tiles = NewTiles(width, height)
+
 	// Place two '2' tiles in random positions
+	rng := g.rng()
 	type pos struct{ x, y int }
-	tile1 := pos{rand.Intn(gridWidth), rand.Intn(gridHeight)}
-	tile2 := pos{rand.Intn(gridWidth), rand.Intn(gridHeight)}
+	tile1 := pos{rng.IntN(width), rng.IntN(height)}
+	tile2 := pos{rng.IntN(width), rng.IntN(height)}
 	for reflect.DeepEqual(tile1, tile2) {
 		// Try again until they're unique
-		tile2 = pos{rand.Intn(gridWidth), rand.Intn(gridHeight)}
+		tile2 = pos{rng.IntN(width), rng.IntN(height)}
+	}
+	g.Tiles[tile1.y][tile1.x].Val = newTileVal(rng)
+	g.Tiles[tile2.y][tile2.x].Val = newTileVal(rng)
+
+	if g.Specials && width*height >= minWallSpaces {
+		g.placeWall(rng)
+	}
+}
+
+// Resize resets the grid to a start-of-game state with new dimensions. Panics if
+// either dimension is outside of the range [MinSize, MaxSize].
+func (g *Grid) Resize(width, height int) {
+	if !ValidSize(width, height) {
+		panic(fmt.Sprintf("invalid grid size %dx%d", width, height))
+	}
+	g.Tiles = NewTiles(width, height)
+	g.Reset()
+}
+
+// rng returns the grid's source of randomness, creating it from the seed if it
+// doesn't exist yet (e.g. the grid was loaded from an older save).
+func (g *Grid) rng() *RNG {
+	if g.RNG == nil {
+		g.RNG = NewRNG(g.Seed)
 	}
-	g.Tiles[tile1.x][tile1.y].Val = newTileVal()
-	g.Tiles[tile2.x][tile2.y].Val = newTileVal()
+	return g.RNG
 }
 
 // NumTiles returns the number of non zero tiles on the grid.
@@ -76,7 +205,7 @@ func (g *Grid) NumTiles() int {
 	n := 0
 	for i := range g.Tiles {
 		for j := range g.Tiles[i] {
-			if g.Tiles[i][j].Val != 0 {
+			if !g.Tiles[i][j].Empty() {
 				n++
 			}
 		}
@@ -102,12 +231,19 @@ const (
 	Lose Outcome = "lose"
 )
 
-// Outcome returns the current outcome of the grid.
+// Outcome returns the current outcome of the grid, where reaching the default
+// target tile is a win.
 func (g *Grid) Outcome() Outcome {
+	return g.OutcomeWithTarget(DefaultTarget)
+}
+
+// OutcomeWithTarget returns the current outcome of the grid, where reaching a tile
+// of the target value is a win.
+func (g *Grid) OutcomeWithTarget(target int) Outcome {
 	switch {
 	case g.isLoss():
 		return Lose
-	case g.HighestTile() >= 2048:
+	case g.HighestTile() >= target:
 		return Win
 	default:
 		return None
@@ -115,54 +251,106 @@ func (g *Grid) Outcome() Outcome {
 }
 
 // spawnTile spawns a single new tile in a random location on the grid. The value of the
-// tile is either 2 (90% chance) or 4 (10% chance).
-func (g *Grid) spawnTile() {
-	x, y := rand.Intn(gridWidth), rand.Intn(gridHeight)
-	for g.Tiles[x][y].Val != emptyTile {
+// tile is either 2 (90% chance) or 4 (10% chance). If special tiles are enabled, the
+// tile is sometimes a special tile instead.
+func (g *Grid) spawnTile() Spawn {
+	rng := g.rng()
+	x, y := rng.IntN(g.Width()), rng.IntN(g.Height())
+	for !g.Tiles[y][x].Empty() {
 		// Try again until they're unique
-		x, y = rand.Intn(gridWidth), rand.Intn(gridHeight)
+		x, y = rng.IntN(g.Width()), rng.IntN(g.Height())
 	}
 
-	g.Tiles[x][y].Val = newTileVal()
-	g.Tiles[x][y].UUID = uuid.Must(uuid.NewV7())
+	spawn := Spawn{X: x, Y: y, Val: newTileVal(rng)}
+	if g.Specials {
+		spawn.Kind = newTileKind(rng)
+		if spawn.Kind == TileWildcard || spawn.Kind == TileBomb {
+			spawn.Val = 0
+		}
+	}
+
+	// The position is known to be empty so placing the tile cannot fail
+	spawn, _ = g.placeTile(spawn)
+	return spawn
+}
+
+// placeTile places a new tile on the grid, giving it a new identity. Returns the
+// tile which was placed, or an error if the position is off the grid or already
+// occupied.
+func (g *Grid) placeTile(spawn Spawn) (Spawn, error) {
+	if spawn.Y < 0 || spawn.Y >= g.Height() || spawn.X < 0 || spawn.X >= g.Width() {
+		return Spawn{}, fmt.Errorf("spawn position {%d,%d} is off the grid", spawn.X, spawn.Y)
+	}
+	if !g.Tiles[spawn.Y][spawn.X].Empty() {
+		return Spawn{}, fmt.Errorf("spawn position {%d,%d} is occupied", spawn.X, spawn.Y)
+	}
+
+	spawn.UUID = uuid.Must(uuid.NewV7())
+	g.Tiles[spawn.Y][spawn.X].Val = spawn.Val
+	g.Tiles[spawn.Y][spawn.X].UUID = spawn.UUID
+	g.Tiles[spawn.Y][spawn.X].Kind = spawn.Kind
+	return spawn, nil
 }
 
 // move attempts to move all tiles in the specified direction, combining them if appropriate.
-// Returns true if any tiles were moved from the attempt, and the added score from any combinations.
-func (g *Grid) move(dir Direction) (bool, int) {
+// Returns a description of the move, without a spawned tile.
+func (g *Grid) move(dir Direction) MoveResult {
 	// Clear all of the "combined this turn" flags
-	for i := range gridWidth {
-		for j := range gridHeight {
-			g.Tiles[i][j].Cmb = false
-		}
+	g.ClearCmbFlags()
+
+	// Use the fast move engine if the grid can be packed into a bitboard
+	var res MoveResult
+	if b, ok := BitboardFromTiles(g.Tiles); ok {
+		res = g.moveBitboard(b, dir)
+	} else {
+		res = g.moveReference(dir)
 	}
+	if res.Moved {
+		res.Clears = g.detonate()
+		res.Unlocks = g.countDownLocks()
+	}
+	return res
+}
+
+// moveReference attempts to move all tiles in the specified direction by shifting
+// them one space at a time. It works for grids of any size and tile values.
+// Returns a description of the move, without a spawned tile.
+func (g *Grid) moveReference(dir Direction) MoveResult {
+	// Combinations are found from the Cmb flags, so flags left over from an
+	// earlier move must be cleared first
+	g.ClearCmbFlags()
+	before := (&Grid{Tiles: g.Tiles}).Clone().Tiles
+	pointsGained := g.moveSteps(dir)
+
+	res := describeMove(dir, before, g.Tiles)
+	res.Points = pointsGained
+	return res
+}
 
-	moved := false
+// moveSteps shifts all tiles in the specified direction one space at a time until
+// they can no longer move. Returns the added score from any combinations.
+func (g *Grid) moveSteps(dir Direction) int {
 	pointsGained := 0
 
+	// The moveStep function only operates on a row, so to move vertically
+	// we must transpose the grid before and after the move operation.
+	if dir == DirUp || dir == DirDown {
+		g.Tiles = transpose(g.Tiles)
+		defer func() { g.Tiles = transpose(g.Tiles) }()
+	}
+
 	// Execute moves until grid can no longer move
 	for {
 		movedThisTurn := false
-		for row := range gridHeight {
+		for row := range g.Tiles {
 			var rowMoved bool
 			var points int
 
-			// The moveStep function only operates on a row, so to move vertically
-			// we must transpose the grid before and after the move operation.
-			if dir == DirUp || dir == DirDown {
-				g.Tiles = transpose(g.Tiles)
-			}
 			g.Tiles[row], rowMoved, points = moveStep(g.Tiles[row], dir)
-			if points > 0 {
-				pointsGained = points
-			}
-			if dir == DirUp || dir == DirDown {
-				g.Tiles = transpose(g.Tiles)
-			}
+			pointsGained += points
 
 			if rowMoved {
 				movedThisTurn = true
-				moved = true
 			}
 		}
 		if !movedThisTurn {
@@ -170,13 +358,13 @@ func (g *Grid) move(dir Direction) (bool, int) {
 		}
 	}
 
-	return moved, pointsGained
+	return pointsGained
 }
 
 // moveStep executes one part of the a move on a grid row. Call multiple times until false
 // is returned to complete a full move. Returns the row after move, whether any tiles moved,
 // and the number of points gained by the move.
-func moveStep(g [gridWidth]Tile, dir Direction) ([gridWidth]Tile, bool, int) {
+func moveStep(g []Tile, dir Direction) ([]Tile, bool, int) {
 	// Iterate in the same direction as the move
 	reverse := false
 	if dir == DirRight || dir == DirDown {
@@ -197,28 +385,26 @@ func moveStep(g [gridWidth]Tile, dir Direction) ([gridWidth]Tile, bool, int) {
 			continue
 		}
 
-		// Skip if source tile is empty
-		if g[i].Val == emptyTile {
+		// Skip if source tile is empty or can't move
+		if !g[i].Movable() {
 			continue
 		}
 
-		// Combine if similar tile exists at destination and end turn
+		// Combine if a matching tile exists at destination and end turn
 		alreadyCombined := g[i].Cmb || g[newPos].Cmb
-		if g[newPos].Val == g[i].Val && !alreadyCombined {
-			g[newPos].Val += g[i].Val // update the new location
+		if combined, ok := combine(g[i], g[newPos]); ok && !alreadyCombined {
+			g[newPos] = combined // update the new location
 			g[newPos].Cmb = true
 			g[newPos].UUID = uuid.Must(uuid.NewV7())
-			valAfterCombine := g[newPos].Val
-			g[i].Val = emptyTile // clear the old location
-			g[i].UUID = uuid.Must(uuid.NewV7())
-			return g, true, valAfterCombine
-		} else if g[newPos].Val != emptyTile {
+			g[i] = Tile{UUID: uuid.Must(uuid.NewV7())} // clear the old location
+			return g, true, combined.Val
+		} else if !g[newPos].Empty() {
 			// Move blocked by another tile
 			continue
 		}
 
 		// Destination empty; move tile and end turn
-		if g[newPos].Val == emptyTile {
+		if g[newPos].Empty() {
 			g[newPos] = g[i]
 			g[i] = Tile{UUID: uuid.Must(uuid.NewV7())}
 			return g, true, 0
@@ -231,27 +417,21 @@ func moveStep(g [gridWidth]Tile, dir Direction) ([gridWidth]Tile, bool, int) {
 // isLoss returns true if the grid is in a losing state (gridlocked).
 func (g *Grid) isLoss() bool {
 	// False if any empty spaces exist
-	for i := range gridHeight {
-		for j := range gridWidth {
-			if g.Tiles[i][j].Val == emptyTile {
+	for i := range g.Tiles {
+		for j := range g.Tiles[i] {
+			if g.Tiles[i][j].Empty() {
 				return false
 			}
 		}
 	}
 
-	// False if any similar tiles exist next to each other
-	for i := range gridHeight {
-		for j := range gridWidth - 1 {
-			if g.Tiles[i][j].Val == g.Tiles[i][j+1].Val {
-				return false
-			}
-		}
-	}
-	t := transpose(g.Tiles)
-	for i := range gridHeight {
-		for j := range gridWidth - 1 {
-			if t[i][j].Val == t[i][j+1].Val {
-				return false
+	// False if any tiles next to each other can combine
+	for _, tiles := range [][][]Tile{g.Tiles, transpose(g.Tiles)} {
+		for i := range tiles {
+			for j := range len(tiles[i]) - 1 {
+				if canCombine(tiles[i][j], tiles[i][j+1]) {
+					return false
+				}
 			}
 		}
 	}
@@ -262,8 +442,8 @@ func (g *Grid) isLoss() bool {
 // HighestTile returns the value of the highest tile on the grid.
 func (g *Grid) HighestTile() int {
 	highest := 0
-	for a := range gridHeight {
-		for b := range gridWidth {
+	for a := range g.Tiles {
+		for b := range g.Tiles[a] {
 			if g.Tiles[a][b].Val > highest {
 				highest = g.Tiles[a][b].Val
 			}
@@ -275,8 +455,8 @@ func (g *Grid) HighestTile() int {
 // Debug arranges the grid into a human readable Debug for debugging purposes.
 func (g *Grid) Debug() string {
 	var out string
-	for row := range gridHeight {
-		for col := range gridWidth {
+	for row := range g.Tiles {
+		for col := range g.Tiles[row] {
 			out += g.Tiles[row][col].paddedString() + "|"
 		}
 		out += "\n"
@@ -284,22 +464,34 @@ func (g *Grid) Debug() string {
 	return out
 }
 
-// clone returns a deep copy for debugging purposes.
-func (g *Grid) clone() *Grid {
-	newGrid := &Grid{}
-	for a := range gridHeight {
-		for b := range gridWidth {
-			newGrid.Tiles[a][b] = g.Tiles[a][b]
-		}
+// Clone returns a deep copy of the grid, including the state of its source of
+// randomness.
+func (g *Grid) Clone() *Grid {
+	newGrid := &Grid{
+		Tiles:    make([][]Tile, len(g.Tiles)),
+		Seed:     g.Seed,
+		Specials: g.Specials,
+		LastMove: g.LastMove,
+	}
+	for a := range g.Tiles {
+		newGrid.Tiles[a] = make([]Tile, len(g.Tiles[a]))
+		copy(newGrid.Tiles[a], g.Tiles[a])
+	}
+	if g.RNG != nil {
+		newGrid.RNG = g.RNG.Clone()
 	}
 	return newGrid
 }
 
 // transpose returns a transposed version of the grid.
-func transpose(matrix [gridWidth][gridHeight]Tile) [gridHeight][gridWidth]Tile {
-	var transposed [gridHeight][gridWidth]Tile
-	for i := range gridWidth {
-		for j := range gridHeight {
+func transpose(matrix [][]Tile) [][]Tile {
+	if len(matrix) == 0 {
+		return [][]Tile{}
+	}
+	transposed := make([][]Tile, len(matrix[0]))
+	for j := range transposed {
+		transposed[j] = make([]Tile, len(matrix))
+		for i := range matrix {
 			transposed[j][i] = matrix[i][j]
 		}
 	}
@@ -310,15 +502,18 @@ const emptyTile = 0
 
 // Tile represents a single tile on the grid.
 type Tile struct {
-	Val  int       `json:"val"`  // the value of the number on the tile
-	Cmb  bool      `json:"cmb"`  // flag for whether tile was combined in the current turn
-	UUID uuid.UUID `json:"uuid"` // unique ID for each tile
+	Val  int       `json:"val"`            // the value of the number on the tile
+	Cmb  bool      `json:"cmb"`            // flag for whether tile was combined in the current turn
+	UUID uuid.UUID `json:"uuid"`           // unique ID for each tile
+	Kind TileKind  `json:"kind,omitempty"` // how the tile behaves
+	Lock int       `json:"lock,omitempty"` // the number of moves until a blocker tile unlocks
 }
 
-// NewTiles generates a fresh set of tiles.
-func NewTiles() [gridHeight][gridWidth]Tile {
-	t := [gridHeight][gridWidth]Tile{}
+// NewTiles generates a fresh set of tiles with the given number of columns and rows.
+func NewTiles(width, height int) [][]Tile {
+	t := make([][]Tile, height)
 	for i := range t {
+		t[i] = make([]Tile, width)
 		for j := range t[i] {
 			t[i][j].UUID = uuid.Must(uuid.NewV7())
 		}
@@ -351,13 +546,21 @@ func (t *Tile) paddedString() string {
 func (t *Tile) Equal(t2 Tile) bool {
 	return t.Val == t2.Val &&
 		t.Cmb == t2.Cmb &&
-		t.UUID == t2.UUID
+		t.UUID == t2.UUID &&
+		t.Kind == t2.Kind &&
+		t.Lock == t2.Lock
 }
 
 // EqualGrid returns whether grid g1 is equal to g2.
-func EqualGrid(g1, g2 [gridWidth][gridHeight]Tile) bool {
-	for i := range gridWidth {
-		for j := range gridHeight {
+func EqualGrid(g1, g2 [][]Tile) bool {
+	if len(g1) != len(g2) {
+		return false
+	}
+	for i := range g1 {
+		if len(g1[i]) != len(g2[i]) {
+			return false
+		}
+		for j := range g1[i] {
 			if !g1[i][j].Equal(g2[i][j]) {
 				return false
 			}
@@ -367,8 +570,8 @@ func EqualGrid(g1, g2 [gridWidth][gridHeight]Tile) bool {
 }
 
 // newTileVal generates the value of a new tile.
-func newTileVal() int {
-	if rand.Float64() >= 0.9 {
+func newTileVal(rng *RNG) int {
+	if rng.Float64() >= 0.9 {
 		return 4
 	}
 	return 2
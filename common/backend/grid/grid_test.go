@@ -1,13 +1,14 @@
 package grid
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 )
 
 func TestMove(t *testing.T) {
 	input := Grid{
-		Tiles: [4][4]Tile{
+		Tiles: [][]Tile{
 			{{Val: 0}, {Val: 2}, {Val: 2}, {Val: 2}},
 			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
 			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
@@ -16,7 +17,7 @@ func TestMove(t *testing.T) {
 	}
 	dir := DirRight
 	expected := Grid{
-		Tiles: [4][4]Tile{
+		Tiles: [][]Tile{
 			{{Val: 0}, {Val: 0}, {Val: 2}, {Val: 4, Cmb: true}},
 			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
 			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
@@ -24,114 +25,208 @@ func TestMove(t *testing.T) {
 		},
 	}
 
-	got := input.clone()
+	got := input.Clone()
 	got.move(dir)
 	if !gridsAreEqual(expected.Tiles, got.Tiles) {
 		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", expected.Debug(), got.Debug())
 	}
 }
 
+func TestMoveNonSquare(t *testing.T) {
+	input := Grid{
+		Tiles: [][]Tile{
+			{{Val: 2}, {Val: 0}, {Val: 0}},
+			{{Val: 2}, {Val: 4}, {Val: 0}},
+			{{Val: 0}, {Val: 4}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 8}},
+			{{Val: 0}, {Val: 0}, {Val: 0}},
+		},
+	}
+	dir := DirDown
+	expected := Grid{
+		Tiles: [][]Tile{
+			{{Val: 0}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}},
+			{{Val: 4, Cmb: true}, {Val: 8, Cmb: true}, {Val: 8}},
+		},
+	}
+
+	got := input.Clone()
+	got.move(dir)
+	if !gridsAreEqual(expected.Tiles, got.Tiles) {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", expected.Debug(), got.Debug())
+	}
+}
+
+func TestNewGridWithSize(t *testing.T) {
+	for _, tc := range []struct{ width, height int }{
+		{3, 3}, {4, 4}, {4, 6}, {6, 4}, {8, 8},
+	} {
+		g := NewGridWithSize(tc.width, tc.height, 1)
+		if g.Width() != tc.width || g.Height() != tc.height {
+			t.Errorf("Expected:\n<%dx%d>\nGot:\n<%dx%d>", tc.width, tc.height, g.Width(), g.Height())
+		}
+		if g.NumTiles() != 2 {
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 2, g.NumTiles())
+		}
+
+		// Every move must keep the dimensions intact
+		for _, dir := range []Direction{DirUp, DirLeft, DirDown, DirRight} {
+			g.Move(dir)
+			if g.Width() != tc.width || g.Height() != tc.height {
+				t.Errorf("Expected:\n<%dx%d>\nGot:\n<%dx%d>", tc.width, tc.height, g.Width(), g.Height())
+			}
+		}
+	}
+}
+
+func TestNewGridWithSeed(t *testing.T) {
+	moves := []Direction{DirLeft, DirUp, DirRight, DirDown, DirLeft, DirUp, DirUp, DirRight}
+
+	g1 := NewGridWithSeed(42)
+	g2 := NewGridWithSeed(42)
+	if !gridsAreEqual(g1.Tiles, g2.Tiles) {
+		t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", g1.Debug(), g2.Debug())
+	}
+
+	for _, dir := range moves {
+		points1 := g1.Move(dir).Points
+		points2 := g2.Move(dir).Points
+		if points1 != points2 {
+			t.Errorf("Expected:\n<%v>\nGot:\n<%v>", points1, points2)
+		}
+		if !gridsAreEqual(g1.Tiles, g2.Tiles) {
+			t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", g1.Debug(), g2.Debug())
+		}
+	}
+}
+
+func TestRNGSerialisation(t *testing.T) {
+	g1 := NewGridWithSeed(7)
+	g1.Move(DirLeft)
+
+	// A grid loaded mid-game should continue to spawn the same tiles
+	b, err := json.Marshal(g1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g2 := &Grid{}
+	if err := json.Unmarshal(b, g2); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []Direction{DirUp, DirRight, DirDown, DirLeft} {
+		g1.Move(dir)
+		g2.Move(dir)
+		if !gridsAreEqual(g1.Tiles, g2.Tiles) {
+			t.Fatalf("Expected:\n<%v>\nGot:\n<%v>", g1.Debug(), g2.Debug())
+		}
+	}
+}
+
 func TestMoveStep(t *testing.T) {
 	type tc struct {
-		input    [4]Tile
+		input    []Tile
 		dir      Direction
-		expected [4]Tile
+		expected []Tile
 		moved    bool
 	}
 
 	for n, tc := range []tc{
 		// 2 2 2 2 --[left]--> 4 4 0 0
 		{
-			input:    [4]Tile{{Val: 2}, {Val: 2}, {Val: 2}, {Val: 2}},
+			input:    []Tile{{Val: 2}, {Val: 2}, {Val: 2}, {Val: 2}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4, Cmb: true}, {Val: 0}, {Val: 2}, {Val: 2}},
+			expected: []Tile{{Val: 4, Cmb: true}, {Val: 0}, {Val: 2}, {Val: 2}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 4, Cmb: true}, {Val: 0}, {Val: 2}, {Val: 2}},
+			input:    []Tile{{Val: 4, Cmb: true}, {Val: 0}, {Val: 2}, {Val: 2}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 0}, {Val: 2}},
+			expected: []Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 0}, {Val: 2}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 0}, {Val: 2}},
+			input:    []Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 0}, {Val: 2}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 2}, {Val: 0}},
+			expected: []Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 2}, {Val: 0}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 2}, {Val: 0}},
+			input:    []Tile{{Val: 4, Cmb: true}, {Val: 2}, {Val: 2}, {Val: 0}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4, Cmb: true}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
+			expected: []Tile{{Val: 4, Cmb: true}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
 			moved:    true,
 		},
 		// 0 4 2 2 --[left]--> 4 4 0 0
 		{
-			input:    [4]Tile{{Val: 0}, {Val: 4}, {Val: 2}, {Val: 2}},
+			input:    []Tile{{Val: 0}, {Val: 4}, {Val: 2}, {Val: 2}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4}, {Val: 0}, {Val: 2}, {Val: 2}},
+			expected: []Tile{{Val: 4}, {Val: 0}, {Val: 2}, {Val: 2}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 4}, {Val: 0}, {Val: 2}, {Val: 2}},
+			input:    []Tile{{Val: 4}, {Val: 0}, {Val: 2}, {Val: 2}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4}, {Val: 2}, {Val: 0}, {Val: 2}},
+			expected: []Tile{{Val: 4}, {Val: 2}, {Val: 0}, {Val: 2}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 4}, {Val: 2}, {Val: 0}, {Val: 2}},
+			input:    []Tile{{Val: 4}, {Val: 2}, {Val: 0}, {Val: 2}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4}, {Val: 2}, {Val: 2}, {Val: 0}},
+			expected: []Tile{{Val: 4}, {Val: 2}, {Val: 2}, {Val: 0}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 4}, {Val: 2}, {Val: 2}, {Val: 0}},
+			input:    []Tile{{Val: 4}, {Val: 2}, {Val: 2}, {Val: 0}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
+			expected: []Tile{{Val: 4}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 4}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
+			input:    []Tile{{Val: 4}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
 			dir:      DirLeft,
-			expected: [4]Tile{{Val: 4}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
+			expected: []Tile{{Val: 4}, {Val: 4, Cmb: true}, {Val: 0}, {Val: 0}},
 			moved:    false,
 		},
 		// // 2 2 2 2 --[right]--> 4 4 0 0
 		{
-			input:    [4]Tile{{Val: 2}, {Val: 2}, {Val: 2}, {Val: 2}},
+			input:    []Tile{{Val: 2}, {Val: 2}, {Val: 2}, {Val: 2}},
 			dir:      DirRight,
-			expected: [4]Tile{{Val: 2}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
+			expected: []Tile{{Val: 2}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 2}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
+			input:    []Tile{{Val: 2}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
 			dir:      DirRight,
-			expected: [4]Tile{{Val: 2}, {Val: 0}, {Val: 2}, {Val: 4, Cmb: true}},
+			expected: []Tile{{Val: 2}, {Val: 0}, {Val: 2}, {Val: 4, Cmb: true}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 2}, {Val: 0}, {Val: 2}, {Val: 4, Cmb: true}},
+			input:    []Tile{{Val: 2}, {Val: 0}, {Val: 2}, {Val: 4, Cmb: true}},
 			dir:      DirRight,
-			expected: [4]Tile{{Val: 0}, {Val: 2}, {Val: 2}, {Val: 4, Cmb: true}},
+			expected: []Tile{{Val: 0}, {Val: 2}, {Val: 2}, {Val: 4, Cmb: true}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 0}, {Val: 2}, {Val: 2}, {Val: 4, Cmb: true}},
+			input:    []Tile{{Val: 0}, {Val: 2}, {Val: 2}, {Val: 4, Cmb: true}},
 			dir:      DirRight,
-			expected: [4]Tile{{Val: 0}, {Val: 0}, {Val: 4, Cmb: true}, {Val: 4, Cmb: true}},
+			expected: []Tile{{Val: 0}, {Val: 0}, {Val: 4, Cmb: true}, {Val: 4, Cmb: true}},
 			moved:    true,
 		},
 		// // 0 2 2 2 --[right]--> 0 0 2 4
 		{
-			input:    [4]Tile{{Val: 0}, {Val: 2}, {Val: 2}, {Val: 2}},
+			input:    []Tile{{Val: 0}, {Val: 2}, {Val: 2}, {Val: 2}},
 			dir:      DirRight,
-			expected: [4]Tile{{Val: 0}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
+			expected: []Tile{{Val: 0}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
 			moved:    true,
 		},
 		{
-			input:    [4]Tile{{Val: 0}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
+			input:    []Tile{{Val: 0}, {Val: 2}, {Val: 0}, {Val: 4, Cmb: true}},
 			dir:      DirRight,
-			expected: [4]Tile{{Val: 0}, {Val: 0}, {Val: 2}, {Val: 4, Cmb: true}},
+			expected: []Tile{{Val: 0}, {Val: 0}, {Val: 2}, {Val: 4, Cmb: true}},
 			moved:    true,
 		},
 	} {
@@ -146,13 +241,13 @@ func TestMoveStep(t *testing.T) {
 }
 
 func TestTranspose(t *testing.T) {
-	input := [4][4]Tile{
+	input := [][]Tile{
 		{{Val: 1}, {Val: 2}, {Val: 3}, {Val: 4}},
 		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
 		{{Val: 6}, {Val: 0}, {Val: 0}, {Val: 0}},
 		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 5}},
 	}
-	expected := [4][4]Tile{
+	expected := [][]Tile{
 		{{Val: 1}, {Val: 0}, {Val: 6}, {Val: 0}},
 		{{Val: 2}, {Val: 0}, {Val: 0}, {Val: 0}},
 		{{Val: 3}, {Val: 0}, {Val: 0}, {Val: 0}},
@@ -164,6 +259,22 @@ func TestTranspose(t *testing.T) {
 	}
 }
 
+func TestTransposeNonSquare(t *testing.T) {
+	input := [][]Tile{
+		{{Val: 1}, {Val: 2}, {Val: 3}},
+		{{Val: 4}, {Val: 5}, {Val: 6}},
+	}
+	expected := [][]Tile{
+		{{Val: 1}, {Val: 4}},
+		{{Val: 2}, {Val: 5}},
+		{{Val: 3}, {Val: 6}},
+	}
+	got := transpose(input)
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("\nExpected:\n<%v>\nGot:\n<%v>", expected, got)
+	}
+}
+
 func TestIsLoss(t *testing.T) {
 	type tc struct {
 		input    Grid
@@ -173,7 +284,7 @@ func TestIsLoss(t *testing.T) {
 	tests := []tc{
 		{
 			input: Grid{
-				Tiles: [4][4]Tile{
+				Tiles: [][]Tile{
 					{{Val: 2}, {Val: 0}, {Val: 8}, {Val: 0}},
 					{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
 					{{Val: 0}, {Val: 4}, {Val: 0}, {Val: 0}},
@@ -184,7 +295,7 @@ func TestIsLoss(t *testing.T) {
 		},
 		{
 			input: Grid{
-				Tiles: [4][4]Tile{
+				Tiles: [][]Tile{
 					{{Val: 4}, {Val: 4}, {Val: 2}, {Val: 4}},
 					{{Val: 4}, {Val: 2}, {Val: 4}, {Val: 2}},
 					{{Val: 2}, {Val: 4}, {Val: 2}, {Val: 4}},
@@ -195,7 +306,7 @@ func TestIsLoss(t *testing.T) {
 		},
 		{
 			input: Grid{
-				Tiles: [4][4]Tile{
+				Tiles: [][]Tile{
 					{{Val: 2}, {Val: 4}, {Val: 2}, {Val: 4}},
 					{{Val: 4}, {Val: 2}, {Val: 4}, {Val: 2}},
 					{{Val: 2}, {Val: 4}, {Val: 2}, {Val: 4}},
@@ -206,7 +317,7 @@ func TestIsLoss(t *testing.T) {
 		},
 		{
 			input: Grid{
-				Tiles: [4][4]Tile{
+				Tiles: [][]Tile{
 					{{Val: 2}, {Val: 4}, {Val: 16}, {Val: 2}},
 					{{Val: 8}, {Val: 32}, {Val: 64}, {Val: 16}},
 					{{Val: 4}, {Val: 16}, {Val: 8}, {Val: 4}},
@@ -217,7 +328,7 @@ func TestIsLoss(t *testing.T) {
 		},
 		{
 			input: Grid{
-				Tiles: [4][4]Tile{
+				Tiles: [][]Tile{
 					{{Val: 4}, {Val: 16}, {Val: 4}, {Val: 2}},
 					{{Val: 2}, {Val: 32}, {Val: 4}, {Val: 2}},
 					{{Val: 4}, {Val: 8}, {Val: 4}, {Val: 2}},
@@ -235,8 +346,32 @@ func TestIsLoss(t *testing.T) {
 	}
 }
 
+func TestOutcomeWithTarget(t *testing.T) {
+	g := Grid{
+		Tiles: [][]Tile{
+			{{Val: 256}, {Val: 0}, {Val: 8}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 4}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		},
+	}
+	for _, tc := range []struct {
+		target int
+		want   Outcome
+	}{
+		{target: 128, want: Win},
+		{target: 256, want: Win},
+		{target: 512, want: None},
+		{target: DefaultTarget, want: None},
+	} {
+		if got := g.OutcomeWithTarget(tc.target); got != tc.want {
+			t.Errorf("Target %d: expected <%v>, got <%v>", tc.target, tc.want, got)
+		}
+	}
+}
+
 // gridsAreEqual checks whether grids are equal, ignoring the UUID fields of tiles.
-func gridsAreEqual(grid1, grid2 [4][4]Tile) bool {
+func gridsAreEqual(grid1, grid2 [][]Tile) bool {
 	for i := range grid1 {
 		if !rowsAreEqual(grid1[i], grid2[i]) {
 			return false
@@ -246,7 +381,7 @@ func gridsAreEqual(grid1, grid2 [4][4]Tile) bool {
 }
 
 // rowsAreEqual checks whether rows of tiles are equal, ignoring the UUID fields.
-func rowsAreEqual(row1, row2 [4]Tile) bool {
+func rowsAreEqual(row1, row2 []Tile) bool {
 	for i := range row1 {
 		if row1[i].Val != row2[i].Val ||
 			row1[i].Cmb != row2[i].Cmb {
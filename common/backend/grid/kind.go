@@ -0,0 +1,146 @@
+package grid
+
+import (
+	"github.com/google/uuid"
+)
+
+// TileKind is a type of tile, which decides how the tile behaves during a move.
+type TileKind string
+
+const (
+	// TileNormal is a number tile which slides and combines.
+	TileNormal TileKind = ""
+	// TileBlocker is a locked tile which can't slide or combine. It becomes a
+	// normal tile once its lock has counted down.
+	TileBlocker TileKind = "blocker"
+	// TileWall is an immovable tile with no number which nothing combines with.
+	TileWall TileKind = "wall"
+	// TileWildcard is a tile with no number which combines with any number tile,
+	// doubling it.
+	TileWildcard TileKind = "wildcard"
+	// TileBomb is a tile with no number which combines with any tile. The
+	// combined tile explodes, clearing itself and its neighbours.
+	TileBomb TileKind = "bomb"
+	// TileDoubling is a number tile which doubles the value of the tile it
+	// combines into.
+	TileDoubling TileKind = "doubling"
+)
+
+const (
+	// specialChance is the chance of a spawned tile being special, when special
+	// tiles are enabled.
+	specialChance = 0.05
+	// minWallSpaces is the smallest grid which gets a wall at the start of a game,
+	// when special tiles are enabled.
+	minWallSpaces = 16
+)
+
+// Empty returns whether there is no tile in the space.
+func (t Tile) Empty() bool {
+	return t.Val == emptyTile && t.Kind == TileNormal
+}
+
+// Locked returns whether the tile is a blocker which can't slide or combine.
+func (t Tile) Locked() bool {
+	return t.Kind == TileBlocker
+}
+
+// Movable returns whether the tile slides when the grid is moved.
+func (t Tile) Movable() bool {
+	return !t.Empty() && !t.Locked() && t.Kind != TileWall
+}
+
+// combine returns the tile made by sliding tile src into tile dst. Returns false if
+// the tiles can't combine. A bomb combines with anything and leaves an exploding
+// bomb tile behind, which is cleared at the end of the move.
+func combine(src, dst Tile) (Tile, bool) {
+	switch {
+	case src.Empty() || dst.Empty() || src.Kind == TileWall || dst.Kind == TileWall:
+		return Tile{}, false
+	case src.Kind == TileBomb || dst.Kind == TileBomb:
+		return Tile{Kind: TileBomb}, true
+	case src.Locked() || dst.Locked():
+		return Tile{}, false
+	}
+
+	var val int
+	switch {
+	case src.Kind == TileWildcard && dst.Kind == TileWildcard:
+		return Tile{}, false
+	case src.Kind == TileWildcard:
+		val = dst.Val * 2
+	case dst.Kind == TileWildcard:
+		val = src.Val * 2
+	case src.Val == dst.Val:
+		val = src.Val * 2
+	default:
+		return Tile{}, false
+	}
+	if src.Kind == TileDoubling || dst.Kind == TileDoubling {
+		val *= 2
+	}
+	return Tile{Val: val}, true
+}
+
+// canCombine returns whether two neighbouring tiles can combine, by moving either
+// one into the other.
+func canCombine(a, b Tile) bool {
+	if _, ok := combine(a, b); ok && a.Movable() {
+		return true
+	}
+	_, ok := combine(b, a)
+	return ok && b.Movable()
+}
+
+// newTileKind generates the kind of a new tile when special tiles are enabled.
+func newTileKind(rng *RNG) TileKind {
+	if rng.Float64() >= specialChance {
+		return TileNormal
+	}
+	return []TileKind{TileWildcard, TileBomb, TileDoubling}[rng.IntN(3)]
+}
+
+// placeWall places a wall in a random empty position.
+func (g *Grid) placeWall(rng *RNG) {
+	x, y := rng.IntN(g.Width()), rng.IntN(g.Height())
+	for !g.Tiles[y][x].Empty() {
+		// Try again until the position is empty
+		x, y = rng.IntN(g.Width()), rng.IntN(g.Height())
+	}
+	g.Tiles[y][x].Kind = TileWall
+}
+
+// detonate clears every bomb which exploded during a move, along with the tiles
+// next to it. Walls can't be cleared. Returns the tiles which were cleared.
+func (g *Grid) detonate() []Clear {
+	var bombs []Pos
+	for y := range g.Tiles {
+		for x := range g.Tiles[y] {
+			if t := g.Tiles[y][x]; t.Kind == TileBomb && t.Cmb {
+				bombs = append(bombs, Pos{X: x, Y: y})
+			}
+		}
+	}
+
+	var clears []Clear
+	for _, bomb := range bombs {
+		for _, pos := range []Pos{
+			bomb,
+			{X: bomb.X - 1, Y: bomb.Y},
+			{X: bomb.X + 1, Y: bomb.Y},
+			{X: bomb.X, Y: bomb.Y - 1},
+			{X: bomb.X, Y: bomb.Y + 1},
+		} {
+			if pos.X < 0 || pos.Y < 0 || pos.X >= g.Width() || pos.Y >= g.Height() {
+				continue
+			}
+			t := g.Tiles[pos.Y][pos.X]
+			if t.Empty() || t.Kind == TileWall {
+				continue
+			}
+			clears = append(clears, Clear{UUID: t.UUID, Val: t.Val, Kind: t.Kind, Pos: pos})
+			g.Tiles[pos.Y][pos.X] = Tile{UUID: uuid.Must(uuid.NewV7())}
+		}
+	}
+	return clears
+}
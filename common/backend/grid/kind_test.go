@@ -0,0 +1,137 @@
+package grid
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCombine(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		src, dst Tile
+		want     Tile
+		ok       bool
+	}{
+		{name: "equal numbers", src: Tile{Val: 4}, dst: Tile{Val: 4}, want: Tile{Val: 8}, ok: true},
+		{name: "different numbers", src: Tile{Val: 4}, dst: Tile{Val: 2}},
+		{name: "wall", src: Tile{Val: 2}, dst: Tile{Kind: TileWall}},
+		{name: "wildcard", src: Tile{Kind: TileWildcard}, dst: Tile{Val: 16}, want: Tile{Val: 32}, ok: true},
+		{name: "two wildcards", src: Tile{Kind: TileWildcard}, dst: Tile{Kind: TileWildcard}},
+		{name: "doubling", src: Tile{Val: 2, Kind: TileDoubling}, dst: Tile{Val: 2}, want: Tile{Val: 8}, ok: true},
+		{name: "doubling into wildcard", src: Tile{Val: 4, Kind: TileDoubling}, dst: Tile{Kind: TileWildcard}, want: Tile{Val: 16}, ok: true},
+		{name: "bomb", src: Tile{Kind: TileBomb}, dst: Tile{Val: 64}, want: Tile{Kind: TileBomb}, ok: true},
+		{name: "bomb into blocker", src: Tile{Kind: TileBomb}, dst: Tile{Val: 2, Kind: TileBlocker}, want: Tile{Kind: TileBomb}, ok: true},
+		{name: "bomb into wall", src: Tile{Kind: TileBomb}, dst: Tile{Kind: TileWall}},
+		{name: "blocker", src: Tile{Val: 2}, dst: Tile{Val: 2, Kind: TileBlocker}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := combine(tc.src, tc.dst)
+			if ok != tc.ok || got != tc.want {
+				t.Errorf("Expected %v (%v), got %v (%v)", tc.want, tc.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestSpecialTiles(t *testing.T) {
+	g := NewGridWithSize(4, 4, 1)
+	g.Tiles = [][]Tile{
+		{{Val: 2}, {Kind: TileWall}, {Val: 0}, {Val: 2}},
+		{{Val: 0}, {Kind: TileWildcard}, {Val: 0}, {Val: 8}},
+		{{Val: 4}, {Val: 16}, {Val: 2}, {Kind: TileBomb}},
+		{{Val: 0}, {Val: 0}, {Val: 2, Kind: TileDoubling}, {Val: 2}},
+	}
+	res := g.move(DirLeft)
+	want := [][]Tile{
+		{{Val: 2}, {Kind: TileWall}, {Val: 2}, {Val: 0}},
+		{{Val: 16}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 4}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 8}, {Val: 0}, {Val: 0}, {Val: 0}},
+	}
+	for y := range want {
+		for x := range want[y] {
+			if g.Tiles[y][x].Val != want[y][x].Val || g.Tiles[y][x].Kind != want[y][x].Kind {
+				t.Fatalf("Expected:\n%v\nGot:\n%s", want, g.Debug())
+			}
+		}
+	}
+
+	// The bomb is cleared along with the tile next to it
+	if len(res.Clears) != 2 {
+		t.Errorf("Expected 2 cleared tiles, got %v", res.Clears)
+	}
+	if res.Points != 16+8 {
+		t.Errorf("Expected 24 points, got %d", res.Points)
+	}
+
+	// A wall is never cleared, and can't move
+	g.Tiles = [][]Tile{
+		{{Kind: TileWall}, {Kind: TileBomb}, {Val: 2}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+	}
+	res = g.move(DirLeft)
+	if g.Tiles[0][0].Kind != TileWall || !g.Tiles[0][1].Empty() || len(res.Clears) != 1 {
+		t.Errorf("Expected only the bomb to be cleared, got:\n%s\n%v", g.Debug(), res.Clears)
+	}
+}
+
+func TestSpecialTilesCantCombine(t *testing.T) {
+	// The only tiles which could combine are next to a wall, so the grid is lost
+	g := Grid{
+		Tiles: [][]Tile{
+			{{Kind: TileWall}, {Val: 2}, {Val: 4}, {Val: 2}},
+			{{Val: 4}, {Val: 8}, {Val: 2}, {Val: 4}},
+			{{Val: 2}, {Val: 4}, {Val: 8}, {Val: 2}},
+			{{Val: 4}, {Val: 2}, {Val: 4}, {Val: 8}},
+		},
+	}
+	if !g.isLoss() {
+		t.Error("Expected a grid with a wall and no possible combinations to be lost")
+	}
+	if _, ok := BitboardFromTiles(g.Tiles); ok {
+		t.Error("Expected a grid with a wall to not fit on a bitboard")
+	}
+
+	// A wildcard can combine with any of its neighbours
+	g.Tiles[0][0] = Tile{Kind: TileWildcard}
+	if g.isLoss() {
+		t.Error("Expected a grid with a wildcard to not be lost")
+	}
+}
+
+func TestSpecialTilesJSON(t *testing.T) {
+	g1 := NewGridWithSize(4, 4, 3)
+	g1.Specials = true
+	g1.Reset()
+
+	walls := 0
+	for y := range g1.Tiles {
+		for x := range g1.Tiles[y] {
+			switch {
+			case g1.Tiles[y][x].Kind == TileWall:
+				walls++
+			case g1.Tiles[y][x].Empty() && x%2 == 0:
+				g1.Tiles[y][x].Kind = TileBomb
+			case g1.Tiles[y][x].Empty():
+				g1.Tiles[y][x] = Tile{Val: 4, Kind: TileDoubling, UUID: g1.Tiles[y][x].UUID}
+			}
+		}
+	}
+	if walls != 1 {
+		t.Errorf("Expected a new game with special tiles to have 1 wall, got %d", walls)
+	}
+
+	b, err := json.Marshal(g1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g2 := &Grid{}
+	if err := json.Unmarshal(b, g2); err != nil {
+		t.Fatal(err)
+	}
+	if !EqualGrid(g1.Tiles, g2.Tiles) || !g2.Specials {
+		t.Errorf("Expected:\n%s\nGot:\n%s", g1.Debug(), g2.Debug())
+	}
+}
@@ -0,0 +1,151 @@
+package grid
+
+import (
+	"github.com/google/uuid"
+)
+
+// Pos is a position on the grid. Position {0,0} is the top left square.
+type Pos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// MoveResult describes everything which happened during a move.
+type MoveResult struct {
+	Dir    Direction  `json:"dir"`
+	Moved  bool       `json:"moved"`           // whether any tiles moved
+	Moves  []TileMove `json:"moves"`           // tiles which slid to a new position without combining
+	Merges []Merge    `json:"merges"`          // pairs of tiles which combined
+	Points int        `json:"points"`          // the points gained from combining tiles
+	Spawn  *Spawn     `json:"spawn,omitempty"` // the tile spawned after the move, if any
+
+	// Clears are the tiles which were cleared by exploding bombs after the move.
+	Clears []Clear `json:"clears,omitempty"`
+	// Unlocks are the blocker tiles which became normal tiles after the move.
+	Unlocks []Unlock `json:"unlocks,omitempty"`
+}
+
+// TileMove describes a tile sliding from one position to another.
+type TileMove struct {
+	UUID uuid.UUID `json:"uuid"`
+	Val  int       `json:"val"`
+	Kind TileKind  `json:"kind,omitempty"`
+	From Pos       `json:"from"`
+	To   Pos       `json:"to"`
+}
+
+// Merge describes two tiles combining into a new tile.
+type Merge struct {
+	Sources [2]TileMove `json:"sources"` // the tiles which combined, in the order they were met
+	UUID    uuid.UUID   `json:"uuid"`    // the identity of the new tile
+	Val     int         `json:"val"`     // the value of the new tile
+	Kind    TileKind    `json:"kind,omitempty"`
+	To      Pos         `json:"to"`
+}
+
+// Clear describes a tile being cleared by an exploding bomb. The bomb itself is
+// cleared too.
+type Clear struct {
+	UUID uuid.UUID `json:"uuid"`
+	Val  int       `json:"val"`
+	Kind TileKind  `json:"kind,omitempty"`
+	Pos  Pos       `json:"pos"`
+}
+
+// Unlock describes a blocker tile becoming a normal tile. The tile keeps its
+// identity and position.
+type Unlock struct {
+	UUID uuid.UUID `json:"uuid"`
+	Val  int       `json:"val"`
+	Pos  Pos       `json:"pos"`
+}
+
+// linePos returns the grid position of a step along a line of a grid with the
+// given size. Step 0 is at the edge which the tiles are moving towards.
+func linePos(dir Direction, width, height, line, step int) Pos {
+	switch dir {
+	case DirLeft:
+		return Pos{X: step, Y: line}
+	case DirRight:
+		return Pos{X: width - 1 - step, Y: line}
+	case DirUp:
+		return Pos{X: line, Y: step}
+	default:
+		return Pos{X: line, Y: height - 1 - step}
+	}
+}
+
+// lineCount returns the number of lines and the length of each line when moving
+// in the given direction.
+func lineCount(dir Direction, width, height int) (int, int) {
+	if dir == DirUp || dir == DirDown {
+		return width, height
+	}
+	return height, width
+}
+
+// describeMove works out which tiles moved and combined by comparing the tiles
+// before and after a move. Tiles keep their order along each line, and every
+// combined tile takes the next two tiles in the direction of the move, so the
+// tiles before and after can be paired up line by line. The points gained from
+// the move aren't included in the result.
+func describeMove(dir Direction, before, after [][]Tile) MoveResult {
+	res := MoveResult{Dir: dir}
+	height := len(before)
+	if height == 0 {
+		return res
+	}
+	width := len(before[0])
+
+	lines, length := lineCount(dir, width, height)
+	for line := range lines {
+		var from, to []Pos
+		for step := range length {
+			pos := linePos(dir, width, height, line, step)
+			if !before[pos.Y][pos.X].Empty() {
+				from = append(from, pos)
+			}
+			if !after[pos.Y][pos.X].Empty() {
+				to = append(to, pos)
+			}
+		}
+
+		i := 0
+		for _, dest := range to {
+			tile := after[dest.Y][dest.X]
+			// A combined tile needs two sources. If there aren't enough, the Cmb
+			// flag is out of date, so the tile only moved
+			if !tile.Cmb || i+len(Merge{}.Sources) > len(from) {
+				src := from[i]
+				if src != dest {
+					res.Moves = append(res.Moves, TileMove{
+						UUID: tile.UUID,
+						Val:  tile.Val,
+						Kind: tile.Kind,
+						From: src,
+						To:   dest,
+					})
+				}
+				i++
+				continue
+			}
+
+			merge := Merge{UUID: tile.UUID, Val: tile.Val, Kind: tile.Kind, To: dest}
+			for n := range merge.Sources {
+				src := from[i+n]
+				merge.Sources[n] = TileMove{
+					UUID: before[src.Y][src.X].UUID,
+					Val:  before[src.Y][src.X].Val,
+					Kind: before[src.Y][src.X].Kind,
+					From: src,
+					To:   dest,
+				}
+			}
+			res.Merges = append(res.Merges, merge)
+			i += 2
+		}
+	}
+
+	res.Moved = len(res.Moves) > 0 || len(res.Merges) > 0
+	return res
+}
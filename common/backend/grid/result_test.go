@@ -0,0 +1,125 @@
+package grid
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMoveResult(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		tiles [][]Tile
+	}{
+		{
+			name: "bitboard",
+			tiles: [][]Tile{
+				{{Val: 2}, {Val: 2}, {Val: 4}, {Val: 4}},
+				{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 8}},
+				{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+				{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+			},
+		},
+		{
+			name: "reference",
+			tiles: [][]Tile{
+				{{Val: 2}, {Val: 2}, {Val: 4}, {Val: 4}, {Val: 0}},
+				{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 8}, {Val: 0}},
+				{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := &Grid{Tiles: tc.tiles}
+			for y := range g.Tiles {
+				for x := range g.Tiles[y] {
+					g.Tiles[y][x].UUID = uuid.New()
+				}
+			}
+			before := g.Clone().Tiles
+
+			res := g.move(DirLeft)
+
+			if !res.Moved || res.Dir != DirLeft {
+				t.Errorf("Expected a move left, got <%+v>", res)
+			}
+			// Both pairs in the top row combine, so both are scored
+			if res.Points != 12 {
+				t.Errorf("Expected:\n<%v>\nGot:\n<%v>", 12, res.Points)
+			}
+
+			wantMoves := []TileMove{
+				{UUID: before[1][3].UUID, Val: 8, From: Pos{X: 3, Y: 1}, To: Pos{X: 0, Y: 1}},
+			}
+			if len(res.Moves) != len(wantMoves) || res.Moves[0] != wantMoves[0] {
+				t.Errorf("Expected:\n<%+v>\nGot:\n<%+v>", wantMoves, res.Moves)
+			}
+
+			if len(res.Merges) != 2 {
+				t.Fatalf("Expected 2 merges, got <%+v>", res.Merges)
+			}
+			for i, want := range []struct {
+				val      int
+				to       Pos
+				from     [2]Pos
+				fromUUID [2]uuid.UUID
+			}{
+				{4, Pos{0, 0}, [2]Pos{{0, 0}, {1, 0}}, [2]uuid.UUID{before[0][0].UUID, before[0][1].UUID}},
+				{8, Pos{1, 0}, [2]Pos{{2, 0}, {3, 0}}, [2]uuid.UUID{before[0][2].UUID, before[0][3].UUID}},
+			} {
+				m := res.Merges[i]
+				if m.Val != want.val || m.To != want.to || m.UUID != g.Tiles[want.to.Y][want.to.X].UUID {
+					t.Errorf("Merge %d: expected <%v at %v>, got <%+v>", i, want.val, want.to, m)
+				}
+				for n, src := range m.Sources {
+					if src.From != want.from[n] || src.To != want.to || src.UUID != want.fromUUID[n] || src.Val != want.val/2 {
+						t.Errorf("Merge %d source %d: got <%+v>", i, n, src)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMoveResultSpawn(t *testing.T) {
+	g := NewGridWithSeed(5)
+	for {
+		res := g.Move(DirLeft)
+		if !res.Moved {
+			if res.Spawn != nil {
+				t.Errorf("Expected no spawn without a move, got <%+v>", res.Spawn)
+			}
+			break
+		}
+		if res.Spawn == nil {
+			t.Fatal("Expected a spawn after a move")
+		}
+		if tile := g.Tiles[res.Spawn.Y][res.Spawn.X]; tile.Val != res.Spawn.Val || tile.UUID != res.Spawn.UUID {
+			t.Errorf("Expected:\n<%+v>\nGot:\n<%+v>", res.Spawn, tile)
+		}
+	}
+}
+
+func TestStaleCmbFlags(t *testing.T) {
+	// Flags left over from the previous move don't stop tiles combining
+	g := &Grid{Tiles: [][]Tile{
+		{{Val: 0}, {Val: 2, Cmb: true}, {Val: 2, Cmb: true}, {Val: 0}, {Val: 4, Cmb: true}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+	}}
+	res := g.moveReference(DirLeft)
+	if res.Points != 4 || len(res.Merges) != 1 || len(res.Moves) != 1 {
+		t.Errorf("Expected one combination and one move, got <%+v>", res)
+	}
+	if g.Tiles[0][0].Val != 4 || g.Tiles[0][1].Val != 4 {
+		t.Errorf("Expected the top row to start 4, 4, got:\n%v", g.Debug())
+	}
+
+	// A tile which is flagged as combined without two sources only moved
+	before := [][]Tile{{{Val: 0}, {Val: 8}}}
+	after := [][]Tile{{{Val: 8, Cmb: true}, {Val: 0}}}
+	res = describeMove(DirLeft, before, after)
+	if len(res.Merges) != 0 || len(res.Moves) != 1 {
+		t.Errorf("Expected one move, got <%+v>", res)
+	}
+}
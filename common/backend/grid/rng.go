@@ -0,0 +1,66 @@
+package grid
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+)
+
+// RNG is a seedable source of randomness for a grid. Its state can be serialised,
+// so a saved game continues to spawn the same tiles after it is loaded.
+type RNG struct {
+	pcg *rand.PCG
+}
+
+// NewRNG constructs a new random number generator from a seed.
+func NewRNG(seed int64) *RNG {
+	return &RNG{pcg: rand.NewPCG(uint64(seed), uint64(seed))}
+}
+
+// IntN returns a random int in the range [0,n).
+func (r *RNG) IntN(n int) int {
+	return rand.New(r.pcg).IntN(n)
+}
+
+// Float64 returns a random float64 in the range [0.0,1.0).
+func (r *RNG) Float64() float64 {
+	return rand.New(r.pcg).Float64()
+}
+
+// Int64 returns a random non-negative int64.
+func (r *RNG) Int64() int64 {
+	return rand.New(r.pcg).Int64()
+}
+
+// Clone returns a copy of the random number generator in its current state.
+func (r *RNG) Clone() *RNG {
+	pcg := *r.pcg
+	return &RNG{pcg: &pcg}
+}
+
+// MarshalJSON satisfies json.Marshaler.
+func (r *RNG) MarshalJSON() ([]byte, error) {
+	b, err := r.pcg.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(b)
+}
+
+// UnmarshalJSON satisfies json.Unmarshaler.
+func (r *RNG) UnmarshalJSON(data []byte) error {
+	var b []byte
+	if err := json.Unmarshal(data, &b); err != nil {
+		return err
+	}
+	pcg := &rand.PCG{}
+	if err := pcg.UnmarshalBinary(b); err != nil {
+		return err
+	}
+	r.pcg = pcg
+	return nil
+}
+
+// RandomSeed returns a new seed from the global random source.
+func RandomSeed() int64 {
+	return rand.Int64()
+}
@@ -0,0 +1,205 @@
+package backend
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// ModeType is a set of rules which decide when a game ends.
+type ModeType string
+
+const (
+	// ModeClassic is open-ended play. The game ends once no moves are possible.
+	ModeClassic ModeType = "classic"
+	// ModeTimeAttack is getting the highest score before the time limit.
+	ModeTimeAttack ModeType = "timeAttack"
+	// ModeRace is reaching the target tile in the fastest time.
+	ModeRace ModeType = "race"
+	// ModeMoveLimit is getting the highest score before the move limit.
+	ModeMoveLimit ModeType = "moveLimit"
+)
+
+// Mode contains the rules of a game.
+type Mode struct {
+	Type      ModeType      `json:"type"`
+	TimeLimit time.Duration `json:"timeLimit,omitempty"` // the length of a time attack game
+	Target    int           `json:"target,omitempty"`    // the tile to reach in a race
+	MoveLimit int           `json:"moveLimit,omitempty"` // the number of moves in a move limit game
+}
+
+// ClassicMode returns the rules for open-ended play.
+func ClassicMode() Mode {
+	return Mode{Type: ModeClassic}
+}
+
+// TimeAttackMode returns the rules for getting the highest score in the given time.
+func TimeAttackMode(limit time.Duration) Mode {
+	return Mode{Type: ModeTimeAttack, TimeLimit: limit}
+}
+
+// RaceMode returns the rules for reaching the given tile in the fastest time.
+func RaceMode(target int) Mode {
+	return Mode{Type: ModeRace, Target: target}
+}
+
+// MoveLimitMode returns the rules for getting the highest score in the given
+// number of moves.
+func MoveLimitMode(limit int) Mode {
+	return Mode{Type: ModeMoveLimit, MoveLimit: limit}
+}
+
+// orDefault returns the mode, or classic mode if the mode is unset (e.g. in a
+// save from before modes existed).
+func (m Mode) orDefault() Mode {
+	if m.Type == "" {
+		return ClassicMode()
+	}
+	return m
+}
+
+// Key uniquely identifies the mode, including its settings. Modes with different
+// settings keep separate high scores.
+func (m Mode) Key() string {
+	switch m.Type {
+	case ModeTimeAttack:
+		return fmt.Sprintf("%s-%v", m.Type, m.TimeLimit)
+	case ModeRace:
+		return fmt.Sprintf("%s-%d", m.Type, m.Target)
+	case ModeMoveLimit:
+		return fmt.Sprintf("%s-%d", m.Type, m.MoveLimit)
+	default:
+		return string(ModeClassic)
+	}
+}
+
+// String returns the mode in a short human readable format.
+func (m Mode) String() string {
+	switch m.Type {
+	case ModeTimeAttack:
+		return fmt.Sprintf("%v MIN", m.TimeLimit.Minutes())
+	case ModeRace:
+		return fmt.Sprintf("RACE %d", m.Target)
+	case ModeMoveLimit:
+		return fmt.Sprintf("%d MOVES", m.MoveLimit)
+	default:
+		return "CLASSIC"
+	}
+}
+
+// Outcome returns the current outcome of the game according to its mode. Reaching
+// the goal of a mode wins the game, and running out of moves loses it. A classic
+// game is won by reaching the target tile, and carries on until it is lost.
+func (g *Game) Outcome() grid.Outcome {
+	gridOutcome := g.Grid.OutcomeWithTarget(g.Target)
+	switch g.Mode.Type {
+	case ModeTimeAttack:
+		if g.Timer.Duration() >= g.Mode.TimeLimit {
+			return grid.Win
+		}
+	case ModeRace:
+		if g.Grid.HighestTile() >= g.Mode.Target {
+			return grid.Win
+		}
+	case ModeMoveLimit:
+		if g.Moves >= g.Mode.MoveLimit {
+			return grid.Win
+		}
+	default:
+		return gridOutcome
+	}
+	if gridOutcome == grid.Lose {
+		return grid.Lose
+	}
+	return grid.None
+}
+
+// Over returns whether the game has ended, so no more moves can be made.
+func (g *Game) Over() bool {
+	switch g.Outcome() {
+	case grid.Lose:
+		return true
+	case grid.Win:
+		return g.Mode.Type != ModeClassic
+	default:
+		return false
+	}
+}
+
+// TimeLeft returns the time remaining in a time attack game. Returns zero for
+// other modes.
+func (g *Game) TimeLeft() time.Duration {
+	if g.Mode.Type != ModeTimeAttack {
+		return 0
+	}
+	return max(g.Mode.TimeLimit-g.Timer.Duration(), 0)
+}
+
+// MovesLeft returns the number of moves remaining in a move limit game. Returns
+// zero for other modes.
+func (g *Game) MovesLeft() int {
+	if g.Mode.Type != ModeMoveLimit {
+		return 0
+	}
+	return max(g.Mode.MoveLimit-g.Moves, 0)
+}
+
+// BestTime returns the fastest time the target tile has been reached in the
+// game's mode. Returns false if the target has never been reached, or the game
+// isn't a race.
+func (g *Game) BestTime() (time.Duration, bool) {
+	if g.Mode.Type != ModeRace {
+		return 0, false
+	}
+	best, ok := g.BestTimes[g.Mode.Key()]
+	return best, ok
+}
+
+// SetMode starts a new game with the given mode. The high score shown becomes the
+// high score of the new mode.
+func (g *Game) SetMode(m Mode) *Game {
+	g.Mode = m.orDefault()
+	g.HighScore = g.HighScores[g.Mode.Key()]
+	return g.Reset()
+}
+
+// Tick ends the game if its time limit has run out. It should be called regularly
+// whilst a time attack game is being played, as the time can run out between moves.
+func (g *Game) Tick() {
+	if g.Mode.Type == ModeTimeAttack && !g.Timer.IsPaused() && g.Over() {
+		g.finish()
+		g.autosave()
+	}
+}
+
+// finish stops a game which has ended, recording its result and any new best time.
+func (g *Game) finish() {
+	if g.Mode.Type == ModeTimeAttack {
+		// The timer may have run on since the time ran out
+		g.Timer.Pause().Set(min(g.Timer.Duration(), g.Mode.TimeLimit))
+	} else {
+		g.Timer.Pause()
+	}
+
+	if g.Mode.Type == ModeRace && g.Outcome() == grid.Win && !g.Assisted {
+		if best, ok := g.BestTime(); !ok || g.Timer.Duration() < best {
+			if g.BestTimes == nil {
+				g.BestTimes = make(map[string]time.Duration)
+			}
+			g.BestTimes[g.Mode.Key()] = g.Timer.Duration()
+		}
+	}
+
+	g.saveReplay()
+	g.recordResult()
+}
+
+// setHighScore sets the high score of the game's mode.
+func (g *Game) setHighScore(score int) {
+	g.HighScore = score
+	if g.HighScores == nil {
+		g.HighScores = make(map[string]int)
+	}
+	g.HighScores[g.Mode.Key()] = score
+}
@@ -0,0 +1,161 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// playUntilOver makes moves until the game is over or maxMoves moves have been
+// attempted.
+func playUntilOver(g *Game, maxMoves int) {
+	dirs := []grid.Direction{grid.DirUp, grid.DirLeft, grid.DirDown, grid.DirRight}
+	for i := 0; i < maxMoves && !g.Over(); i++ {
+		g.ExecuteMove(dirs[i%len(dirs)])
+	}
+}
+
+func TestMoveLimitMode(t *testing.T) {
+	game := NewGame(&Opts{Seed: 1, UndoDepth: 4, Mode: MoveLimitMode(5)})
+	playUntilOver(game, 100)
+
+	if game.Moves != 5 || game.MovesLeft() != 0 {
+		t.Fatalf("Expected 5 moves, got %d", game.Moves)
+	}
+	if game.Outcome() != grid.Win || !game.Over() {
+		t.Errorf("Expected game to be won and over, got %v", game.Outcome())
+	}
+	if !game.Timer.IsPaused() {
+		t.Error("Expected timer to be paused")
+	}
+
+	// No more moves can be made
+	before := game.Grid.Clone().Tiles
+	for _, dir := range []grid.Direction{grid.DirUp, grid.DirLeft, grid.DirDown, grid.DirRight} {
+		if res := game.ExecuteMove(dir); res.Moved {
+			t.Fatalf("Expected move %v to be rejected", dir)
+		}
+	}
+	if !grid.EqualGrid(before, game.Grid.Tiles) {
+		t.Error("Expected grid to be unchanged")
+	}
+
+	// Undoing a move gives it back
+	if !game.Undo() || game.MovesLeft() != 1 || game.Over() {
+		t.Errorf("Expected 1 move left after undo, got %d", game.MovesLeft())
+	}
+}
+
+func TestTimeAttackMode(t *testing.T) {
+	const limit = 30 * time.Millisecond
+	game := NewGame(&Opts{Seed: 1, Mode: TimeAttackMode(limit)})
+
+	// The time starts with the first move
+	time.Sleep(limit)
+	if game.Over() || game.TimeLeft() != limit {
+		t.Fatalf("Expected %v left before the first move, got %v", limit, game.TimeLeft())
+	}
+	game.ExecuteMove(grid.DirLeft)
+	game.ExecuteMove(grid.DirUp)
+
+	time.Sleep(limit + 10*time.Millisecond)
+	game.Tick()
+	if !game.Over() || game.TimeLeft() != 0 {
+		t.Fatalf("Expected the time to have run out, got %v left", game.TimeLeft())
+	}
+	if !game.Timer.IsPaused() || game.Timer.Duration() != limit {
+		t.Errorf("Expected timer to stop at %v, got %v", limit, game.Timer.Duration())
+	}
+}
+
+func TestRaceMode(t *testing.T) {
+	for _, assisted := range []bool{false, true} {
+		game := NewGame(&Opts{Seed: 1, Mode: RaceMode(64)})
+		if assisted {
+			game.MarkAssisted()
+		}
+		game.Grid.Tiles = [][]grid.Tile{
+			{{Val: 32}, {Val: 32}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+			{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		}
+
+		if _, ok := game.BestTime(); ok {
+			t.Fatal("Expected no best time before the target is reached")
+		}
+		game.ExecuteMove(grid.DirLeft)
+		if game.Outcome() != grid.Win || !game.Over() {
+			t.Fatalf("Expected game to be won and over, got %v", game.Outcome())
+		}
+
+		best, ok := game.BestTime()
+		if assisted && ok {
+			t.Errorf("Expected assisted game to not set a best time, got %v", best)
+		}
+		if !assisted && (!ok || best != game.Timer.Duration()) {
+			t.Errorf("Expected best time of %v, got %v", game.Timer.Duration(), best)
+		}
+	}
+}
+
+func TestTarget(t *testing.T) {
+	tiles := [][]grid.Tile{
+		{{Val: 128}, {Val: 128}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+		{{Val: 0}, {Val: 0}, {Val: 0}, {Val: 0}},
+	}
+
+	if game := NewGame(&Opts{Seed: 1}); game.Target != grid.DefaultTarget {
+		t.Errorf("Expected default target of %d, got %d", grid.DefaultTarget, game.Target)
+	}
+
+	game := NewGame(&Opts{Seed: 1, Target: 256})
+	game.Grid.Tiles = tiles
+	game.ExecuteMove(grid.DirLeft)
+	if game.Outcome() != grid.Win {
+		t.Fatalf("Expected game to be won, got %v", game.Outcome())
+	}
+	if game.Over() {
+		t.Error("Expected classic game to carry on after reaching the target")
+	}
+
+	// The target is kept when the game is saved and loaded
+	b, err := game.Serialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := Game{}
+	if err := loaded.Deserialise(b); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Target != 256 || loaded.Outcome() != grid.Win {
+		t.Errorf("Expected loaded game to keep target 256, got %d", loaded.Target)
+	}
+}
+
+func TestHighScoresPerMode(t *testing.T) {
+	game := NewGame(&Opts{Seed: 1})
+	game.Score = 1000
+	game.ExecuteMove(grid.DirLeft)
+	game.ExecuteMove(grid.DirUp)
+	classic := game.HighScore
+
+	game.SetMode(MoveLimitMode(10))
+	if game.HighScore != 0 {
+		t.Errorf("Expected new mode to have no high score, got %d", game.HighScore)
+	}
+	playUntilOver(game, 100)
+	moveLimit := game.HighScore
+
+	game.SetMode(ClassicMode())
+	if game.HighScore != classic {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", classic, game.HighScore)
+	}
+	game.SetMode(MoveLimitMode(10))
+	if game.HighScore != moveLimit {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", moveLimit, game.HighScore)
+	}
+}
@@ -0,0 +1,207 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/backend/store"
+)
+
+const (
+	// DefaultSaveFile is the file which games are saved in when no save file is
+	// given. Saves from before profiles existed are kept here.
+	DefaultSaveFile = ".save.bruh"
+	// DefaultProfileName is the name of the profile created when there are none.
+	DefaultProfileName = "Player"
+	// ProfilesDir is the directory within the data directory which profiles are
+	// kept in. Each profile has its own directory, named after its ID.
+	ProfilesDir = "profiles"
+	// MaxProfileNameLen is the maximum number of characters in a profile's name.
+	MaxProfileNameLen = 20
+
+	profileFile        = "profile.bruh"
+	currentProfileFile = ".profile.bruh" // the ID of the selected profile, in the config directory
+)
+
+// Profile is a player of the game. Every profile has its own save file, which
+// keeps their high scores, their own statistics and their own preferences.
+type Profile struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Prefs Prefs  `json:"prefs"`
+}
+
+// Prefs contains a player's preferred settings for the games they host.
+type Prefs struct {
+	Width  int  `json:"width"`
+	Height int  `json:"height"`
+	Target int  `json:"target"`
+	Battle bool `json:"battle"`
+	// Port is the port to host games on. If zero, the default port is used.
+	Port uint16 `json:"port,omitempty"`
+}
+
+// NewProfile creates and saves a new profile with the given name.
+func NewProfile(name string) (*Profile, error) {
+	p := &Profile{
+		ID:   uuid.Must(uuid.NewV7()).String(),
+		Name: cleanProfileName(name),
+		Prefs: Prefs{
+			Width:  grid.DefaultWidth,
+			Height: grid.DefaultHeight,
+			Target: grid.DefaultTarget,
+		},
+	}
+	return p, p.Save()
+}
+
+// LoadProfile loads the profile with the given ID.
+func LoadProfile(id string) (*Profile, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("invalid profile ID %q", id)
+	}
+	b, err := store.NewStore(profilePath(id)).ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	var p Profile
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, err
+	}
+	if p.ID != id {
+		return nil, fmt.Errorf("profile %s has the wrong ID %s", id, p.ID)
+	}
+	if !grid.ValidSize(p.Prefs.Width, p.Prefs.Height) {
+		p.Prefs.Width, p.Prefs.Height = grid.DefaultWidth, grid.DefaultHeight
+	}
+	p.Prefs.Target = targetOrDefault(p.Prefs.Target)
+	return &p, nil
+}
+
+// ListProfiles returns every profile, oldest first. Profiles which can't be loaded
+// are left out.
+func ListProfiles() ([]*Profile, error) {
+	entries, err := os.ReadDir(store.Path(ProfilesDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// IDs are time-ordered, so sorting them sorts the profiles by age
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			ids = append(ids, entry.Name())
+		}
+	}
+	slices.Sort(ids)
+
+	var profiles []*Profile
+	for _, id := range ids {
+		p, err := LoadProfile(id)
+		if err != nil {
+			continue
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// CurrentProfile returns the profile which was last selected. If there are no
+// profiles, a default profile is created which takes over the save from before
+// profiles existed.
+func CurrentProfile() (*Profile, error) {
+	b, err := store.NewStore(store.ConfigPath(currentProfileFile)).ReadBytes()
+	if err == nil {
+		if p, err := LoadProfile(string(b)); err == nil {
+			return p, nil
+		}
+	}
+
+	profiles, err := ListProfiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(profiles) > 0 {
+		return profiles[0], SetCurrentProfile(profiles[0])
+	}
+
+	p, err := NewProfile(DefaultProfileName)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Rename(store.Path(DefaultSaveFile), store.Path(p.SaveFile()))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return p, SetCurrentProfile(p)
+}
+
+// SetCurrentProfile selects the profile which is used next time the game starts.
+func SetCurrentProfile(p *Profile) error {
+	return store.NewStore(store.ConfigPath(currentProfileFile)).SaveBytes([]byte(p.ID))
+}
+
+// Save saves the profile.
+func (p *Profile) Save() error {
+	if p.ID == "" {
+		return errors.New("profile has no ID")
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return store.NewStore(profilePath(p.ID)).SaveBytes(b)
+}
+
+// Rename changes the name of the profile and saves it. Names are trimmed to
+// MaxProfileNameLen characters, and empty names are replaced by the default name.
+func (p *Profile) Rename(name string) error {
+	p.Name = cleanProfileName(name)
+	return p.Save()
+}
+
+// SaveFile returns the file which the profile's game is saved in, relative to the
+// data directory. Profiles which haven't been saved use DefaultSaveFile.
+func (p *Profile) SaveFile() string {
+	if p.ID == "" {
+		return DefaultSaveFile
+	}
+	return filepath.Join(ProfilesDir, p.ID, DefaultSaveFile)
+}
+
+// StatsFile returns the file which the results of the profile's games are recorded
+// in, relative to the data directory. Profiles which haven't been saved use
+// DefaultStatsFile.
+func (p *Profile) StatsFile() string {
+	if p.ID == "" {
+		return DefaultStatsFile
+	}
+	return filepath.Join(ProfilesDir, p.ID, DefaultStatsFile)
+}
+
+// profilePath returns the path of the file containing the profile with the given
+// ID, relative to the data directory.
+func profilePath(id string) string {
+	return filepath.Join(ProfilesDir, id, profileFile)
+}
+
+// cleanProfileName returns a name which can be given to a profile.
+func cleanProfileName(name string) string {
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > MaxProfileNameLen {
+		name = strings.TrimSpace(string(runes[:MaxProfileNameLen]))
+	}
+	if name == "" {
+		return DefaultProfileName
+	}
+	return name
+}
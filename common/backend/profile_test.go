@@ -0,0 +1,98 @@
+package backend
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/z-riley/go-2048-battle/common/backend/store"
+)
+
+func TestProfiles(t *testing.T) {
+	store.SetDir(t.TempDir())
+
+	alice, err := NewProfile("  Alice ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := NewProfile("Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice.Prefs.Battle = true
+	if err := alice.Rename(""); err != nil {
+		t.Fatal(err)
+	}
+	if alice.Name != DefaultProfileName {
+		t.Errorf("Expected an empty name to be replaced by %q, got %q", DefaultProfileName, alice.Name)
+	}
+	if err := bob.Rename(strings.Repeat("b", MaxProfileNameLen+5)); err != nil {
+		t.Fatal(err)
+	}
+	if len(bob.Name) != MaxProfileNameLen {
+		t.Errorf("Expected name to be trimmed to %d characters, got %q", MaxProfileNameLen, bob.Name)
+	}
+
+	got, err := LoadProfile(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *alice {
+		t.Errorf("Expected %+v, got %+v", alice, got)
+	}
+
+	profiles, err := ListProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 || profiles[0].ID != alice.ID || profiles[1].ID != bob.ID {
+		t.Errorf("Expected Alice then Bob, got %+v", profiles)
+	}
+
+	if _, err := LoadProfile("../../.save.bruh"); err == nil {
+		t.Error("Expected an error loading a profile with an invalid ID")
+	}
+}
+
+func TestCurrentProfile(t *testing.T) {
+	store.SetDir(t.TempDir())
+
+	// A save from before profiles existed is given to the default profile
+	legacy := NewGame(&Opts{SaveToDisk: true, Seed: 1})
+	legacy.HighScore = 256
+	if err := legacy.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := CurrentProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != DefaultProfileName {
+		t.Errorf("Expected default profile, got %+v", p)
+	}
+	if _, err := os.Stat(store.Path(DefaultSaveFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected legacy save to be moved, got %v", err)
+	}
+	game := NewGame(&Opts{SaveToDisk: true, SaveFile: p.SaveFile()})
+	if game.HighScore != 256 {
+		t.Errorf("Expected high score of 256 from legacy save, got %d", game.HighScore)
+	}
+
+	// The selected profile is remembered
+	other, err := NewProfile("Other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCurrentProfile(other); err != nil {
+		t.Fatal(err)
+	}
+	p, err = CurrentProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != other.ID {
+		t.Errorf("Expected profile %s, got %s", other.ID, p.ID)
+	}
+}
@@ -0,0 +1,234 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/backend/store"
+)
+
+const (
+	// ReplayDir is the directory within the data directory which replays are
+	// saved to.
+	ReplayDir = "replays"
+	// ReplayExt is the file extension of saved replays.
+	ReplayExt = ".replay"
+)
+
+// ActionType is the kind of player input recorded in a replay.
+type ActionType string
+
+const (
+	ActionMove ActionType = "move"
+	ActionUndo ActionType = "undo"
+	ActionRedo ActionType = "redo"
+	// ActionAttack is garbage from the opponent landing on the grid.
+	ActionAttack ActionType = "attack"
+	// ActionReset is the start of a new game on the same grid. It is only kept in
+	// a game's History, as replays only cover one game.
+	ActionReset ActionType = "reset"
+)
+
+// Action is a single player input recorded in a replay.
+type Action struct {
+	Type  ActionType     `json:"type"`
+	Dir   grid.Direction `json:"dir,omitempty"`   // the direction of a move
+	Spawn *grid.Spawn    `json:"spawn,omitempty"` // the tile spawned by a move
+	Time  time.Time      `json:"time"`            // when the input was made
+
+	// Blockers are the blocker tiles placed by an attack.
+	Blockers []grid.Spawn `json:"blockers,omitempty"`
+}
+
+// Replay is a recording of a game which can be played back.
+type Replay struct {
+	Seed         int64         `json:"seed"`
+	RNG          *grid.RNG     `json:"rng"` // the state of the grid's randomness when the game started
+	InitialTiles [][]grid.Tile `json:"initialTiles"`
+	UndoDepth    int           `json:"undoDepth"`
+	Start        time.Time     `json:"start"`
+	Actions      []Action      `json:"actions"`
+	Score        int           `json:"score"`    // the score when the replay was last saved
+	Assisted     bool          `json:"assisted"` // whether the player had help in the game
+	Mode         Mode          `json:"mode"`
+	Target       int           `json:"target,omitempty"` // the tile which wins the game
+}
+
+// newReplay starts a recording of a game from its current state.
+func newReplay(g *Game) *Replay {
+	tiles := g.Grid.Clone()
+	var rng *grid.RNG
+	if g.Grid.RNG != nil {
+		rng = g.Grid.RNG.Clone()
+	}
+	return &Replay{
+		Seed:         g.Grid.Seed,
+		RNG:          rng,
+		InitialTiles: tiles.Tiles,
+		UndoDepth:    g.opts.UndoDepth,
+		Mode:         g.Mode,
+		Target:       g.Target,
+		Start:        time.Now(),
+		Actions:      []Action{},
+	}
+}
+
+// record adds an action to the replay.
+func (r *Replay) record(action Action) {
+	action.Time = time.Now()
+	r.Actions = append(r.Actions, action)
+}
+
+// Filename returns the name of the file the replay is saved as.
+func (r *Replay) Filename() string {
+	return r.Start.Format("2006-01-02_15-04-05.000") + ReplayExt
+}
+
+// Duration returns the time between the start of the game and the last action.
+func (r *Replay) Duration() time.Duration {
+	if len(r.Actions) == 0 {
+		return 0
+	}
+	return r.Actions[len(r.Actions)-1].Time.Sub(r.Start)
+}
+
+// replayStore returns a store for a file in the replay directory.
+func replayStore(filename string) *store.Store {
+	return store.NewStore(filepath.Join(ReplayDir, filename))
+}
+
+// LoadReplay loads a replay from a file.
+func LoadReplay(path string) (*Replay, error) {
+	b, err := store.NewStore(path).ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	var r Replay
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	if len(r.InitialTiles) == 0 || !grid.ValidSize(len(r.InitialTiles[0]), len(r.InitialTiles)) {
+		return nil, fmt.Errorf("replay %s has an invalid grid", path)
+	}
+	return &r, nil
+}
+
+// ListReplays returns the paths of every saved replay, most recent first.
+func ListReplays() ([]string, error) {
+	dir := store.Path(ReplayDir)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ReplayExt) {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	// Filenames are timestamps, so sorting them sorts the replays by age
+	slices.Sort(paths)
+	slices.Reverse(paths)
+	return paths, nil
+}
+
+// Player plays back a replay one action at a time.
+type Player struct {
+	replay *Replay
+	game   *Game
+	next   int // index of the next action to play
+}
+
+// NewPlayer constructs a player for a replay, ready to play the first action.
+func NewPlayer(r *Replay) *Player {
+	p := &Player{replay: r}
+	p.Reset()
+	return p
+}
+
+// Reset returns the player to the start of the replay.
+func (p *Player) Reset() {
+	r := p.replay
+	g := grid.NewGridWithSize(len(r.InitialTiles[0]), len(r.InitialTiles), r.Seed)
+	for i := range r.InitialTiles {
+		copy(g.Tiles[i], r.InitialTiles[i])
+	}
+	if r.RNG != nil {
+		g.RNG = r.RNG.Clone()
+	}
+
+	p.game = &Game{
+		Grid:   g,
+		Timer:  NewTimer(),
+		Mode:   r.Mode.orDefault(),
+		Target: targetOrDefault(r.Target),
+		opts:   &Opts{UndoDepth: r.UndoDepth},
+	}
+	p.game.Transitions = spawnTransitions(g)
+	p.next = 0
+}
+
+// Step plays the next action in the replay. Returns an error if the action
+// cannot be played on the current game state.
+func (p *Player) Step() error {
+	if p.Done() {
+		return errors.New("replay has finished")
+	}
+	action := p.replay.Actions[p.next]
+	p.next++
+
+	if err := p.game.Apply(action); err != nil {
+		return fmt.Errorf("action %d: %w", p.next, err)
+	}
+
+	// Show the time the action was made at rather than the time spent playing back
+	p.game.Timer.Pause().Set(action.Time.Sub(p.replay.Start))
+	return nil
+}
+
+// Done returns whether every action in the replay has been played.
+func (p *Player) Done() bool {
+	return p.next >= len(p.replay.Actions)
+}
+
+// Game returns the game state at the current point in the replay.
+func (p *Player) Game() *Game {
+	return p.game
+}
+
+// Next returns the next action in the replay. Returns false if the replay has
+// finished.
+func (p *Player) Next() (Action, bool) {
+	if p.Done() {
+		return Action{}, false
+	}
+	return p.replay.Actions[p.next], true
+}
+
+// Progress returns the number of actions played and the total number of actions.
+func (p *Player) Progress() (int, int) {
+	return p.next, len(p.replay.Actions)
+}
+
+// NextDelay returns the time the player waited between the previous action and
+// the next one when the replay was recorded.
+func (p *Player) NextDelay() time.Duration {
+	if p.Done() {
+		return 0
+	}
+	prev := p.replay.Start
+	if p.next > 0 {
+		prev = p.replay.Actions[p.next-1].Time
+	}
+	return p.replay.Actions[p.next].Time.Sub(prev)
+}
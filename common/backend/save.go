@@ -0,0 +1,120 @@
+package backend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/z-riley/go-2048-battle/config"
+)
+
+// SaveVersion is the version of the save file format written by this version of
+// the game. It must be incremented, and a migration added, whenever a change to
+// the saved game state needs older saves to be upgraded.
+const SaveVersion = 2
+
+// errChecksum is returned when a save file's contents don't match its checksum.
+var errChecksum = errors.New("save file checksum mismatch")
+
+// saveFile is the envelope which the game state is saved in.
+type saveFile struct {
+	Version    int             `json:"version"`    // the version of the save file format
+	AppVersion string          `json:"appVersion"` // the version of the game which wrote the save
+	Checksum   string          `json:"checksum"`   // the SHA-256 of the game state
+	Game       json.RawMessage `json:"game"`
+}
+
+// migration upgrades the game state of a save by one version.
+type migration func(game map[string]json.RawMessage) error
+
+// migrations upgrade the game state of older saves. The migration at index i
+// upgrades a save from version i+1 to version i+2.
+var migrations = []migration{
+	migrateV1,
+}
+
+// encodeSave wraps the JSON of a game state in a save file envelope.
+func encodeSave(game []byte) ([]byte, error) {
+	return json.Marshal(saveFile{
+		Version:    SaveVersion,
+		AppVersion: config.Version,
+		Checksum:   checksum(game),
+		Game:       game,
+	})
+}
+
+// decodeSave unwraps the JSON of a game state from a save file, upgrading it to
+// the current version. Saves from before the envelope existed are version 1.
+// Returns an error if the save is damaged or was written by a newer version of the
+// game.
+func decodeSave(b []byte) ([]byte, error) {
+	var f saveFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+	if f.Version == 0 {
+		// Version 1 saves are the bare game state
+		f = saveFile{Version: 1, Game: b}
+	} else if f.Checksum != checksum(f.Game) {
+		return nil, errChecksum
+	}
+
+	if f.Version > SaveVersion {
+		return nil, fmt.Errorf("save file version %d (from version %s of the game) is newer than supported version %d",
+			f.Version, f.AppVersion, SaveVersion)
+	}
+	if f.Version == SaveVersion {
+		return f.Game, nil
+	}
+
+	var game map[string]json.RawMessage
+	if err := json.Unmarshal(f.Game, &game); err != nil {
+		return nil, err
+	}
+	for v := f.Version; v < SaveVersion; v++ {
+		if err := migrations[v-1](game); err != nil {
+			return nil, fmt.Errorf("failed to migrate save file from version %d: %w", v, err)
+		}
+	}
+	return json.Marshal(game)
+}
+
+// checksum returns the SHA-256 of b as a hex string.
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// migrateV1 upgrades a version 1 save. Saves from before assisted games existed
+// don't have the previous high score, and saves from before modes existed only
+// have a single high score.
+func migrateV1(game map[string]json.RawMessage) error {
+	highScore, ok := game["highScore"]
+	if !ok {
+		highScore = json.RawMessage("0")
+	}
+	if _, ok := game["prevHighScore"]; !ok {
+		game["prevHighScore"] = highScore
+	}
+
+	if scores, ok := game["highScores"]; !ok || string(scores) == "null" {
+		var mode Mode
+		if raw, ok := game["mode"]; ok {
+			if err := json.Unmarshal(raw, &mode); err != nil {
+				return err
+			}
+		}
+		var score int
+		if err := json.Unmarshal(highScore, &score); err != nil {
+			return err
+		}
+		b, err := json.Marshal(map[string]int{mode.orDefault().Key(): score})
+		if err != nil {
+			return err
+		}
+		game["highScores"] = b
+	}
+	return nil
+}
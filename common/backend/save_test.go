@@ -0,0 +1,117 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/backend/store"
+)
+
+func TestSaveFile(t *testing.T) {
+	game := NewGame(&Opts{Seed: 1})
+	game.HighScore = 128
+	j, err := game.Serialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := encodeSave(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeSave(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(j) {
+		t.Errorf("Expected:\n<%s>\nGot:\n<%s>", j, got)
+	}
+
+	// Damaged saves are rejected
+	damaged := strings.Replace(string(b), `"highScore":128`, `"highScore":129`, 1)
+	if _, err := decodeSave([]byte(damaged)); !errors.Is(err, errChecksum) {
+		t.Errorf("Expected a checksum error, got %v", err)
+	}
+
+	// Saves from newer versions of the game are rejected
+	newer, err := json.Marshal(saveFile{Version: SaveVersion + 1, Checksum: checksum(j), Game: j})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeSave(newer); err == nil {
+		t.Error("Expected an error loading a save from a newer version")
+	}
+}
+
+func TestMigrateV1(t *testing.T) {
+	// A version 1 save is the bare game state, from before the previous high score
+	// and per-mode high scores existed
+	v1 := `{"score":4,"highScore":64,"mode":{"type":"moveLimit","moveLimit":100}}`
+	b, err := decodeSave([]byte(v1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var game Game
+	if err := json.Unmarshal(b, &game); err != nil {
+		t.Fatal(err)
+	}
+	if game.PrevHighScore != 64 {
+		t.Errorf("Expected previous high score of 64, got %d", game.PrevHighScore)
+	}
+	if got := game.HighScores[MoveLimitMode(100).Key()]; got != 64 || len(game.HighScores) != 1 {
+		t.Errorf("Expected a move limit high score of 64, got %v", game.HighScores)
+	}
+}
+
+func TestLoadDamagedSave(t *testing.T) {
+	store.SetDir(t.TempDir())
+	if err := store.NewStore(".save.bruh").SaveBytes([]byte("not a game")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The damaged save is backed up and replaced by a new game
+	game := NewGame(&Opts{SaveToDisk: true, Seed: 1})
+	if game.Score != 0 || game.Grid.NumTiles() != 2 {
+		t.Errorf("Expected a new game, got:\n%s", game.Grid.Debug())
+	}
+	backups, err := filepath.Glob(store.Path(".save.bruh.*.bak"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %v", backups)
+	}
+	if err := game.Load(); err != nil {
+		t.Errorf("Expected the new save file to load, got %v", err)
+	}
+	if _, err := os.Stat(store.Path(".save.bruh")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSaveWritesReplays(t *testing.T) {
+	store.SetDir(t.TempDir())
+	game := NewGame(&Opts{SaveToDisk: true, Seed: 1, Record: true})
+	game.ExecuteMove(grid.DirUp)
+	game.ExecuteMove(grid.DirLeft)
+	replay := *game.Replay
+	game.Reset()
+
+	// The replay is written in the background, but is on the disk once the game
+	// has been saved
+	if err := game.Save(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadReplay(store.Path(filepath.Join(ReplayDir, replay.Filename())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Actions) != len(replay.Actions) {
+		t.Errorf("Expected %d actions in the saved replay, got %d", len(replay.Actions), len(got.Actions))
+	}
+}
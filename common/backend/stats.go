@@ -0,0 +1,200 @@
+package backend
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/backend/store"
+)
+
+// DefaultStatsFile is the file which the results of finished games are recorded
+// in when no other file is given.
+const DefaultStatsFile = ".stats.bruh"
+
+// statsMu stops results recorded at the same time from overwriting each other.
+var statsMu sync.Mutex
+
+// Result is the result of a finished game.
+type Result struct {
+	Start       time.Time     `json:"start"` // when the game started, which identifies the game
+	Score       int           `json:"score"`
+	HighestTile int           `json:"highestTile"`
+	Duration    time.Duration `json:"duration"`
+	Moves       int           `json:"moves"`
+	Mode        Mode          `json:"mode"`
+	Won         bool          `json:"won"`
+	Assisted    bool          `json:"assisted"`           // whether the player had help in the game
+	Opponent    string        `json:"opponent,omitempty"` // the opponent's name in a versus game
+}
+
+// Stats contains the results of every game a player has finished.
+type Stats struct {
+	Results []Result `json:"results"`
+}
+
+// OpponentRecord is a player's record against one opponent.
+type OpponentRecord struct {
+	Name   string
+	Wins   int
+	Losses int
+}
+
+// WinRate returns the fraction of games against the opponent which were won.
+func (o OpponentRecord) WinRate() float64 {
+	if o.Wins+o.Losses == 0 {
+		return 0
+	}
+	return float64(o.Wins) / float64(o.Wins+o.Losses)
+}
+
+// TileCount is the number of games in which a tile was the highest tile reached.
+type TileCount struct {
+	Tile  int
+	Games int
+}
+
+// Result returns the result of the game so far. A game is won by reaching the goal
+// of its mode, or the target tile in classic mode.
+func (g *Game) Result() Result {
+	won := g.Outcome() == grid.Win
+	if g.Mode.Type == ModeClassic {
+		won = g.Grid.HighestTile() >= g.Target
+	}
+	return Result{
+		Start:       g.Started,
+		Score:       g.Score,
+		HighestTile: g.Grid.HighestTile(),
+		Duration:    g.Timer.Duration(),
+		Moves:       g.Moves,
+		Mode:        g.Mode,
+		Won:         won,
+		Assisted:    g.Assisted,
+	}
+}
+
+// LoadStats loads the statistics from a file, relative to the data directory.
+// Returns empty statistics if the file doesn't exist yet.
+func LoadStats(filename string) (*Stats, error) {
+	b, err := store.NewStore(filename).ReadBytes()
+	if errors.Is(err, os.ErrNotExist) {
+		return &Stats{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var s Stats
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// RecordResult adds the result of a finished game to the statistics in a file,
+// relative to the data directory. Recording a game again (e.g. after an undo)
+// replaces its previous result.
+func RecordResult(filename string, r Result) error {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	s, err := LoadStats(filename)
+	if err != nil {
+		return err
+	}
+	s.add(r)
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return store.NewStore(filename).SaveBytes(b)
+}
+
+// add adds a result, replacing the previous result of the same game.
+func (s *Stats) add(r Result) {
+	i := slices.IndexFunc(s.Results, func(prev Result) bool {
+		return prev.Start.Equal(r.Start) && prev.Opponent == r.Opponent
+	})
+	if i >= 0 {
+		s.Results[i] = r
+		return
+	}
+	s.Results = append(s.Results, r)
+}
+
+// Top returns up to n of the highest scoring games, highest first. Assisted games
+// are left out, like they are from high scores.
+func (s *Stats) Top(n int) []Result {
+	var top []Result
+	for _, r := range s.Results {
+		if !r.Assisted {
+			top = append(top, r)
+		}
+	}
+	slices.SortStableFunc(top, func(a, b Result) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return top[:min(n, len(top))]
+}
+
+// AverageScore returns the mean score of every game. Returns zero if no games
+// have been played.
+func (s *Stats) AverageScore() float64 {
+	if len(s.Results) == 0 {
+		return 0
+	}
+	total := 0
+	for _, r := range s.Results {
+		total += r.Score
+	}
+	return float64(total) / float64(len(s.Results))
+}
+
+// Opponents returns the player's record against every opponent in versus games,
+// most played first.
+func (s *Stats) Opponents() []OpponentRecord {
+	var records []OpponentRecord
+	for _, r := range s.Results {
+		if r.Opponent == "" {
+			continue
+		}
+		i := slices.IndexFunc(records, func(o OpponentRecord) bool { return o.Name == r.Opponent })
+		if i < 0 {
+			records = append(records, OpponentRecord{Name: r.Opponent})
+			i = len(records) - 1
+		}
+		if r.Won {
+			records[i].Wins++
+		} else {
+			records[i].Losses++
+		}
+	}
+	slices.SortStableFunc(records, func(a, b OpponentRecord) int {
+		return cmp.Or(
+			cmp.Compare(b.Wins+b.Losses, a.Wins+a.Losses),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+	return records
+}
+
+// HighestTiles returns the number of games which ended with each highest tile,
+// smallest tile first.
+func (s *Stats) HighestTiles() []TileCount {
+	var counts []TileCount
+	for _, r := range s.Results {
+		i := slices.IndexFunc(counts, func(c TileCount) bool { return c.Tile == r.HighestTile })
+		if i < 0 {
+			counts = append(counts, TileCount{Tile: r.HighestTile})
+			i = len(counts) - 1
+		}
+		counts[i].Games++
+	}
+	slices.SortFunc(counts, func(a, b TileCount) int {
+		return cmp.Compare(a.Tile, b.Tile)
+	})
+	return counts
+}
@@ -0,0 +1,122 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/backend/store"
+)
+
+func TestRecordResult(t *testing.T) {
+	store.SetDir(t.TempDir())
+
+	game := NewGame(&Opts{SaveToDisk: true, Seed: 1, UndoDepth: 4, Mode: MoveLimitMode(5)})
+	// Wait for background saves before the directory is removed
+	t.Cleanup(func() { _ = game.store.Flush() })
+	playUntilOver(game, 100)
+
+	stats, err := LoadStats(DefaultStatsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Results) != 1 {
+		t.Fatalf("Expected 1 result, got %+v", stats.Results)
+	}
+	got := stats.Results[0]
+	if !got.Won || got.Moves != 5 || got.Score != game.Score || got.HighestTile != game.Grid.HighestTile() {
+		t.Errorf("Expected result of the finished game, got %+v", got)
+	}
+
+	// Finishing the same game again replaces its result
+	game.Undo()
+	playUntilOver(game, 100)
+	stats, err = LoadStats(DefaultStatsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Results) != 1 {
+		t.Errorf("Expected 1 result after finishing again, got %+v", stats.Results)
+	}
+
+	// A new game adds another result
+	game.Reset()
+	playUntilOver(game, 100)
+	stats, err = LoadStats(DefaultStatsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Results) != 2 {
+		t.Errorf("Expected 2 results, got %+v", stats.Results)
+	}
+}
+
+func TestStats(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stats := &Stats{}
+	for i, r := range []Result{
+		{Score: 100, HighestTile: 16},
+		{Score: 500, HighestTile: 64, Opponent: "Bob", Won: true},
+		{Score: 300, HighestTile: 32, Opponent: "Bob"},
+		{Score: 900, HighestTile: 128, Assisted: true},
+		{Score: 200, HighestTile: 32, Opponent: "Alice", Won: true},
+	} {
+		r.Start = start.Add(time.Duration(i) * time.Minute)
+		r.Mode = ClassicMode()
+		stats.add(r)
+	}
+
+	top := stats.Top(3)
+	if len(top) != 3 || top[0].Score != 500 || top[1].Score != 300 || top[2].Score != 200 {
+		t.Errorf("Expected top scores of 500, 300, 200 without the assisted game, got %+v", top)
+	}
+	if got := len(stats.Top(10)); got != 4 {
+		t.Errorf("Expected 4 unassisted games, got %d", got)
+	}
+
+	if got := stats.AverageScore(); got != 400 {
+		t.Errorf("Expected average score of 400, got %v", got)
+	}
+
+	opponents := stats.Opponents()
+	want := []OpponentRecord{{Name: "Bob", Wins: 1, Losses: 1}, {Name: "Alice", Wins: 1}}
+	if len(opponents) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, opponents)
+	}
+	for i := range want {
+		if opponents[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], opponents[i])
+		}
+	}
+	if rate := opponents[0].WinRate(); rate != 0.5 {
+		t.Errorf("Expected win rate of 0.5, got %v", rate)
+	}
+
+	tiles := stats.HighestTiles()
+	wantTiles := []TileCount{{16, 1}, {32, 2}, {64, 1}, {128, 1}}
+	if len(tiles) != len(wantTiles) {
+		t.Fatalf("Expected %+v, got %+v", wantTiles, tiles)
+	}
+	for i := range wantTiles {
+		if tiles[i] != wantTiles[i] {
+			t.Errorf("Expected %+v, got %+v", wantTiles[i], tiles[i])
+		}
+	}
+}
+
+func TestClassicResult(t *testing.T) {
+	game := NewGame(&Opts{Seed: 1, Target: 256})
+	game.Grid.Tiles = [][]grid.Tile{
+		{{Val: 2}, {Val: 4}, {Val: 2}, {Val: 4}},
+		{{Val: 4}, {Val: 2}, {Val: 4}, {Val: 2}},
+		{{Val: 2}, {Val: 4}, {Val: 2}, {Val: 4}},
+		{{Val: 4}, {Val: 2}, {Val: 4}, {Val: 256}},
+	}
+	// A classic game is lost once it's over, but it was won if the target was reached
+	if game.Outcome() != grid.Lose {
+		t.Fatalf("Expected game to be over, got %v", game.Outcome())
+	}
+	if r := game.Result(); !r.Won || r.HighestTile != 256 {
+		t.Errorf("Expected a won game with a 256 tile, got %+v", r)
+	}
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// appDir is the name of the game's directory within the user's data and config
+// directories.
+const appDir = "go-2048-battle"
+
+// dir overrides the data and config directories if set.
+var dir string
+
+// SetDir makes the game keep all of its files in the given directory instead of
+// the user's data and config directories, so separate profiles can be run in
+// isolation. An empty dir restores the defaults.
+func SetDir(d string) {
+	dir = d
+}
+
+// DataDir returns the directory which the game's data (e.g. saves and replays) is
+// kept in. On Linux and other Unix systems it follows the XDG Base Directory
+// Specification, so it is $XDG_DATA_HOME/go-2048-battle, falling back to
+// ~/.local/share/go-2048-battle.
+func DataDir() string {
+	if dir != "" {
+		return dir
+	}
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		// There is no separate data directory on these platforms
+		return ConfigDir()
+	}
+	if xdg := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, appDir)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(home, ".local", "share", appDir)
+}
+
+// ConfigDir returns the directory which the game's settings are kept in. It is
+// $XDG_CONFIG_HOME/go-2048-battle on Unix systems, or the platform's equivalent.
+func ConfigDir() string {
+	if dir != "" {
+		return dir
+	}
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(config, appDir)
+}
+
+// Path returns the path of a file in the data directory. Absolute paths are
+// returned unchanged.
+func Path(name string) string {
+	return resolve(DataDir(), name)
+}
+
+// ConfigPath returns the path of a file in the config directory. Absolute paths
+// are returned unchanged.
+func ConfigPath(name string) string {
+	return resolve(ConfigDir(), name)
+}
+
+// resolve returns the path of name within dir, unless name is absolute.
+func resolve(dir, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
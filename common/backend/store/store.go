@@ -2,47 +2,169 @@ package store
 
 import (
 	"encoding/gob"
+	"io"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 )
 
 // Store can store bytes to the disk.
 type Store struct {
 	mu       *sync.Mutex
 	filename string
+
+	// encode writes bytes to a file, or encodes them as a gob if nil. Tests set it
+	// to simulate failed writes. It is nil otherwise, so stores can be deep copied.
+	encode func(w io.Writer, b []byte) error
+
+	queueMu *sync.Mutex
+	idle    *sync.Cond // signalled when the background writer stops
+	pending []byte     // the latest queued bytes which haven't been written yet
+	writing bool       // whether the background writer is running
+	lastErr error      // the error from the latest background write
 }
 
-// NewStore constructs a new store under a specified filename. If the file already
-// exists, its current contents are used.
+// NewStore constructs a new store under a specified filename. Relative filenames
+// are in the data directory. If the file already exists, its current contents are
+// used.
 func NewStore(filename string) *Store {
+	queueMu := new(sync.Mutex)
 	return &Store{
 		mu:       new(sync.Mutex),
-		filename: filename,
+		filename: Path(filename),
+		queueMu:  queueMu,
+		idle:     sync.NewCond(queueMu),
 	}
 }
 
-// SaveBytes saves bytes to the store.
+// encodeGob writes bytes to w as a gob.
+func encodeGob(w io.Writer, b []byte) error {
+	return gob.NewEncoder(w).Encode(b)
+}
+
+// cleaned holds a *sync.Once for each file whose leftover temporary files have
+// been removed, keyed by the file's absolute path.
+var cleaned sync.Map
+
+// removeTemps removes temporary files left behind by writes to a file which were
+// interrupted, such as by a crash. It only runs before the first write to the file,
+// so it can't remove the temporary file of a write in progress.
+func removeTemps(filename string) {
+	key, err := filepath.Abs(filename)
+	if err != nil {
+		key = filename
+	}
+	once, _ := cleaned.LoadOrStore(key, new(sync.Once))
+	once.(*sync.Once).Do(func() {
+		tmps, _ := filepath.Glob(filename + ".tmp-*")
+		for _, tmp := range tmps {
+			_ = os.Remove(tmp)
+		}
+	})
+}
+
+// SaveBytes saves bytes to the store. The bytes are written to a temporary file
+// which replaces the store's file once it is safely on the disk, so the previous
+// contents are kept if the write is interrupted.
 func (s *Store) SaveBytes(b []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Create save file if it doesn't exist
-	file, err := os.Create(s.filename)
+	dir, base := filepath.Split(s.filename)
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	removeTemps(s.filename)
+	file, err := os.CreateTemp(dir, base+".tmp-*")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	tmp := file.Name()
 
-	err = gob.NewEncoder(file).Encode(b)
+	encode := s.encode
+	if encode == nil {
+		encode = encodeGob
+	}
+	err = encode(file, b)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.filename)
+	}
 	if err != nil {
+		_ = os.Remove(tmp)
 		return err
 	}
 
+	// Make sure the rename is on the disk too. Not every platform supports syncing
+	// a directory, so failing to is ignored
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
 	return nil
 }
 
+// Queue saves bytes to the store in the background. Saves which are queued whilst
+// another is being written are combined, so only the latest bytes are written
+// once it finishes.
+func (s *Store) Queue(b []byte) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	s.pending = b
+	if !s.writing {
+		s.writing = true
+		go s.writeQueued()
+	}
+}
+
+// writeQueued writes queued bytes until there are none left.
+func (s *Store) writeQueued() {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	for s.pending != nil {
+		b := s.pending
+		s.pending = nil
+
+		s.queueMu.Unlock()
+		err := s.SaveBytes(b)
+		s.queueMu.Lock()
+
+		s.lastErr = err
+	}
+	s.writing = false
+	s.idle.Broadcast()
+}
+
+// Flush waits for queued saves to be written. Returns the error from the latest
+// queued save, if any.
+func (s *Store) Flush() error {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	for s.writing {
+		s.idle.Wait()
+	}
+	err := s.lastErr
+	s.lastErr = nil
+	return err
+}
+
 // ReadBytes reads bytes from the store.
 func (s *Store) ReadBytes() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	file, err := os.Open(s.filename)
 	if err != nil {
 		return nil, err
@@ -56,3 +178,16 @@ func (s *Store) ReadBytes() ([]byte, error) {
 	}
 	return contents, nil
 }
+
+// Backup moves the store's file aside so it can be inspected later, and a new one
+// started in its place. Returns the path of the backup.
+func (s *Store) Backup() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.filename + "." + time.Now().Format("20060102-150405") + ".bak"
+	if err := os.Rename(s.filename, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -2,8 +2,14 @@ package store
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"sync"
 	"testing"
 )
 
@@ -20,6 +26,18 @@ func (g *TestStruct) LoadFromJSON(j []byte) error {
 	return json.Unmarshal(j, &g)
 }
 
+func TestMain(m *testing.M) {
+	// Keep test files out of the user's data directory
+	d, err := os.MkdirTemp("", "store-test")
+	if err != nil {
+		panic(err)
+	}
+	SetDir(d)
+	code := m.Run()
+	_ = os.RemoveAll(d)
+	os.Exit(code)
+}
+
 func TestSaveBytesReadBytes(t *testing.T) {
 	const filename = ".test.bruh"
 	s := NewStore(filename)
@@ -48,3 +66,147 @@ func TestSaveBytesReadBytes(t *testing.T) {
 		t.Errorf("\nExpected:<%v>\nGot:<%v>", expected, got)
 	}
 }
+
+func TestBackup(t *testing.T) {
+	SetDir(t.TempDir())
+	s := NewStore(".test.bruh")
+	if err := s.SaveBytes([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := s.Backup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReadBytes(); !os.IsNotExist(err) {
+		t.Errorf("Expected the store to be empty after a backup, got %v", err)
+	}
+	got, err := NewStore(path).ReadBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("Expected the backup to contain the old contents, got %q", got)
+	}
+}
+
+func TestInterruptedWrite(t *testing.T) {
+	SetDir(t.TempDir())
+
+	// A crash in the middle of a write leaves a temporary file behind, which is
+	// removed before the file is next written
+	leftover := Path(".test.bruh") + ".tmp-123"
+	if err := os.WriteFile(leftover, []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore(".test.bruh")
+	if err := s.SaveBytes([]byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Errorf("Expected the leftover temporary file to be removed, got %v", err)
+	}
+
+	// The write fails halfway through
+	errInterrupted := errors.New("interrupted")
+	s.encode = func(w io.Writer, b []byte) error {
+		if _, err := w.Write(b[:len(b)/2]); err != nil {
+			return err
+		}
+		return errInterrupted
+	}
+	if err := s.SaveBytes([]byte("a much longer new value")); !errors.Is(err, errInterrupted) {
+		t.Fatalf("Expected the write to be interrupted, got %v", err)
+	}
+
+	got, err := s.ReadBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "old" {
+		t.Errorf("Expected the old contents to be kept, got %q", got)
+	}
+	tmps, err := filepath.Glob(s.filename + ".tmp-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmps) != 0 {
+		t.Errorf("Expected the failed write to clean up after itself, got %v", tmps)
+	}
+
+	// Writes work again once the problem goes away
+	s.encode = nil
+	if err := s.SaveBytes([]byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.ReadBytes(); err != nil || string(got) != "new" {
+		t.Errorf("Expected the new contents, got %q (%v)", got, err)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	SetDir(t.TempDir())
+	s := NewStore(".test.bruh")
+
+	// Saves queued at the same time never tear the file, and the last one wins
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Queue([]byte(fmt.Sprint("value ", i)))
+			if _, err := s.ReadBytes(); err != nil && !os.IsNotExist(err) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	s.Queue([]byte("latest"))
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.ReadBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "latest" {
+		t.Errorf("Expected the latest value, got %q", got)
+	}
+
+	// Errors from queued saves are reported by Flush
+	s.encode = func(io.Writer, []byte) error { return errors.New("disk full") }
+	s.Queue([]byte("lost"))
+	if err := s.Flush(); err == nil {
+		t.Error("Expected the failed save to be reported")
+	}
+	if err := s.Flush(); err != nil {
+		t.Errorf("Expected the error to only be reported once, got %v", err)
+	}
+}
+
+func TestDir(t *testing.T) {
+	defer SetDir(DataDir())
+	SetDir("")
+
+	t.Setenv("XDG_DATA_HOME", "/data")
+	t.Setenv("XDG_CONFIG_HOME", "/config")
+	if runtime.GOOS == "linux" {
+		if got := Path("save"); got != "/data/go-2048-battle/save" {
+			t.Errorf("Expected the save to be in the XDG data directory, got %s", got)
+		}
+		if got := ConfigPath("settings"); got != "/config/go-2048-battle/settings" {
+			t.Errorf("Expected the settings to be in the XDG config directory, got %s", got)
+		}
+	}
+
+	// A profile directory holds everything
+	dir := t.TempDir()
+	SetDir(dir)
+	if Path("save") != filepath.Join(dir, "save") || ConfigPath("settings") != filepath.Join(dir, "settings") {
+		t.Errorf("Expected files to be kept in %s", dir)
+	}
+	if Path(filepath.Join(dir, "abs")) != filepath.Join(dir, "abs") {
+		t.Error("Expected absolute paths to be unchanged")
+	}
+}
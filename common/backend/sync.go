@@ -0,0 +1,90 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// Apply plays an action which was made on another copy of the game, such as a move
+// recorded in a replay or made by the host of a versus game. Returns an error if
+// the action cannot be played on the current game state.
+func (g *Game) Apply(action Action) error {
+	switch action.Type {
+	case ActionMove:
+		if action.Spawn == nil {
+			return errors.New("move has no spawned tile")
+		}
+		_, err := g.executeMove(func() (grid.MoveResult, error) {
+			return g.Grid.MoveWithSpawn(action.Dir, *action.Spawn)
+		})
+		return err
+	case ActionUndo:
+		if !g.Undo() {
+			return errors.New("no move to undo")
+		}
+	case ActionRedo:
+		if !g.Redo() {
+			return errors.New("no move to redo")
+		}
+	case ActionAttack:
+		return g.placeBlockers(func() ([]grid.Spawn, error) {
+			return g.Grid.PlaceBlockers(action.Blockers)
+		})
+	default:
+		return fmt.Errorf("unknown action type %q", action.Type)
+	}
+	return nil
+}
+
+// TakeActions returns the actions made since they were last taken, so they can be
+// played on another copy of the game with Apply. Returns nothing unless the game
+// was created with the Sync option.
+func (g *Game) TakeActions() []Action {
+	actions := g.actions
+	g.actions = nil
+	return actions
+}
+
+// Checksum returns a digest of the state of the game which copies of the game can
+// compare to check they are in sync. Tile identities aren't included, as they
+// differ between copies.
+func (g *Game) Checksum() string {
+	type tile struct {
+		Val  int           `json:"v"`
+		Kind grid.TileKind `json:"k,omitempty"`
+		Lock int           `json:"l,omitempty"`
+	}
+	tiles := make([][]tile, len(g.Grid.Tiles))
+	for y, row := range g.Grid.Tiles {
+		tiles[y] = make([]tile, len(row))
+		for x, t := range row {
+			tiles[y][x] = tile{Val: t.Val, Kind: t.Kind, Lock: t.Lock}
+		}
+	}
+
+	b, err := json.Marshal(struct {
+		Tiles [][]tile `json:"tiles"`
+		Score int      `json:"score"`
+		Moves int      `json:"moves"`
+	}{tiles, g.Score, g.Moves})
+	if err != nil {
+		// The state is made of plain values, so this can't happen
+		panic(err)
+	}
+	return checksum(b)
+}
+
+// Adopt replaces the state of the game with the state of another copy of it, such
+// as the host's copy of a guest's game. The game keeps its own options, and the
+// moves made before it can no longer be undone.
+func (g *Game) Adopt(other *Game) {
+	store, opts := g.store, g.opts
+	*g = *other
+	g.store, g.opts = store, opts
+	g.attack = Attack{}
+	g.actions = nil
+	g.undoStack, g.redoStack = nil, nil
+}
@@ -0,0 +1,63 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+func TestSyncActions(t *testing.T) {
+	host := NewGame(&Opts{Seed: 7, Battle: true, Sync: true})
+	guest := NewGame(&Opts{Seed: 7, Battle: true})
+	if host.Checksum() != guest.Checksum() {
+		t.Fatalf("Expected games from the same seed to match. Host:\n%s\nGuest:\n%s", host.Grid.Debug(), guest.Grid.Debug())
+	}
+
+	dirs := []grid.Direction{grid.DirUp, grid.DirLeft, grid.DirDown, grid.DirRight}
+	for i := range 40 {
+		host.ExecuteMove(dirs[i%len(dirs)])
+		if i%10 == 9 {
+			host.ReceiveAttack(Attack{Blockers: 2})
+		}
+
+		for _, action := range host.TakeActions() {
+			if err := guest.Apply(action); err != nil {
+				t.Fatalf("Move %d: %v", i, err)
+			}
+		}
+		if host.Checksum() != guest.Checksum() {
+			t.Fatalf("Move %d: expected games to match. Host:\n%s\nGuest:\n%s", i, host.Grid.Debug(), guest.Grid.Debug())
+		}
+	}
+	if len(host.TakeActions()) != 0 {
+		t.Error("Expected actions to be cleared once taken")
+	}
+
+	// Games without the Sync option don't keep their actions
+	guest.ExecuteMove(grid.DirUp)
+	if actions := guest.TakeActions(); len(actions) != 0 {
+		t.Errorf("Expected no actions, got %v", actions)
+	}
+}
+
+func TestAdopt(t *testing.T) {
+	host := NewGame(&Opts{Seed: 1})
+	guest := NewGame(&Opts{Seed: 2, UndoDepth: 4})
+	guest.ExecuteMove(grid.DirLeft)
+	host.ExecuteMove(grid.DirUp)
+	host.ExecuteMove(grid.DirRight)
+
+	guest.Adopt(host)
+	if guest.Checksum() != host.Checksum() {
+		t.Errorf("Expected adopted game to match. Host:\n%s\nGuest:\n%s", host.Grid.Debug(), guest.Grid.Debug())
+	}
+	if guest.CanUndo() {
+		t.Error("Expected moves from before adopting to be forgotten")
+	}
+
+	// The adopted game keeps its own options
+	guest.ExecuteMove(grid.DirDown)
+	if guest.opts.UndoDepth != 4 || !guest.CanUndo() {
+		t.Error("Expected adopted game to keep its undo depth")
+	}
+}
@@ -1,58 +1,104 @@
 package backend
 
 import (
+	"encoding/json"
+	"sync"
 	"time"
 )
 
+// Timer measures the time spent playing a game. The elapsed time is worked out
+// from when the timer was started, so it is precise and needs no background
+// goroutine. It is safe for concurrent use.
 type Timer struct {
-	Time     time.Duration `json:"time"`
-	isPaused bool
+	mu      sync.Mutex
+	elapsed time.Duration // the time counted before the timer was last resumed
+	started time.Time     // when the timer was last resumed. Zero if paused
+}
+
+// timerJSON is the serialised form of a timer.
+type timerJSON struct {
+	Time time.Duration `json:"time"`
 }
 
 // NewTimer constructs a new timer. Resume must be called to start the timer.
 func NewTimer() *Timer {
-	t := Timer{
-		Time:     time.Duration(0),
-		isPaused: true,
-	}
-
-	go func() {
-		for {
-			time.Sleep(time.Second)
-			if !t.isPaused {
-				t.Time += time.Second
-			}
-		}
-	}()
-
-	return &t
+	return &Timer{}
 }
 
 // Resume resumes the timer.
 func (t *Timer) Resume() *Timer {
-	t.isPaused = false
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.started.IsZero() {
+		t.started = time.Now()
+	}
 	return t
 }
 
 // Pause pauses the timer.
 func (t *Timer) Pause() *Timer {
-	t.isPaused = true
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.elapsed = t.duration()
+	t.started = time.Time{}
 	return t
 }
 
 // Reset sets the timer to zero.
 func (t *Timer) Reset() *Timer {
-	t.Time = 0
-	return t
+	return t.Set(0)
 }
 
 // Set sets the timer to the specified duration.
 func (t *Timer) Set(d time.Duration) *Timer {
-	t.Time = d
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.elapsed = d
+	if !t.started.IsZero() {
+		t.started = time.Now()
+	}
 	return t
 }
 
+// IsPaused returns whether the timer is paused.
+func (t *Timer) IsPaused() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.started.IsZero()
+}
+
 // Duration returns the current time.
 func (t *Timer) Duration() time.Duration {
-	return t.Time
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.duration()
+}
+
+// duration returns the current time. The mutex must be held.
+func (t *Timer) duration() time.Duration {
+	if t.started.IsZero() {
+		return t.elapsed
+	}
+	return t.elapsed + time.Since(t.started)
+}
+
+// String returns the current time to the nearest millisecond, e.g. "1m23.456s".
+func (t *Timer) String() string {
+	return t.Duration().Truncate(time.Millisecond).String()
+}
+
+// MarshalJSON serialises the current time.
+func (t *Timer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(timerJSON{Time: t.Duration()})
+}
+
+// UnmarshalJSON sets the timer to a serialised time. Whether the timer is paused
+// is unchanged.
+func (t *Timer) UnmarshalJSON(b []byte) error {
+	var j timerJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	t.Set(j.Time)
+	return nil
 }
@@ -0,0 +1,79 @@
+package backend
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimer(t *testing.T) {
+	timer := NewTimer()
+	if !timer.IsPaused() || timer.Duration() != 0 {
+		t.Fatalf("Expected a paused timer at zero, got %v", timer.Duration())
+	}
+
+	timer.Resume()
+	time.Sleep(20 * time.Millisecond)
+	timer.Pause()
+	paused := timer.Duration()
+	if paused < 20*time.Millisecond {
+		t.Errorf("Expected at least 20ms, got %v", paused)
+	}
+
+	// The time doesn't change whilst paused
+	time.Sleep(10 * time.Millisecond)
+	if got := timer.Duration(); got != paused {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", paused, got)
+	}
+
+	// Setting the time keeps the timer running if it was running
+	timer.Resume().Set(time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	if got := timer.Duration(); got < time.Minute+10*time.Millisecond {
+		t.Errorf("Expected at least %v, got %v", time.Minute+10*time.Millisecond, got)
+	}
+	if timer.IsPaused() {
+		t.Error("Expected timer to still be running")
+	}
+}
+
+func TestTimerJSON(t *testing.T) {
+	timer := NewTimer().Set(1234567 * time.Microsecond)
+	b, err := json.Marshal(timer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"time":1234567000}`; string(b) != expected {
+		t.Errorf("Expected:\n<%s>\nGot:\n<%s>", expected, b)
+	}
+
+	var got Timer
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Duration() != timer.Duration() || !got.IsPaused() {
+		t.Errorf("Expected a paused timer at %v, got %v", timer.Duration(), got.Duration())
+	}
+	if s := got.String(); s != "1.234s" {
+		t.Errorf("Expected:\n<%v>\nGot:\n<%v>", "1.234s", s)
+	}
+}
+
+func TestTimerConcurrentUse(t *testing.T) {
+	timer := NewTimer()
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			for range 1000 {
+				if i%2 == 0 {
+					timer.Resume()
+				} else {
+					timer.Pause()
+				}
+				_ = timer.Duration()
+			}
+		})
+	}
+	wg.Wait()
+}
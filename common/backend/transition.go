@@ -0,0 +1,170 @@
+package backend
+
+import (
+	"github.com/google/uuid"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// TransitionKind is the way in which a tile changed during a move.
+type TransitionKind string
+
+const (
+	// TransitionMove is a tile sliding to a new position.
+	TransitionMove TransitionKind = "move"
+	// TransitionMerge is a tile sliding into a position and combining with another
+	// tile. The tile no longer exists after the move.
+	TransitionMerge TransitionKind = "merge"
+	// TransitionCombine is a new tile created by two tiles combining.
+	TransitionCombine TransitionKind = "combine"
+	// TransitionSpawn is a new tile spawned after the move.
+	TransitionSpawn TransitionKind = "spawn"
+	// TransitionBlock is a new blocker tile placed by an opponent's attack.
+	TransitionBlock TransitionKind = "block"
+	// TransitionUnlock is a blocker tile becoming a normal tile. It doesn't move.
+	TransitionUnlock TransitionKind = "unlock"
+	// TransitionClear is a tile being cleared by an exploding bomb, after any
+	// sliding. The tile no longer exists after the move.
+	TransitionClear TransitionKind = "clear"
+)
+
+// Transition describes how a single tile changed during a move. Tiles are
+// identified by their UUID.
+type Transition struct {
+	Kind TransitionKind `json:"kind"`
+	UUID uuid.UUID      `json:"uuid"`
+	Val  int            `json:"val"`
+	Tile grid.TileKind  `json:"tile,omitempty"` // the kind of the tile
+	From grid.Pos       `json:"from"`           // the same as To for new tiles
+	To   grid.Pos       `json:"to"`
+
+	// MergedInto is the UUID of the tile created by a merge. It is only set for
+	// merging tiles.
+	MergedInto uuid.UUID `json:"mergedInto,omitzero"`
+}
+
+// NewTile returns whether the tile didn't exist before the move.
+func (t Transition) NewTile() bool {
+	return t.Kind == TransitionCombine || t.Kind == TransitionSpawn || t.Kind == TransitionBlock
+}
+
+// Transitions lists the change to every tile which was affected by a move. Tiles
+// which didn't move aren't included. Combined tiles which were cleared by a bomb
+// straight away are left out.
+func Transitions(res grid.MoveResult) []Transition {
+	cleared := make(map[uuid.UUID]bool, len(res.Clears))
+	for _, c := range res.Clears {
+		cleared[c.UUID] = true
+	}
+
+	var transitions []Transition
+	for _, m := range res.Moves {
+		transitions = append(transitions, Transition{
+			Kind: TransitionMove,
+			UUID: m.UUID,
+			Val:  m.Val,
+			Tile: m.Kind,
+			From: m.From,
+			To:   m.To,
+		})
+	}
+	created := make(map[uuid.UUID]bool, len(res.Merges))
+	for _, m := range res.Merges {
+		created[m.UUID] = true
+		for _, src := range m.Sources {
+			transitions = append(transitions, Transition{
+				Kind:       TransitionMerge,
+				UUID:       src.UUID,
+				Val:        src.Val,
+				Tile:       src.Kind,
+				From:       src.From,
+				To:         src.To,
+				MergedInto: m.UUID,
+			})
+		}
+		if cleared[m.UUID] {
+			continue
+		}
+		transitions = append(transitions, Transition{
+			Kind: TransitionCombine,
+			UUID: m.UUID,
+			Val:  m.Val,
+			Tile: m.Kind,
+			From: m.To,
+			To:   m.To,
+		})
+	}
+	for _, c := range res.Clears {
+		if created[c.UUID] {
+			continue
+		}
+		transitions = append(transitions, Transition{
+			Kind: TransitionClear,
+			UUID: c.UUID,
+			Val:  c.Val,
+			Tile: c.Kind,
+			From: c.Pos,
+			To:   c.Pos,
+		})
+	}
+	for _, u := range res.Unlocks {
+		transitions = append(transitions, Transition{
+			Kind: TransitionUnlock,
+			UUID: u.UUID,
+			Val:  u.Val,
+			From: u.Pos,
+			To:   u.Pos,
+		})
+	}
+	if res.Spawn != nil {
+		pos := grid.Pos{X: res.Spawn.X, Y: res.Spawn.Y}
+		transitions = append(transitions, Transition{
+			Kind: TransitionSpawn,
+			UUID: res.Spawn.UUID,
+			Val:  res.Spawn.Val,
+			Tile: res.Spawn.Kind,
+			From: pos,
+			To:   pos,
+		})
+	}
+	return transitions
+}
+
+// spawnTransitions lists every tile on a grid as being spawned, as happens at the
+// start of a game.
+func spawnTransitions(g *grid.Grid) []Transition {
+	var transitions []Transition
+	for y := range g.Tiles {
+		for x, tile := range g.Tiles[y] {
+			if tile.Empty() {
+				continue
+			}
+			pos := grid.Pos{X: x, Y: y}
+			transitions = append(transitions, Transition{
+				Kind: TransitionSpawn,
+				UUID: tile.UUID,
+				Val:  tile.Val,
+				Tile: tile.Kind,
+				From: pos,
+				To:   pos,
+			})
+		}
+	}
+	return transitions
+}
+
+// blockTransitions lists blocker tiles placed by an attack.
+func blockTransitions(blockers []grid.Spawn) []Transition {
+	var transitions []Transition
+	for _, b := range blockers {
+		pos := grid.Pos{X: b.X, Y: b.Y}
+		transitions = append(transitions, Transition{
+			Kind: TransitionBlock,
+			UUID: b.UUID,
+			Val:  b.Val,
+			Tile: grid.TileBlocker,
+			From: pos,
+			To:   pos,
+		})
+	}
+	return transitions
+}
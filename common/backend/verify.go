@@ -0,0 +1,97 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// Verify plays a game's history on a new grid made from the game's seed and grid
+// settings, without any of the game's own state, and returns the score which the
+// history reaches. Special tiles change which tiles spawn, so specials must match
+// the game's grid. Returns an error if any action in the history couldn't have
+// been made, such as a tile spawning somewhere other than where the seed puts it.
+func Verify(seed int64, width, height int, specials bool, history []Action) (int, error) {
+	if !grid.ValidSize(width, height) {
+		return 0, fmt.Errorf("invalid grid size %dx%d", width, height)
+	}
+
+	type state struct {
+		grid  *grid.Grid
+		score int
+	}
+	// The grid is made the same way as newGrid makes it, so the seed gives the
+	// same tiles
+	g := grid.NewGridWithSize(width, height, seed)
+	if specials {
+		g.Specials = true
+		g.Reset()
+	}
+	score := 0
+	var undoStack, redoStack []state
+
+	for i, action := range history {
+		switch action.Type {
+		case ActionMove:
+			if !grid.ValidDirection(action.Dir) {
+				return 0, fmt.Errorf("action %d: invalid direction %q", i, action.Dir)
+			}
+			before := state{g.Clone(), score}
+			res := g.Move(action.Dir)
+			if !res.Moved {
+				return 0, fmt.Errorf("action %d: move %s doesn't move any tiles", i, action.Dir)
+			}
+			if action.Spawn == nil || !sameSpawn(*action.Spawn, *res.Spawn) {
+				return 0, fmt.Errorf("action %d: spawned tile %+v doesn't match %+v from the seed", i, action.Spawn, *res.Spawn)
+			}
+			score += res.Points
+			undoStack = append(undoStack, before)
+			redoStack = nil
+
+		case ActionUndo:
+			if len(undoStack) == 0 {
+				return 0, fmt.Errorf("action %d: no move to undo", i)
+			}
+			redoStack = append(redoStack, state{g, score})
+			prev := undoStack[len(undoStack)-1]
+			undoStack = undoStack[:len(undoStack)-1]
+			g, score = prev.grid, prev.score
+
+		case ActionRedo:
+			if len(redoStack) == 0 {
+				return 0, fmt.Errorf("action %d: no move to redo", i)
+			}
+			undoStack = append(undoStack, state{g, score})
+			next := redoStack[len(redoStack)-1]
+			redoStack = redoStack[:len(redoStack)-1]
+			g, score = next.grid, next.score
+
+		case ActionAttack:
+			placed := g.AddBlockers(len(action.Blockers))
+			if len(placed) != len(action.Blockers) {
+				return 0, fmt.Errorf("action %d: %d blockers placed, but %d fit on the grid", i, len(action.Blockers), len(placed))
+			}
+			for j := range placed {
+				if !sameSpawn(action.Blockers[j], placed[j]) {
+					return 0, fmt.Errorf("action %d: blocker %+v doesn't match %+v from the seed", i, action.Blockers[j], placed[j])
+				}
+			}
+			undoStack, redoStack = nil, nil
+
+		case ActionReset:
+			g.Reset()
+			score = 0
+			undoStack, redoStack = nil, nil
+
+		default:
+			return 0, fmt.Errorf("action %d: unknown action type %q", i, action.Type)
+		}
+	}
+	return score, nil
+}
+
+// sameSpawn returns whether two spawned tiles are the same, ignoring their
+// identities.
+func sameSpawn(a, b grid.Spawn) bool {
+	return a.X == b.X && a.Y == b.Y && a.Val == b.Val && a.Kind == b.Kind
+}
@@ -0,0 +1,70 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+func TestVerify(t *testing.T) {
+	game := NewGame(&Opts{Seed: 3, Width: 5, Height: 4, Battle: true, UndoDepth: 2, History: true})
+	dirs := []grid.Direction{grid.DirUp, grid.DirLeft, grid.DirDown, grid.DirRight}
+	for i := range 30 {
+		game.ExecuteMove(dirs[i%len(dirs)])
+		switch i {
+		case 9:
+			game.ReceiveAttack(Attack{Blockers: 3})
+		case 14:
+			game.Undo()
+			game.Redo()
+			game.Undo()
+		case 19:
+			game.ResetKeepTimer()
+		}
+	}
+
+	score, err := Verify(3, 5, 4, false, game.History)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score != game.Score {
+		t.Errorf("Expected score of %d, got %d", game.Score, score)
+	}
+
+	// A history played from a different seed spawns different tiles
+	if _, err := Verify(4, 5, 4, false, game.History); err == nil {
+		t.Error("Expected history to fail with a different seed")
+	}
+
+	// A spawned tile which the seed doesn't give is rejected
+	history := make([]Action, len(game.History))
+	copy(history, game.History)
+	for i, action := range history {
+		if action.Type == ActionMove {
+			spawn := *action.Spawn
+			spawn.Val = 2048
+			history[i].Spawn = &spawn
+			break
+		}
+	}
+	if _, err := Verify(3, 5, 4, false, history); err == nil {
+		t.Error("Expected history with a made up tile to fail")
+	}
+
+	// Special tiles change which tiles spawn, so they must be verified with the
+	// same grid settings
+	specials := NewGame(&Opts{Seed: 3, Specials: true, History: true})
+	for i := range 30 {
+		specials.ExecuteMove(dirs[i%len(dirs)])
+	}
+	score, err = Verify(3, grid.DefaultWidth, grid.DefaultHeight, true, specials.History)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score != specials.Score {
+		t.Errorf("Expected score of %d with special tiles, got %d", specials.Score, score)
+	}
+	if _, err := Verify(3, grid.DefaultWidth, grid.DefaultHeight, false, specials.History); err == nil {
+		t.Error("Expected history with special tiles to fail without them")
+	}
+}
@@ -0,0 +1,248 @@
+package comms
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DiscoveryPort is the UDP port which hosts advertise their lobbies on.
+	DiscoveryPort = 8081
+	// advertiseInterval is how often a host advertises its lobby.
+	advertiseInterval = time.Second
+	// lobbyTimeout is how long a lobby is listed for after it was last advertised.
+	lobbyTimeout = 3 * advertiseInterval
+)
+
+// LobbyData describes a host's lobby. It is broadcast on the local network so
+// guests can find the game without typing in the host's IP address.
+type LobbyData struct {
+	Protocol VersionRange `json:"protocol"` // the protocol versions the host can play
+	Name     string       `json:"name"`     // the host's username
+	Port     uint16       `json:"port"`     // the port the host's server listens on
+	Open     bool         `json:"open"`     // whether the lobby has room for a guest
+	Settings SettingsData `json:"settings"`
+}
+
+// ParseLobbyData returns lobby data from a byte slice.
+func ParseLobbyData(b []byte) (d LobbyData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts lobby data into a byte slice.
+func (d LobbyData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypeLobbyData, b})
+}
+
+// Advertiser repeatedly broadcasts a lobby on the local network.
+type Advertiser struct {
+	mu    sync.Mutex
+	lobby LobbyData
+	conn  *net.UDPConn
+	addrs func() []*net.UDPAddr // the addresses the lobby is sent to
+	done  chan struct{}
+}
+
+// StartAdvertising starts broadcasting a lobby on the local network until Stop is
+// called.
+func StartAdvertising(lobby LobbyData) (*Advertiser, error) {
+	return startAdvertising(lobby, func() []*net.UDPAddr {
+		return broadcastAddrs(DiscoveryPort)
+	})
+}
+
+// startAdvertising starts sending a lobby to the given addresses.
+func startAdvertising(lobby LobbyData, addrs func() []*net.UDPAddr) (*Advertiser, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open socket: %w", err)
+	}
+	a := &Advertiser{
+		lobby: lobby,
+		conn:  conn,
+		addrs: addrs,
+		done:  make(chan struct{}),
+	}
+	go a.run()
+	return a, nil
+}
+
+// SetLobby changes the lobby which is advertised.
+func (a *Advertiser) SetLobby(lobby LobbyData) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lobby = lobby
+}
+
+// Stop stops advertising the lobby.
+func (a *Advertiser) Stop() {
+	close(a.done)
+	_ = a.conn.Close()
+}
+
+// run advertises the lobby until the advertiser is stopped.
+func (a *Advertiser) run() {
+	ticker := time.NewTicker(advertiseInterval)
+	defer ticker.Stop()
+	for {
+		a.mu.Lock()
+		msg, err := a.lobby.Serialise()
+		a.mu.Unlock()
+		if err == nil {
+			for _, addr := range a.addrs() {
+				// Networks which can't be reached are skipped
+				_, _ = a.conn.WriteToUDP(msg, addr)
+			}
+		}
+
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// broadcastAddrs returns the broadcast addresses of every IPv4 network the device
+// is on, as well as the limited broadcast address.
+func broadcastAddrs(port int) []*net.UDPAddr {
+	addrs := []*net.UDPAddr{{IP: net.IPv4bcast, Port: port}}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return addrs
+	}
+	for _, i := range ifaces {
+		if i.Flags&net.FlagUp == 0 || i.Flags&net.FlagBroadcast == 0 {
+			continue
+		}
+		ifaceAddrs, err := i.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range ifaceAddrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil {
+				continue
+			}
+			ip, mask := ipNet.IP.To4(), net.IP(ipNet.Mask).To4()
+			if mask == nil {
+				continue
+			}
+			bcast := make(net.IP, net.IPv4len)
+			for j := range bcast {
+				bcast[j] = ip[j] | ^mask[j]
+			}
+			addrs = append(addrs, &net.UDPAddr{IP: bcast, Port: port})
+		}
+	}
+	return addrs
+}
+
+// Lobby is a lobby which was advertised on the local network.
+type Lobby struct {
+	Addr string // the IP address of the host
+	LobbyData
+}
+
+// Browser listens for lobbies advertised on the local network.
+type Browser struct {
+	mu      sync.Mutex
+	conn    *net.UDPConn
+	lobbies map[string]Lobby     // keyed by the host's address and port
+	seen    map[string]time.Time // when each lobby was last advertised
+}
+
+// NewBrowser starts listening for lobbies until Close is called.
+func NewBrowser() (*Browser, error) {
+	return newBrowser(&net.UDPAddr{Port: DiscoveryPort})
+}
+
+// newBrowser starts listening for lobbies on the given address. The address can
+// be shared with other browsers, so more than one game on the same device can
+// search for lobbies.
+func newBrowser(addr *net.UDPAddr) (*Browser, error) {
+	lc := net.ListenConfig{Control: reusePort}
+	conn, err := lc.ListenPacket(context.Background(), "udp4", addr.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for lobbies: %w", err)
+	}
+	b := &Browser{
+		conn:    conn.(*net.UDPConn),
+		lobbies: make(map[string]Lobby),
+		seen:    make(map[string]time.Time),
+	}
+	go b.run()
+	return b, nil
+}
+
+// Lobbies returns the lobbies which have been advertised recently, sorted by the
+// host's name.
+func (b *Browser) Lobbies() []Lobby {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var lobbies []Lobby
+	for key, lobby := range b.lobbies {
+		if time.Since(b.seen[key]) > lobbyTimeout {
+			delete(b.lobbies, key)
+			delete(b.seen, key)
+			continue
+		}
+		lobbies = append(lobbies, lobby)
+	}
+	slices.SortFunc(lobbies, func(a, b Lobby) int {
+		return cmp.Or(
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.Addr, b.Addr),
+			cmp.Compare(a.Port, b.Port),
+		)
+	})
+	return lobbies
+}
+
+// Close stops listening for lobbies.
+func (b *Browser) Close() error {
+	return b.conn.Close()
+}
+
+// run records advertised lobbies until the browser is closed.
+func (b *Browser) run() {
+	buf := make([]byte, 4096)
+	for {
+		n, addr, err := b.conn.ReadFromUDP(buf)
+		if err != nil {
+			// The connection has been closed
+			return
+		}
+
+		// Anything which isn't a lobby is ignored, as other programs may use the
+		// same port
+		msg, err := ParseMessage(buf[:n])
+		if err != nil || msg.Type != TypeLobbyData {
+			continue
+		}
+		data, err := ParseLobbyData(msg.Content)
+		if err != nil {
+			continue
+		}
+
+		lobby := Lobby{Addr: addr.IP.String(), LobbyData: data}
+		key := net.JoinHostPort(lobby.Addr, strconv.Itoa(int(lobby.Port)))
+		b.mu.Lock()
+		b.lobbies[key] = lobby
+		b.seen[key] = time.Now()
+		b.mu.Unlock()
+	}
+}
@@ -0,0 +1,20 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package comms
+
+import "syscall"
+
+// reusePort lets more than one socket listen on the same port, so every game on
+// the device can search for lobbies. Broadcasts are received by all of them.
+func reusePort(_, _ string, c syscall.RawConn) error {
+	var err error
+	if ctrlErr := c.Control(func(fd uintptr) {
+		err = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+		if err == nil {
+			err = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEPORT, 1)
+		}
+	}); ctrlErr != nil {
+		return ctrlErr
+	}
+	return err
+}
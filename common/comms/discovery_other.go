@@ -0,0 +1,11 @@
+//go:build !(linux || windows || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package comms
+
+import "syscall"
+
+// reusePort does nothing on platforms where sharing a port isn't supported, so
+// only one game on the device can search for lobbies at a time.
+func reusePort(_, _ string, _ syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,68 @@
+package comms
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDiscovery(t *testing.T) {
+	browser, err := newBrowser(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer browser.Close()
+
+	lobby := LobbyData{Protocol: VersionRange{1, 2}, Name: "Alice", Port: 8080, Open: true, Settings: SettingsData{Width: 4, Height: 4, Target: 2048}}
+	advertiser, err := startAdvertising(lobby, func() []*net.UDPAddr {
+		return []*net.UDPAddr{browser.conn.LocalAddr().(*net.UDPAddr)}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer advertiser.Stop()
+
+	// The first advertisement is sent straight away
+	lobbies := waitForLobbies(t, browser, func(l []Lobby) bool { return len(l) == 1 })
+	want := Lobby{Addr: "127.0.0.1", LobbyData: lobby}
+	if lobbies[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, lobbies[0])
+	}
+
+	// Changes to the lobby are advertised
+	lobby.Open = false
+	advertiser.SetLobby(lobby)
+	waitForLobbies(t, browser, func(l []Lobby) bool { return len(l) == 1 && !l[0].Open })
+}
+
+func TestBrowsersShareAPort(t *testing.T) {
+	// Every game on the same device searches for lobbies on the same port
+	first, err := newBrowser(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	second, err := newBrowser(first.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("Expected a second browser to listen on the same port: %v", err)
+	}
+	defer second.Close()
+}
+
+// waitForLobbies waits until the lobbies found by a browser satisfy a condition,
+// and returns them.
+func waitForLobbies(t *testing.T, b *Browser, ok func([]Lobby) bool) []Lobby {
+	t.Helper()
+	deadline := time.Now().Add(3 * advertiseInterval)
+	for {
+		lobbies := b.Lobbies()
+		if ok(lobbies) {
+			return lobbies
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for lobbies, got %+v", lobbies)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,15 @@
+package comms
+
+import "syscall"
+
+// reusePort lets more than one socket listen on the same port, so every game on
+// the device can search for lobbies. Broadcasts are received by all of them.
+func reusePort(_, _ string, c syscall.RawConn) error {
+	var err error
+	if ctrlErr := c.Control(func(fd uintptr) {
+		err = syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	}); ctrlErr != nil {
+		return ctrlErr
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package comms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/config"
+)
+
+// VersionRange is a range of versions of the multiplayer protocol, including both
+// ends.
+type VersionRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// Protocol is the range of protocol versions which this game can play. The newest
+// version is increased whenever the messages sent between players change, and the
+// oldest version is increased once a version can no longer be played.
+var Protocol = VersionRange{Min: 1, Max: 2}
+
+// Common returns the newest version which is in both ranges. Returns false if the
+// ranges don't overlap.
+func (r VersionRange) Common(other VersionRange) (int, bool) {
+	version := min(r.Max, other.Max)
+	return version, version >= max(r.Min, other.Min)
+}
+
+// String returns the range in a form which can be shown to the player.
+func (r VersionRange) String() string {
+	if r.Min == r.Max {
+		return fmt.Sprintf("%d", r.Min)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// Capability is an optional feature of a game which both players must support to
+// use.
+type Capability string
+
+const (
+	// CapBoardSize is support for boards other than the default size.
+	CapBoardSize Capability = "boardSize"
+	// CapModes is support for game modes other than classic mode.
+	CapModes Capability = "modes"
+	// CapAttacks is support for battle rules, where players attack each other.
+	CapAttacks Capability = "attacks"
+	// CapCompression is support for compressed messages.
+	CapCompression Capability = "compression"
+)
+
+// Capabilities are the capabilities which this game supports. Versus games are
+// always played in classic mode, and messages are never compressed.
+var Capabilities = []Capability{CapBoardSize, CapAttacks}
+
+// ErrIncompatible is returned when two games can't play each other.
+var ErrIncompatible = errors.New("incompatible version")
+
+// HandshakeData is the first message sent by each player after the guest connects,
+// so the players can agree on how to play.
+type HandshakeData struct {
+	AppVersion   string       `json:"appVersion"` // the version of the game, for showing to players
+	Protocol     VersionRange `json:"protocol"`
+	Capabilities []Capability `json:"capabilities"`
+}
+
+// NewHandshake returns the handshake describing this game.
+func NewHandshake() HandshakeData {
+	return HandshakeData{
+		AppVersion:   config.Version,
+		Protocol:     Protocol,
+		Capabilities: Capabilities,
+	}
+}
+
+// ParseHandshakeData returns handshake data from a byte slice.
+func ParseHandshakeData(b []byte) (d HandshakeData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts handshake data into a byte slice.
+func (d HandshakeData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypeHandshakeData, b})
+}
+
+// Agreement is how two players have agreed to play, following a handshake.
+type Agreement struct {
+	Version      int          // the protocol version both players use
+	Capabilities []Capability // the capabilities both players support
+}
+
+// Negotiate agrees on the newest protocol version and the capabilities which both
+// players support. Returns an error wrapping ErrIncompatible if the players have
+// no protocol version in common.
+func Negotiate(local, remote HandshakeData) (Agreement, error) {
+	version, ok := local.Protocol.Common(remote.Protocol)
+	if !ok {
+		return Agreement{}, fmt.Errorf("%w: opponent's game %s plays protocol %s, but this game %s plays protocol %s",
+			ErrIncompatible, remote.AppVersion, remote.Protocol, local.AppVersion, local.Protocol)
+	}
+
+	var caps []Capability
+	for _, c := range local.Capabilities {
+		if slices.Contains(remote.Capabilities, c) {
+			caps = append(caps, c)
+		}
+	}
+	return Agreement{Version: version, Capabilities: caps}, nil
+}
+
+// Missing returns the capabilities which the settings need, but which aren't part
+// of the agreement.
+func (a Agreement) Missing(settings SettingsData) []Capability {
+	var missing []Capability
+	for _, c := range settings.Requires() {
+		if !slices.Contains(a.Capabilities, c) {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+// Requires returns the capabilities which both players need to play a game with
+// the settings.
+func (d SettingsData) Requires() []Capability {
+	var caps []Capability
+	if d.Width != grid.DefaultWidth || d.Height != grid.DefaultHeight {
+		caps = append(caps, CapBoardSize)
+	}
+	if d.Battle {
+		caps = append(caps, CapAttacks)
+	}
+	return caps
+}
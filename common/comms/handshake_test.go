@@ -0,0 +1,40 @@
+package comms
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	local := HandshakeData{Protocol: VersionRange{2, 4}, Capabilities: []Capability{CapBoardSize, CapAttacks}}
+
+	// The newest version both games play is used, with the capabilities both have
+	agreement, err := Negotiate(local, HandshakeData{Protocol: VersionRange{3, 6}, Capabilities: []Capability{CapAttacks, CapCompression}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agreement.Version != 4 {
+		t.Errorf("Expected version 4, got %d", agreement.Version)
+	}
+	if !slices.Equal(agreement.Capabilities, []Capability{CapAttacks}) {
+		t.Errorf("Expected only attacks in common, got %v", agreement.Capabilities)
+	}
+
+	// Games with no version in common can't play each other
+	for _, r := range []VersionRange{{1, 1}, {5, 6}} {
+		if _, err := Negotiate(local, HandshakeData{Protocol: r}); !errors.Is(err, ErrIncompatible) {
+			t.Errorf("Expected versions %s to be incompatible, got %v", r, err)
+		}
+	}
+}
+
+func TestMissing(t *testing.T) {
+	agreement := Agreement{Version: 1, Capabilities: []Capability{CapAttacks}}
+	if missing := agreement.Missing(SettingsData{Width: 4, Height: 4, Battle: true}); len(missing) != 0 {
+		t.Errorf("Expected nothing missing, got %v", missing)
+	}
+	if missing := agreement.Missing(SettingsData{Width: 5, Height: 5}); !slices.Equal(missing, []Capability{CapBoardSize}) {
+		t.Errorf("Expected board size to be missing, got %v", missing)
+	}
+}
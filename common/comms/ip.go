@@ -2,11 +2,16 @@ package comms
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 )
 
+// DefaultPort is the TCP port which hosts listen on unless another is chosen.
+const DefaultPort = 8080
+
 // IsWSL returns true if the device is a WSL instance.
 func IsWSL() bool {
 	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
@@ -68,3 +73,58 @@ func isPrivateIP(ip net.IP) bool {
 
 	return false
 }
+
+// ParseAddr parses an address typed in by a player, which is a host name or an IP
+// address followed by an optional port, such as "192.168.1.5:8080" or "[::1]:8080".
+// If no port is given, defaultPort is used. IPv6 addresses are returned in square
+// brackets, so a port can be appended to them.
+func ParseAddr(addr string, defaultPort uint16) (host string, port uint16, err error) {
+	addr = strings.TrimSpace(addr)
+	host, portStr, err := net.SplitHostPort(addr)
+	hasPort := err == nil
+	if !hasPort {
+		// There is no port, or the address is an IPv6 address without brackets
+		host = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	}
+
+	if host == "" || strings.ContainsAny(host, " []") {
+		return "", 0, fmt.Errorf("invalid host %q", host)
+	}
+	if strings.Contains(host, ":") {
+		// Zones such as "%eth0" are allowed, but aren't part of the IP address
+		ip, _, _ := strings.Cut(host, "%")
+		if net.ParseIP(ip) == nil {
+			return "", 0, fmt.Errorf("invalid IPv6 address %q", host)
+		}
+		host = "[" + host + "]"
+	}
+
+	port = defaultPort
+	if hasPort {
+		port, err = ParsePort(portStr)
+		if err != nil {
+			return "", 0, err
+		}
+	}
+	return host, port, nil
+}
+
+// ParsePort parses a TCP port number. Returns an error unless the port is in the
+// range [1, 65535].
+func ParsePort(s string) (uint16, error) {
+	port, err := strconv.ParseUint(strings.TrimSpace(s), 10, 16)
+	if err != nil || port == 0 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return uint16(port), nil
+}
+
+// FreePort returns a TCP port which isn't in use, chosen by the system.
+func FreePort() (uint16, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return uint16(l.Addr().(*net.TCPAddr).Port), nil
+}
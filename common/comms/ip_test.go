@@ -0,0 +1,35 @@
+package comms
+
+import "testing"
+
+func TestParseAddr(t *testing.T) {
+	for _, tc := range []struct {
+		addr string
+		host string
+		port uint16
+		ok   bool
+	}{
+		{"192.168.1.5", "192.168.1.5", 8080, true},
+		{" 192.168.1.5:9000 ", "192.168.1.5", 9000, true},
+		{"localhost:9000", "localhost", 9000, true},
+		{"::1", "[::1]", 8080, true},
+		{"[::1]", "[::1]", 8080, true},
+		{"[fe80::1%eth0]:9000", "[fe80::1%eth0]", 9000, true},
+		{"", "", 0, false},
+		{"192.168.1.5:", "", 0, false},
+		{"192.168.1.5:0", "", 0, false},
+		{"192.168.1.5:70000", "", 0, false},
+		{"[::1]:port", "", 0, false},
+		{"::1::2", "", 0, false},
+		{"Enter IP address", "", 0, false},
+	} {
+		host, port, err := ParseAddr(tc.addr, DefaultPort)
+		if (err == nil) != tc.ok {
+			t.Errorf("%q: expected ok=%v, got error %v", tc.addr, tc.ok, err)
+			continue
+		}
+		if host != tc.host || port != tc.port {
+			t.Errorf("%q: expected %s port %d, got %s port %d", tc.addr, tc.host, tc.port, host, port)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
 )
 
 // Message contains data for multiplayer mode communication. The Type field
@@ -24,15 +25,20 @@ func ParseMessage(b []byte) (m Message, err error) {
 type MessageType string
 
 const (
-	TypePlayerData  MessageType = "playerData"
-	TypeGameData    MessageType = "gameData"
-	TypeEventData   MessageType = "eventData"
-	TypeRequestData MessageType = "request"
+	TypePlayerData    MessageType = "playerData"
+	TypeEventData     MessageType = "eventData"
+	TypeRequestData   MessageType = "request"
+	TypeSettingsData  MessageType = "settingsData"
+	TypeMoveData      MessageType = "moveData"
+	TypeActionData    MessageType = "actionData"
+	TypeSyncData      MessageType = "syncData"
+	TypeVerifyData    MessageType = "verifyData"
+	TypeLobbyData     MessageType = "lobbyData"
+	TypeHandshakeData MessageType = "handshakeData"
 )
 
 // PlayerData contains data about a player.
 type PlayerData struct {
-	Version  string `json:"version"`
 	Username string `json:"username"`
 }
 
@@ -53,24 +59,135 @@ func (d PlayerData) Serialise() ([]byte, error) {
 	return json.Marshal(Message{TypePlayerData, b})
 }
 
-// GameData contains a game's current state.
-type GameData struct {
-	Game backend.Game `json:"game"`
+// SettingsData contains the settings of a game, as chosen by the host.
+type SettingsData struct {
+	Width  int  `json:"width"`  // number of columns in the grid
+	Height int  `json:"height"` // number of rows in the grid
+	Target int  `json:"target"` // the value of the tile which wins the game
+	Battle bool `json:"battle"` // whether players can attack each other
+
+	// HostSeed and GuestSeed seed the host's and guest's games, so both players
+	// start with the same grids. They are chosen when the host starts the game.
+	HostSeed  int64 `json:"hostSeed,omitempty"`
+	GuestSeed int64 `json:"guestSeed,omitempty"`
 }
 
-// ParseGameData returns game data from a byte slice.
-func ParseGameData(b []byte) (d GameData, err error) {
+// ParseSettingsData returns settings data from a byte slice.
+func ParseSettingsData(b []byte) (d SettingsData, err error) {
 	err = json.Unmarshal(b, &d)
 	return d, err
 }
 
-// Serialise converts game data into a byte slice.
-func (d GameData) Serialise() ([]byte, error) {
+// Serialise converts settings data into a byte slice.
+func (d SettingsData) Serialise() ([]byte, error) {
 	b, err := json.Marshal(d)
 	if err != nil {
 		return nil, err
 	}
-	return json.Marshal(Message{TypeGameData, b})
+	return json.Marshal(Message{TypeSettingsData, b})
+}
+
+// MoveData contains a move which the guest wants to make. The host makes the move
+// in its copy of the guest's game, which is the one that counts.
+type MoveData struct {
+	Seq int            `json:"seq"` // the number of moves the guest has sent, including this one
+	Dir grid.Direction `json:"dir"`
+
+	// Seen is the sequence number of the last update the guest received from the
+	// host, and Checksum is the checksum of the guest's game after it. The host
+	// compares them with its own copy to find guests which have changed their game.
+	Seen     int    `json:"seen"`
+	Checksum string `json:"checksum"`
+}
+
+// ParseMoveData returns move data from a byte slice.
+func ParseMoveData(b []byte) (d MoveData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts move data into a byte slice.
+func (d MoveData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypeMoveData, b})
+}
+
+// ActionData is an update from the host containing the actions made in both games
+// since the previous update, and the checksums of the games afterwards.
+type ActionData struct {
+	Seq           int              `json:"seq"` // the update's sequence number
+	Ack           int              `json:"ack"` // the sequence number of the last move made for the guest
+	Host          []backend.Action `json:"host,omitempty"`
+	Guest         []backend.Action `json:"guest,omitempty"`
+	HostChecksum  string           `json:"hostChecksum"`
+	GuestChecksum string           `json:"guestChecksum"`
+}
+
+// ParseActionData returns action data from a byte slice.
+func ParseActionData(b []byte) (d ActionData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts action data into a byte slice.
+func (d ActionData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypeActionData, b})
+}
+
+// SyncData is an update from the host containing the whole state of both games. It
+// is sent when the guest's games need replacing, such as at the start of a game or
+// when the guest's games no longer match the host's.
+type SyncData struct {
+	Seq   int          `json:"seq"` // the update's sequence number
+	Ack   int          `json:"ack"` // the sequence number of the last move made for the guest
+	Host  backend.Game `json:"host"`
+	Guest backend.Game `json:"guest"`
+}
+
+// ParseSyncData returns sync data from a byte slice.
+func ParseSyncData(b []byte) (d SyncData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts sync data into a byte slice.
+func (d SyncData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypeSyncData, b})
+}
+
+// VerifyData is sent by each player when a game ends, so the opponent can check
+// the player's score could really have been reached from their seed.
+type VerifyData struct {
+	Seed     int64            `json:"seed"`               // the seed the player's game was made from
+	Specials bool             `json:"specials,omitempty"` // whether the player's game spawned special tiles
+	Score    int              `json:"score"`              // the player's final score
+	History  []backend.Action `json:"history"`            // every action in the player's game
+}
+
+// ParseVerifyData returns verify data from a byte slice.
+func ParseVerifyData(b []byte) (d VerifyData, err error) {
+	err = json.Unmarshal(b, &d)
+	return d, err
+}
+
+// Serialise converts verify data into a byte slice.
+func (d VerifyData) Serialise() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{TypeVerifyData, b})
 }
 
 // EventData contains an event which has occurred.
@@ -86,6 +203,8 @@ const (
 	EventHostStartGame Event = "host started game"
 	// EventScreenLoaded signifies that the screen has finished initialising.
 	EventScreenLoaded Event = "screen loaded"
+	// EventRestart signifies that the guest wants to restart their game.
+	EventRestart Event = "restart"
 )
 
 // ParseEventData returns event data from a byte slice.
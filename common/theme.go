@@ -2,7 +2,9 @@ package common
 
 import (
 	"image/color"
+	"path/filepath"
 
+	"github.com/z-riley/go-2048-battle/assets"
 	"github.com/z-riley/gogl"
 )
 
@@ -45,13 +47,28 @@ var (
 	Tile2048Colour = gogl.RGB(235, 196, 2) // official colour
 	Tile4096Colour = gogl.RGB(255, 59, 59)
 	Tile8192Colour = gogl.RGB(255, 32, 33)
+
+	BlockerTileColour  = gogl.RGB(94, 86, 78)
+	WallTileColour     = gogl.RGB(60, 58, 50)
+	WildcardTileColour = gogl.RGB(142, 124, 195)
+	BombTileColour     = gogl.RGB(40, 40, 40)
+	DoublingTileColour = gogl.RGB(94, 178, 168)
 )
 
-const (
-	FontPathMedium = "./assets/ClearSans/ClearSans-Medium.ttf"
-	FontPathBold   = "./assets/ClearSans/ClearSans-Medium.ttf"
+// Font paths. The fonts are loaded from the working directory until SetAssetDir
+// is called.
+var (
+	FontPathMedium = filepath.Join("assets", assets.FontMedium)
+	FontPathBold   = filepath.Join("assets", assets.FontMedium)
 )
 
+// SetAssetDir makes the fonts load from a directory which the assets were
+// extracted to. It must be called before any widgets are created.
+func SetAssetDir(dir string) {
+	FontPathMedium = filepath.Join(dir, assets.FontMedium)
+	FontPathBold = filepath.Join(dir, assets.FontMedium)
+}
+
 // tileColour returns the colour for a tile of a given value.
 func tileColour(val int) color.Color {
 	switch val {
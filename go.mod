@@ -8,13 +8,13 @@ require (
 	github.com/moby/moby v27.3.1+incompatible
 	github.com/z-riley/gogl v0.1.1-0.20251212173100-1eaf28c969ce
 	github.com/z-riley/servesyouright v1.0.0
-	golang.org/x/exp v0.0.0-20240808152545-0cdaa3abc0fa
 )
 
 require (
 	github.com/ebitengine/purego v0.8.3 // indirect
 	github.com/jupiterrider/purego-sdl3 v0.0.0-20251207102000-8bd199c0f033 // indirect
 	github.com/netgusto/poly2tri-go v0.0.0-20170716161910-d102ad91854f // indirect
+	golang.org/x/exp v0.0.0-20240808152545-0cdaa3abc0fa // indirect
 	golang.org/x/image v0.19.0 // indirect
 	golang.org/x/text v0.17.0 // indirect
 )
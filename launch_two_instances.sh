@@ -13,11 +13,11 @@ cleanup() {
 trap cleanup INT
 
 # Start the processes in the background
-process1_command="go run cmd/main.go --screen multiplayerJoin"
+process1_command="go run cmd/main.go --screen multiplayerJoin --data-dir ${TMPDIR:-/tmp}/go-2048-battle-join"
 $process1_command 2>&1 &
 pid1=$!
 
-process2_command="go run cmd/main.go --screen multiplayerHost"
+process2_command="go run cmd/main.go --screen multiplayerHost --data-dir ${TMPDIR:-/tmp}/go-2048-battle-host"
 $process2_command 2>&1 &
 pid2=$!
 
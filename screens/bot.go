@@ -0,0 +1,121 @@
+package screens
+
+import (
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/ai"
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+)
+
+// botDifficulty contains the settings for a computer opponent.
+type botDifficulty struct {
+	name     string
+	interval time.Duration // the time between moves
+	depth    int           // the number of moves the bot looks ahead
+}
+
+// botDifficulties contains every difficulty which the player can choose from,
+// from easiest to hardest.
+var botDifficulties = []botDifficulty{
+	{name: "EASY", interval: 1200 * time.Millisecond, depth: 1},
+	{name: "NORMAL", interval: 700 * time.Millisecond, depth: 2},
+	{name: "HARD", interval: 400 * time.Millisecond, depth: 3},
+	{name: "EXPERT", interval: 200 * time.Millisecond, depth: 4},
+}
+
+// defaultBotDifficulty is the index of the difficulty used when no other
+// difficulty is chosen.
+const defaultBotDifficulty = 1
+
+// bot plays a game automatically at a set difficulty. Moves are searched for in
+// the background, but the game is only modified by update, so a bot can safely
+// share its game with the update loop.
+type bot struct {
+	game       *backend.Game
+	solver     *ai.Solver
+	difficulty botDifficulty
+
+	requestCh chan struct{} // receives a value when the bot should move
+	resultCh  chan botMove  // receives the result of each search
+	stopCh    chan struct{} // closed to stop requesting moves
+	searching bool          // whether a move is being searched for
+}
+
+// botMove contains the move chosen by a bot for a grid.
+type botMove struct {
+	tiles [][]grid.Tile // the tiles the move was chosen for
+	dir   grid.Direction
+	ok    bool // false if no move is possible
+}
+
+// newBot constructs a bot which plays the given game. start must be called for
+// the bot to begin playing.
+func newBot(game *backend.Game, difficulty botDifficulty) *bot {
+	return &bot{
+		game: game,
+		solver: ai.NewSolver(ai.NewExpectimax(), ai.Budget{
+			Depth: difficulty.depth,
+			Time:  difficulty.interval / 2,
+		}),
+		difficulty: difficulty,
+		requestCh:  make(chan struct{}, 1),
+		resultCh:   make(chan botMove, 1),
+	}
+}
+
+// start starts the bot requesting a move at the rate set by its difficulty.
+func (b *bot) start() {
+	b.stopCh = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(b.difficulty.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				// Drop the request if the previous one hasn't been handled yet
+				select {
+				case b.requestCh <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}(b.stopCh)
+}
+
+// stop stops the bot from making any more moves.
+func (b *bot) stop() {
+	if b.stopCh != nil {
+		close(b.stopCh)
+		b.stopCh = nil
+	}
+}
+
+// update starts searching for a move if one has been requested, and makes the
+// move once it has been found. It must be called from the same goroutine which
+// reads the bot's game.
+func (b *bot) update() {
+	select {
+	case <-b.requestCh:
+		if b.searching {
+			return
+		}
+		b.searching = true
+		g := b.game.Grid.Clone()
+		go func() {
+			dir, ok := b.solver.BestMove(g)
+			b.resultCh <- botMove{tiles: g.Tiles, dir: dir, ok: ok}
+		}()
+
+	case move := <-b.resultCh:
+		b.searching = false
+		if move.ok && grid.EqualGrid(move.tiles, b.game.Grid.Tiles) {
+			b.game.ExecuteMove(move.dir)
+		}
+
+	default:
+		// Nothing to do
+	}
+}
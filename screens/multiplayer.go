@@ -1,11 +1,12 @@
 package screens
 
 import (
+	"errors"
 	"fmt"
 	"image/color"
 	"strconv"
+	"time"
 
-	"github.com/brunoga/deep"
 	"github.com/z-riley/go-2048-battle/common"
 	"github.com/z-riley/go-2048-battle/common/backend"
 	"github.com/z-riley/go-2048-battle/common/backend/grid"
@@ -29,7 +30,10 @@ type MultiplayerScreen struct {
 	backend       *backend.Game
 	arena         *common.Arena
 	arenaInputCh  chan func()
+	attacks       []backend.Attack // attacks on the player waiting to be applied
 	endGameDialog *gogl.Text
+	attackAlert   *gogl.Text
+	alertUntil    time.Time // when the attack alert stops being shown
 	debugGrid     *gogl.Text
 
 	opponentScore     *common.ScoreBox
@@ -39,9 +43,27 @@ type MultiplayerScreen struct {
 	opponentBackend   *backend.Game
 	opponentDebugGrid *gogl.Text
 
-	// EITHER server or client will exist
+	resultRecorded bool // whether the result of the game has been added to the statistics
+
+	// When a networked game ends, each player sends their game's history so the
+	// opponent can check it, and the result is only recorded if it checks out
+	opponentSeed  int64             // the seed the opponent's game was made from
+	verifySent    bool              // whether the player's history has been sent to the opponent
+	opponentClaim *comms.VerifyData // the history sent by the opponent, if it has arrived
+	unverified    bool              // whether the opponent's game failed to check out
+
+	// EITHER server, client or bot will exist
 	server *servesyouright.Server
 	client *servesyouright.Client
+	bot    *bot
+
+	// A networked game is run by the host, which makes the guest's moves in its own
+	// copy of the guest's game and sends the guest updates of both games
+	seq       int            // the sequence number of the last update sent by the host or applied by the guest
+	moveSeq   int            // the sequence number of the last move sent by the guest or received by the host
+	ack       int            // the sequence number of the last move the host has told the guest it made
+	checksums map[int]string // the checksum of the guest's game after each update sent by the host
+	resyncing bool           // whether the guest is waiting for the host to replace its games
 }
 
 // NewMultiplayerScreen constructs a new singleplayer menu screen.
@@ -57,10 +79,40 @@ const (
 	usernameKey = "username"
 	// usernameKey is used for indentifying the opponent's username in InitData.
 	opponentUsernameKey = "opponentUsername"
+	// settingsKey is used for identifying the game settings in InitData.
+	settingsKey = "settings"
+	// botKey is used for identifying the difficulty of a computer opponent in
+	// InitData.
+	botKey = "bot"
 )
 
 // Enter initialises the screen.
 func (s *MultiplayerScreen) Enter(initData InitData) {
+	settings, ok := initData[settingsKey].(comms.SettingsData)
+	if !ok {
+		settings = comms.SettingsData{
+			Width:  defaultBoardSize.width,
+			Height: defaultBoardSize.height,
+			Target: grid.DefaultTarget,
+		}
+	}
+
+	s.resultRecorded = false
+	s.attacks = nil
+	s.verifySent, s.opponentClaim, s.unverified = false, nil, false
+	s.seq, s.moveSeq, s.ack = 0, 0, 0
+	s.checksums = make(map[int]string)
+	s.resyncing = false
+
+	// Both games of a networked game are made from seeds chosen by the host, so the
+	// host and guest start with the same grids
+	_, isHost := initData[serverKey]
+	seed, opponentSeed := settings.HostSeed, settings.GuestSeed
+	if _, isGuest := initData[clientKey]; isGuest {
+		seed, opponentSeed = settings.GuestSeed, settings.HostSeed
+	}
+	s.opponentSeed = opponentSeed
+
 	// UI widgets
 	{
 		s.arena = common.NewArena(
@@ -85,6 +137,12 @@ func (s *MultiplayerScreen) Enter(initData InitData) {
 			gogl.Vec{X: config.WinWidth / 2, Y: anchor.Y - 2.5*unit},
 		).SetAlignment(gogl.AlignTopCentre).SetSize(25)
 
+		s.attackAlert = common.NewGameText(
+			"",
+			gogl.Vec{X: config.WinWidth / 2, Y: anchor.Y + common.ArenaSizePx/2},
+		).SetAlignment(gogl.AlignCentre).SetSize(25).SetColour(common.Tile64Colour)
+		s.alertUntil = time.Time{}
+
 		// Player's grid
 		{
 			const widgetWidth = unit * 1.27
@@ -110,12 +168,19 @@ func (s *MultiplayerScreen) Enter(initData InitData) {
 			).SetHeading("SCORE")
 
 			s.guide = common.NewGameText(
-				"Your grid",
+				fmt.Sprintf("Your grid - first to %d wins", settings.Target),
 				gogl.Vec{X: anchor.X + s.arena.Width(), Y: anchor.Y - 0.67*unit},
 			).SetAlignment(gogl.AlignTopRight)
 
 			s.backend = backend.NewGame(&backend.Opts{
 				SaveToDisk: false,
+				Seed:       seed,
+				Width:      settings.Width,
+				Height:     settings.Height,
+				Target:     settings.Target,
+				Battle:     settings.Battle,
+				Sync:       isHost,
+				History:    true,
 			})
 			s.arenaInputCh = make(chan func(), 100)
 
@@ -143,6 +208,13 @@ func (s *MultiplayerScreen) Enter(initData InitData) {
 
 			s.opponentBackend = backend.NewGame(&backend.Opts{
 				SaveToDisk: false,
+				Seed:       opponentSeed,
+				Width:      settings.Width,
+				Height:     settings.Height,
+				Target:     settings.Target,
+				Battle:     settings.Battle,
+				Sync:       isHost,
+				History:    true,
 			})
 		}
 
@@ -160,9 +232,16 @@ func (s *MultiplayerScreen) Enter(initData InitData) {
 		)
 	}
 
-	// Initialise server/client
+	// Initialise server/client/bot
 	{
-		if server, ok := initData[serverKey]; ok {
+		s.server, s.client, s.bot = nil, nil, nil
+
+		if difficulty, ok := initData[botKey]; ok {
+			// Local mode - the opponent's game is played by a bot
+			s.bot = newBot(s.opponentBackend, difficulty.(botDifficulty))
+			s.bot.start()
+			s.opponentBackend.Timer.Resume()
+		} else if server, ok := initData[serverKey]; ok {
 			// Host mode - initialise server
 			s.server = server.(*servesyouright.Server)
 			s.server.SetCallback(func(_ int, b []byte) {
@@ -172,6 +251,7 @@ func (s *MultiplayerScreen) Enter(initData InitData) {
 			}).SetDisconnectCallback(func(_ int) {
 				log.Println("Opponent has left the game")
 			})
+			s.opponentBackend.Timer.Resume()
 		} else if client, ok := initData[clientKey]; ok {
 			// Guest mode - initialise client
 			s.client = client.(*servesyouright.Client)
@@ -181,11 +261,11 @@ func (s *MultiplayerScreen) Enter(initData InitData) {
 				}
 			})
 		} else {
-			panic("neither server or client was passed to MultiplayerScreen Init")
+			panic("neither server, client or bot was passed to MultiplayerScreen Init")
 		}
 
 		// Tell the opponent that the local server/client is ready to receive data
-		if err := s.sendScreenLoadedEvent(); err != nil {
+		if err := s.sendEvent(comms.EventScreenLoaded); err != nil {
 			log.Println("Failed to send game update", err)
 		}
 	}
@@ -196,22 +276,22 @@ func (s *MultiplayerScreen) Enter(initData InitData) {
 	{
 		s.win.RegisterKeybind(gogl.KeyUp, gogl.KeyPress, func() {
 			s.arenaInputCh <- func() {
-				s.backend.ExecuteMove(grid.DirUp)
+				s.move(grid.DirUp)
 			}
 		})
 		s.win.RegisterKeybind(gogl.KeyDown, gogl.KeyPress, func() {
 			s.arenaInputCh <- func() {
-				s.backend.ExecuteMove(grid.DirDown)
+				s.move(grid.DirDown)
 			}
 		})
 		s.win.RegisterKeybind(gogl.KeyLeft, gogl.KeyPress, func() {
 			s.arenaInputCh <- func() {
-				s.backend.ExecuteMove(grid.DirLeft)
+				s.move(grid.DirLeft)
 			}
 		})
 		s.win.RegisterKeybind(gogl.KeyRight, gogl.KeyPress, func() {
 			s.arenaInputCh <- func() {
-				s.backend.ExecuteMove(grid.DirRight)
+				s.move(grid.DirRight)
 			}
 		})
 		s.win.RegisterKeybind(gogl.KeyR, gogl.KeyRelease, func() {
@@ -227,10 +307,84 @@ func (s *MultiplayerScreen) Enter(initData InitData) {
 	s.backend.Timer.Resume()
 }
 
-// Reset resets the multiplayer screen.
+// Reset restarts the player's game. A guest's game is restarted by the host. A
+// game can't be restarted once it has ended, as it is being checked by the
+// opponent.
 func (s *MultiplayerScreen) Reset() {
+	if isLoss, isWin := s.outcome(); isLoss || isWin {
+		return
+	}
+	if s.client != nil {
+		if err := s.sendEvent(comms.EventRestart); err != nil {
+			log.Println("Failed to ask host to restart game:", err)
+		}
+		return
+	}
+
 	s.backend.ResetKeepTimer()
 	s.arena.Reset()
+	if s.server != nil {
+		if err := s.sendSync(); err != nil {
+			log.Println("Failed to send game update:", err)
+		}
+	}
+}
+
+// outcome returns whether the player has lost or won the game.
+func (s *MultiplayerScreen) outcome() (isLoss, isWin bool) {
+	isLoss = s.backend.Outcome() == grid.Lose || s.opponentBackend.Outcome() == grid.Win
+	isWin = s.backend.Outcome() == grid.Win || s.opponentBackend.Outcome() == grid.Lose
+	return isLoss, isWin
+}
+
+// move makes a move in the player's game. A guest's moves are sent to the host,
+// which makes them and sends back the result.
+func (s *MultiplayerScreen) move(dir grid.Direction) {
+	if isLoss, isWin := s.outcome(); isLoss || isWin {
+		return
+	}
+	if s.client == nil {
+		s.backend.ExecuteMove(dir)
+		return
+	}
+
+	// Moves are only sent once the host has sent its games and made the previous
+	// move, so every move is made from the grid which the guest can see
+	if s.seq == 0 || s.ack < s.moveSeq || s.resyncing {
+		return
+	}
+	s.moveSeq++
+	msg, err := comms.MoveData{
+		Seq:      s.moveSeq,
+		Dir:      dir,
+		Seen:     s.seq,
+		Checksum: s.backend.Checksum(),
+	}.Serialise()
+	if err != nil {
+		log.Println("Failed to serialise move data:", err)
+		return
+	}
+	if err := s.sendToOpponent(msg); err != nil {
+		log.Println("Failed to send move:", err)
+	}
+}
+
+// exchangeAttacks delivers the attacks built up in each game to the other game.
+// The host delivers the guest's attacks, because it runs both games. Attacks made
+// once the game has ended are dropped, so the games stay as they were when they
+// were sent to be checked.
+func (s *MultiplayerScreen) exchangeAttacks() {
+	if isLoss, isWin := s.outcome(); isLoss || isWin {
+		return
+	}
+	if attack, ok := s.backend.TakeAttack(); ok {
+		s.opponentBackend.ReceiveAttack(attack)
+	}
+	if attack, ok := s.opponentBackend.TakeAttack(); ok {
+		// The attack is applied in place of an input at the start of an update,
+		// so it can't land in the middle of a move
+		s.attacks = append(s.attacks, attack)
+	}
 }
 
 // Exit deinitialises the screen.
@@ -238,7 +392,7 @@ func (s *MultiplayerScreen) Exit() {
 	s.backend.Timer.Pause()
 
 	if err := s.backend.Save(); err != nil {
-		panic(err)
+		log.Printf("Failed to save game: %v\n", err)
 	}
 
 	s.win.UnregisterKeybind(gogl.KeyUp, gogl.KeyPress)
@@ -251,6 +405,8 @@ func (s *MultiplayerScreen) Exit() {
 		s.server.Destroy()
 	} else if s.client != nil {
 		s.client.Destroy()
+	} else if s.bot != nil {
+		s.bot.stop()
 	}
 
 	s.arena.Destroy()
@@ -261,25 +417,46 @@ func (s *MultiplayerScreen) Update() {
 	s.win.SetBackground(s.backgroundColour)
 
 	// Handle user inputs from user. Only 1 input must be sent per update cycle,
-	// because the frontend can only animate one move at a time.
-	select {
-	case inputFunc := <-s.arenaInputCh:
-		inputFunc()
-		if err := s.sendGameData(); err != nil {
+	// because the frontend can only animate one move at a time. A waiting attack
+	// takes the place of an input.
+	if len(s.attacks) > 0 {
+		s.receiveAttack(s.attacks[0])
+		s.attacks = s.attacks[1:]
+	} else {
+		select {
+		case inputFunc := <-s.arenaInputCh:
+			inputFunc()
+		default:
+			// No user input; continue
+		}
+	}
+
+	// Check for win or lose
+	isLoss, isWin := s.outcome()
+
+	// The bot stops playing once the game has ended, like a human opponent would
+	if s.bot != nil && !isLoss && !isWin {
+		s.bot.update()
+	}
+	if s.client == nil {
+		s.exchangeAttacks()
+	}
+	if s.server != nil {
+		if err := s.sendActions(); err != nil {
 			log.Println("Failed to send game update:", err)
 		}
-	default:
-		// No user input; continue
+	}
+
+	// The game is sent to be checked after the final update, so the opponent's
+	// copy of it is complete by the time it arrives
+	if isLoss || isWin {
+		s.finishGame(!isLoss)
 	}
 
 	// Deep copy so front-end has time to animate itself whilst allowing the back
 	// end to update
-	s.arena.Update(deep.MustCopy(*s.backend))
-	s.opponentArena.Update(deep.MustCopy(*s.opponentBackend))
-
-	// Check for win or lose
-	isLoss := s.backend.Grid.Outcome() == grid.Lose || s.opponentBackend.Grid.Outcome() == grid.Win
-	isWin := s.backend.Grid.Outcome() == grid.Win || s.opponentBackend.Grid.Outcome() == grid.Lose
+	s.arena.Update(s.backend.Snapshot())
+	s.opponentArena.Update(s.opponentBackend.Snapshot())
 	switch {
 	case isLoss:
 		s.updateLose()
@@ -302,7 +479,7 @@ func (s *MultiplayerScreen) updateNormal() {
 	s.newGame.Update(s.win)
 	s.menu.Update(s.win)
 	s.score.SetBody(strconv.Itoa(s.backend.Score))
-	s.timer.SetText(s.backend.Timer.Time.String())
+	s.timer.SetText(s.backend.Timer.String())
 	s.opponentScore.SetBody(strconv.Itoa(s.opponentBackend.Score))
 
 	for _, d := range []gogl.Drawable{
@@ -319,6 +496,10 @@ func (s *MultiplayerScreen) updateNormal() {
 	} {
 		s.win.Draw(d)
 	}
+
+	if time.Now().Before(s.alertUntil) {
+		s.win.Draw(s.attackAlert)
+	}
 }
 
 // updateWin updates and draws the singleplayer screen in a winning state.
@@ -343,11 +524,94 @@ func (s *MultiplayerScreen) updateLose() {
 	s.updateGameEnd()
 }
 
+// finishGame sends the player's game to the opponent to be checked, and records
+// the result once the opponent's game has checked out. A game against a bot is
+// recorded straight away.
+func (s *MultiplayerScreen) finishGame(won bool) {
+	if s.resultRecorded || s.unverified {
+		return
+	}
+	if s.bot != nil {
+		s.recordResult(won)
+		return
+	}
+
+	// The guest's copy of its game is only sent once it matches the host's
+	if !s.verifySent && !s.resyncing {
+		s.verifySent = true
+		if err := s.sendVerify(); err != nil {
+			log.Println("Failed to send game to be verified:", err)
+		}
+	}
+	if s.opponentClaim == nil {
+		return
+	}
+	if err := s.verifyOpponent(*s.opponentClaim); err != nil {
+		log.Printf("Suspected cheating: %v. Not recording result\n", err)
+		s.unverified = true
+		s.endGameDialog.SetText(fmt.Sprintf("Couldn't verify %s's game\nResult not recorded", s.opponentName))
+		return
+	}
+	s.recordResult(won)
+}
+
+// sendVerify sends the opponent the player's seed and history, so they can check
+// the player's game.
+func (s *MultiplayerScreen) sendVerify() error {
+	msg, err := comms.VerifyData{
+		Seed:     s.backend.Grid.Seed,
+		Specials: s.backend.Grid.Specials,
+		Score:    s.backend.Score,
+		History:  s.backend.History,
+	}.Serialise()
+	if err != nil {
+		return fmt.Errorf("failed to serialise verify data: %w", err)
+	}
+	return s.sendToOpponent(msg)
+}
+
+// verifyOpponent checks that the opponent's history could really have been played
+// from the seed chosen for their game, and that it reaches the score which the
+// opponent reported and the player saw.
+func (s *MultiplayerScreen) verifyOpponent(claim comms.VerifyData) error {
+	if claim.Seed != s.opponentSeed {
+		return fmt.Errorf("opponent's game was made from seed %d instead of %d", claim.Seed, s.opponentSeed)
+	}
+	if claim.Specials != s.opponentBackend.Grid.Specials {
+		return fmt.Errorf("opponent's game had special tiles set to %t instead of %t", claim.Specials, s.opponentBackend.Grid.Specials)
+	}
+	score, err := backend.Verify(
+		claim.Seed, s.opponentBackend.Grid.Width(), s.opponentBackend.Grid.Height(), claim.Specials, claim.History,
+	)
+	if err != nil {
+		return fmt.Errorf("opponent's history can't be played: %w", err)
+	}
+	if score != claim.Score {
+		return fmt.Errorf("opponent reported a score of %d, but their history scores %d", claim.Score, score)
+	}
+	if score != s.opponentBackend.Score {
+		return fmt.Errorf("opponent's history scores %d, but their game showed %d", score, s.opponentBackend.Score)
+	}
+	return nil
+}
+
+// recordResult adds the result of the game to the player's statistics.
+func (s *MultiplayerScreen) recordResult(won bool) {
+	s.resultRecorded = true
+
+	r := s.backend.Result()
+	r.Won = won
+	r.Opponent = s.opponentName
+	if err := backend.RecordResult(profile.StatsFile(), r); err != nil {
+		log.Println("Failed to record result:", err)
+	}
+}
+
 // updateGameEnd draws the appropriate game widgets for when the game has ended.
 func (s *MultiplayerScreen) updateGameEnd() {
 	s.menu.Update(s.win)
 	s.score.SetBody(strconv.Itoa(s.backend.Score))
-	s.timer.SetText(s.backend.Timer.Time.String())
+	s.timer.SetText(s.backend.Timer.String())
 	s.backend.Timer.Pause()
 	s.opponentScore.SetBody(strconv.Itoa(s.opponentBackend.Score))
 
@@ -366,8 +630,13 @@ func (s *MultiplayerScreen) updateGameEnd() {
 	}
 }
 
-// sendToOpponent sends bytes to the opponent.
+// sendToOpponent sends bytes to the opponent. Does nothing if the opponent is a
+// bot, because the bot reads the game state directly.
 func (s *MultiplayerScreen) sendToOpponent(b []byte) error {
+	if s.bot != nil {
+		return nil
+	}
+
 	if s.server != nil {
 		for _, id := range s.server.GetClientIDs() {
 			if err := s.server.WriteToClient(id, b); err != nil {
@@ -382,111 +651,286 @@ func (s *MultiplayerScreen) sendToOpponent(b []byte) error {
 	return nil
 }
 
-// handleOpponentData handles data from the opponent.
+// handleOpponentData handles data from the opponent. Data is handled alongside
+// the player's inputs, so the games are only changed by the screen's own thread.
 func (s *MultiplayerScreen) handleOpponentData(data []byte) error {
 	msg, err := comms.ParseMessage(data)
 	if err != nil {
 		return fmt.Errorf("failed to parse message: %w", err)
 	}
 
+	// Only the host makes moves, and only the guest receives updates
+	isUpdate := msg.Type == comms.TypeActionData || msg.Type == comms.TypeSyncData
+	if (msg.Type == comms.TypeMoveData && s.server == nil) || (isUpdate && s.client == nil) {
+		return fmt.Errorf("unexpected message type \"%s\"", msg.Type)
+	}
+
 	switch msg.Type {
-	case comms.TypeGameData:
-		gameData, err := comms.ParseGameData(msg.Content)
+	case comms.TypeMoveData:
+		moveData, err := comms.ParseMoveData(msg.Content)
 		if err != nil {
-			return fmt.Errorf("failed to parse game data: %w", err)
+			return fmt.Errorf("failed to parse move data: %w", err)
 		}
-		return s.handleGameData(gameData)
+		s.arenaInputCh <- func() { s.handleMoveData(moveData) }
+
+	case comms.TypeActionData:
+		actionData, err := comms.ParseActionData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse action data: %w", err)
+		}
+		s.arenaInputCh <- func() { s.handleActionData(actionData) }
+
+	case comms.TypeSyncData:
+		syncData, err := comms.ParseSyncData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse sync data: %w", err)
+		}
+		if syncData.Host.Grid == nil || syncData.Host.Timer == nil ||
+			syncData.Guest.Grid == nil || syncData.Guest.Timer == nil {
+			return errors.New("sync data is missing a game")
+		}
+		s.arenaInputCh <- func() { s.handleSyncData(syncData) }
+
+	case comms.TypeVerifyData:
+		verifyData, err := comms.ParseVerifyData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse verify data: %w", err)
+		}
+		s.arenaInputCh <- func() { s.opponentClaim = &verifyData }
 
 	case comms.TypeEventData:
 		eventData, err := comms.ParseEventData(msg.Content)
 		if err != nil {
 			return fmt.Errorf("failed to parse event data: %w", err)
 		}
-		return s.handleEventData(eventData)
+		s.arenaInputCh <- func() { s.handleEventData(eventData) }
 
 	case comms.TypeRequestData:
 		requestData, err := comms.ParseRequestData(msg.Content)
 		if err != nil {
 			return fmt.Errorf("failed to parse request data: %w", err)
 		}
-		return s.handleRequest(requestData)
+		s.arenaInputCh <- func() { s.handleRequest(requestData) }
 
 	default:
 		return fmt.Errorf("unsupported message type \"%s\"", msg.Type)
 	}
+	return nil
+}
+
+// handleMoveData makes a guest's move in the host's copy of the guest's game. A
+// move made from a grid which doesn't match the host's copy is rejected as
+// suspected cheating, and the guest is sent the host's games.
+func (s *MultiplayerScreen) handleMoveData(data comms.MoveData) {
+	s.moveSeq = max(s.moveSeq, data.Seq)
+
+	var problem string
+	if checksum, ok := s.checksums[data.Seen]; !ok || checksum != data.Checksum {
+		problem = fmt.Sprintf("guest's game doesn't match the host's copy after update %d", data.Seen)
+	} else if !grid.ValidDirection(data.Dir) {
+		problem = fmt.Sprintf("guest moved in invalid direction %q", data.Dir)
+	}
+
+	// The guest only moves again once a later update has acknowledged this move,
+	// so the checksums up to this update won't be needed again
+	for seq := range s.checksums {
+		if seq <= data.Seen {
+			delete(s.checksums, seq)
+		}
+	}
+	if problem != "" {
+		log.Printf("Suspected cheating: %s. Resyncing guest\n", problem)
+		if err := s.sendSync(); err != nil {
+			log.Println("Failed to resync guest:", err)
+		}
+		return
+	}
+
+	// Moves made once the game has ended are ignored, as the guest may not have
+	// seen the end yet
+	if isLoss, isWin := s.outcome(); !isLoss && !isWin {
+		s.opponentBackend.ExecuteMove(data.Dir)
+		s.exchangeAttacks()
+	}
+	if err := s.sendActions(); err != nil {
+		log.Println("Failed to send game update:", err)
+	}
 }
 
-// sendGameData sends the local game state to the opponent.
-func (s *MultiplayerScreen) sendGameData() error {
-	msg, err := comms.GameData{
-		Game: *s.backend,
+// sendActions sends the guest an update containing the actions made in both games
+// since the previous update. Nothing is sent if there are no actions to send and
+// every move has been acknowledged.
+func (s *MultiplayerScreen) sendActions() error {
+	hostActions, guestActions := s.backend.TakeActions(), s.opponentBackend.TakeActions()
+	if len(hostActions) == 0 && len(guestActions) == 0 && s.ack == s.moveSeq {
+		return nil
+	}
+
+	s.seq++
+	s.ack = s.moveSeq
+	s.checksums[s.seq] = s.opponentBackend.Checksum()
+	msg, err := comms.ActionData{
+		Seq:           s.seq,
+		Ack:           s.ack,
+		Host:          hostActions,
+		Guest:         guestActions,
+		HostChecksum:  s.backend.Checksum(),
+		GuestChecksum: s.checksums[s.seq],
 	}.Serialise()
 	if err != nil {
-		return fmt.Errorf("failed to serialise game data: %w", err)
+		return fmt.Errorf("failed to serialise action data: %w", err)
 	}
-
 	return s.sendToOpponent(msg)
 }
 
-// handleGameData handles incoming game data from the opponent.
-func (s *MultiplayerScreen) handleGameData(data comms.GameData) error {
-	s.opponentBackend = &data.Game
-	return nil
+// handleActionData plays the host's update on the guest's copies of both games.
+// If the games don't match the host's afterwards, the guest asks the host for its
+// games.
+func (s *MultiplayerScreen) handleActionData(data comms.ActionData) {
+	if s.resyncing {
+		// The host's games will include the update
+		return
+	}
+	s.seq, s.ack = data.Seq, data.Ack
+
+	for _, action := range data.Host {
+		if err := s.opponentBackend.Apply(action); err != nil {
+			s.requestSync(fmt.Errorf("failed to play host's action: %w", err))
+			return
+		}
+	}
+	for _, action := range data.Guest {
+		if err := s.backend.Apply(action); err != nil {
+			s.requestSync(fmt.Errorf("failed to play own action: %w", err))
+			return
+		}
+		if action.Type == backend.ActionAttack {
+			s.alertAttack(len(action.Blockers))
+		}
+	}
+
+	if s.backend.Checksum() != data.GuestChecksum || s.opponentBackend.Checksum() != data.HostChecksum {
+		s.requestSync(fmt.Errorf("games don't match the host's after update %d", data.Seq))
+	}
 }
 
-// sendScreenLoadedEvent sends the screen loaded event to the opponent.
-func (s *MultiplayerScreen) sendScreenLoadedEvent() error {
-	msg, err := comms.EventData{
-		Event: comms.EventScreenLoaded,
+// sendSync sends the guest the whole state of both games, replacing the guest's
+// copies.
+func (s *MultiplayerScreen) sendSync() error {
+	// The actions made so far are part of the games' state
+	s.backend.TakeActions()
+	s.opponentBackend.TakeActions()
+
+	s.seq++
+	s.ack = s.moveSeq
+	s.checksums[s.seq] = s.opponentBackend.Checksum()
+	msg, err := comms.SyncData{
+		Seq:   s.seq,
+		Ack:   s.ack,
+		Host:  *s.backend,
+		Guest: *s.opponentBackend,
 	}.Serialise()
 	if err != nil {
-		return fmt.Errorf("failed to serialise event data: %w", err)
+		return fmt.Errorf("failed to serialise sync data: %w", err)
 	}
-
 	return s.sendToOpponent(msg)
 }
 
-// handleEventData handles incoming game data from the opponent.
-func (s *MultiplayerScreen) handleEventData(data comms.EventData) error {
-	switch data.Event {
-	case comms.EventScreenLoaded:
-		// Send game data to opponent
-		if err := s.sendGameData(); err != nil {
-			return fmt.Errorf("failed to send game data: %w", err)
-		}
+// handleSyncData replaces the guest's copies of both games with the host's.
+func (s *MultiplayerScreen) handleSyncData(data comms.SyncData) {
+	s.seq, s.ack = data.Seq, data.Ack
+	s.resyncing = false
 
-		// Request for opponent to send their game data
-		if err := s.requestOpponentGameData(); err != nil {
-			return fmt.Errorf("failed to request opponent's game data: %w", err)
+	s.backend.Adopt(&data.Guest)
+	s.opponentBackend.Adopt(&data.Host)
+	for _, g := range []*backend.Game{s.backend, s.opponentBackend} {
+		if !g.Over() {
+			g.Timer.Resume()
 		}
 	}
-
-	return nil
 }
 
-// requestOpponentData sends a request for the opponent to send their game data.
-func (s *MultiplayerScreen) requestOpponentGameData() error {
+// requestSync asks the host to send its games, because the guest's copies of them
+// no longer match.
+func (s *MultiplayerScreen) requestSync(reason error) {
+	log.Printf("Out of sync with host: %v. Resyncing\n", reason)
+	s.resyncing = true
+
 	msg, err := comms.RequestData{
-		Request: comms.TypeGameData,
+		Request: comms.TypeSyncData,
 	}.Serialise()
 	if err != nil {
-		return fmt.Errorf("failed to serialise request data: %w", err)
+		log.Println("Failed to serialise request data:", err)
+		return
 	}
-
 	if err := s.sendToOpponent(msg); err != nil {
-		return fmt.Errorf("failed to send data to opponent: %w", err)
+		log.Println("Failed to request resync:", err)
 	}
+}
 
-	return nil
+// receiveAttack places an attack on the player's grid and alerts the player. An
+// attack which arrives after the game has ended is dropped.
+func (s *MultiplayerScreen) receiveAttack(attack backend.Attack) {
+	if isLoss, isWin := s.outcome(); isLoss || isWin {
+		return
+	}
+	s.backend.ReceiveAttack(attack)
+	s.alertAttack(attack.Blockers)
+}
+
+// alertAttack alerts the player to blockers landing on their grid.
+func (s *MultiplayerScreen) alertAttack(blockers int) {
+	s.attackAlert.SetText(fmt.Sprintf("INCOMING!\n%d BLOCKERS", blockers))
+	s.alertUntil = time.Now().Add(time.Second)
+}
+
+// sendEvent sends an event to the opponent.
+func (s *MultiplayerScreen) sendEvent(event comms.Event) error {
+	msg, err := comms.EventData{
+		Event: event,
+	}.Serialise()
+	if err != nil {
+		return fmt.Errorf("failed to serialise event data: %w", err)
+	}
+
+	return s.sendToOpponent(msg)
+}
+
+// handleEventData handles an incoming event from the opponent.
+func (s *MultiplayerScreen) handleEventData(data comms.EventData) {
+	switch data.Event {
+	case comms.EventScreenLoaded:
+		// Whichever player loads the screen last starts the guest off with the
+		// host's games
+		if s.server != nil {
+			if err := s.sendSync(); err != nil {
+				log.Println("Failed to send games to guest:", err)
+			}
+		} else if !s.resyncing {
+			s.requestSync(errors.New("host has just loaded the game"))
+		}
+
+	case comms.EventRestart:
+		if s.server == nil {
+			log.Println("Ignoring restart from host")
+			return
+		}
+		s.opponentBackend.ResetKeepTimer()
+		if err := s.sendSync(); err != nil {
+			log.Println("Failed to send restarted game to guest:", err)
+		}
+	}
 }
 
 // handleRequest handles an incoming request for data.
-func (s *MultiplayerScreen) handleRequest(data comms.RequestData) error {
+func (s *MultiplayerScreen) handleRequest(data comms.RequestData) {
 	switch data.Request {
-	case comms.TypeGameData:
-		if err := s.sendGameData(); err != nil {
-			return fmt.Errorf("failed to send game data: %w", err)
+	case comms.TypeSyncData:
+		if s.server == nil {
+			return
+		}
+		if err := s.sendSync(); err != nil {
+			log.Println("Failed to resync guest:", err)
 		}
 	}
-	return nil
 }
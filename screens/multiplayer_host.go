@@ -1,12 +1,18 @@
 package screens
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
+	"math"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/moby/moby/pkg/namesgenerator"
 	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/backend"
 	"github.com/z-riley/go-2048-battle/common/comms"
 	"github.com/z-riley/go-2048-battle/config"
 	"github.com/z-riley/go-2048-battle/log"
@@ -14,9 +20,15 @@ import (
 	"github.com/z-riley/servesyouright"
 )
 
-const (
-	serverPort = 8080
-)
+// hostPort is the port to host games on, if it was chosen when the game was
+// launched. It overrides the port chosen in the host lobby.
+var hostPort uint16
+
+// SetHostPort makes games be hosted on the given port, instead of the port chosen
+// in the host lobby.
+func SetHostPort(port uint16) {
+	hostPort = port
+}
 
 type MultiplayerHostScreen struct {
 	win *gogl.Window
@@ -25,6 +37,12 @@ type MultiplayerHostScreen struct {
 	tooltip          *gogl.TextBox
 	nameHeading      *gogl.Text
 	nameEntry        *common.EntryBox
+	portHeading      *gogl.Text
+	portEntry        *common.EntryBox
+	boardSize        *gogl.Button
+	target           *gogl.Button
+	rules            *gogl.Button
+	settings         comms.SettingsData
 	opponentName     string
 	opponentStatus   *gogl.Text
 	start            *gogl.Button
@@ -32,7 +50,13 @@ type MultiplayerHostScreen struct {
 	buttonBackground *gogl.CurvedRect
 
 	server            *servesyouright.Server
+	port              uint16            // the port the server listens on
+	advertiser        *comms.Advertiser // advertises the lobby on the local network
 	opponentIsInLobby bool
+
+	// A guest must agree how to play in a handshake before it can join
+	agreement         *comms.Agreement // how the host and guest play, once they have agreed
+	incompatibleUntil time.Time        // when the message about an incompatible guest stops being shown
 }
 
 // NewMultiplayerHostScreen constructs an uninitialised multiplayer host screen.
@@ -49,9 +73,18 @@ func (s *MultiplayerHostScreen) Enter(_ InitData) {
 
 	s.tooltip = common.NewTooltip()
 
+	// The name and port are side by side
+	const (
+		nameWidth = 440
+		portWidth = 140
+		entryGap  = 20
+		nameX     = (config.WinWidth - nameWidth - portWidth - entryGap) / 2
+		portX     = nameX + nameWidth + entryGap
+	)
+
 	s.nameHeading = gogl.NewText(
 		"Your name:",
-		gogl.Vec{X: config.WinWidth / 2, Y: 300},
+		gogl.Vec{X: nameX + nameWidth/2, Y: 300},
 		common.FontPathMedium,
 	).
 		SetColour(common.GreyTextColour).
@@ -59,19 +92,79 @@ func (s *MultiplayerHostScreen) Enter(_ InitData) {
 		SetSize(30)
 
 	s.nameEntry = common.NewEntryBox(
-		440, 60,
-		gogl.Vec{X: (config.WinWidth - 440) / 2, Y: s.nameHeading.Pos().Y + 30},
-		namesgenerator.GetRandomName(0),
+		nameWidth, 60,
+		gogl.Vec{X: nameX, Y: s.nameHeading.Pos().Y + 30},
+		profile.Name,
 	).
 		SetModifiedCB(func() {
 			// Update guest with new username
 			if err := s.sendPlayerData(); err != nil {
 				log.Println("Failed to send username update to guests:", err)
 			}
+			s.advertise()
 		})
 
+	// A port given when the game was launched takes priority over the player's
+	// preference. The port can be changed whilst waiting for an opponent
+	s.port = cmp.Or(hostPort, profile.Prefs.Port, comms.DefaultPort)
+	s.portHeading = gogl.NewText(
+		"Port:",
+		gogl.Vec{X: portX + portWidth/2, Y: 300},
+		common.FontPathMedium,
+	).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(30)
+
+	s.portEntry = common.NewEntryBox(
+		portWidth, 60,
+		gogl.Vec{X: portX, Y: s.portHeading.Pos().Y + 30},
+		strconv.Itoa(int(s.port)),
+	)
+
+	s.settings = comms.SettingsData{
+		Width:  profile.Prefs.Width,
+		Height: profile.Prefs.Height,
+		Target: profile.Prefs.Target,
+		Battle: profile.Prefs.Battle,
+	}
+	const (
+		settingWidth = 200
+		settingGap   = 10
+	)
+	s.boardSize = common.NewGameButton(
+		settingWidth, 0.5*common.TileSizePx,
+		gogl.Vec{X: config.WinWidth/2 - 1.5*settingWidth - settingGap, Y: 425},
+		func() {
+			size := nextBoardSize(boardSize{s.settings.Width, s.settings.Height})
+			s.settings.Width, s.settings.Height = size.width, size.height
+			s.boardSize.SetLabelText("BOARD SIZE: " + size.String())
+			s.settingsChanged()
+		},
+	).SetLabelText("BOARD SIZE: " + boardSize{s.settings.Width, s.settings.Height}.String())
+
+	s.target = common.NewGameButton(
+		settingWidth, 0.5*common.TileSizePx,
+		gogl.Vec{X: (config.WinWidth - settingWidth) / 2, Y: 425},
+		func() {
+			s.settings.Target = nextTarget(s.settings.Target)
+			s.target.SetLabelText(fmt.Sprintf("WIN TILE: %d", s.settings.Target))
+			s.settingsChanged()
+		},
+	).SetLabelText(fmt.Sprintf("WIN TILE: %d", s.settings.Target))
+
+	s.rules = common.NewGameButton(
+		settingWidth, 0.5*common.TileSizePx,
+		gogl.Vec{X: config.WinWidth/2 + 0.5*settingWidth + settingGap, Y: 425},
+		func() {
+			s.settings.Battle = !s.settings.Battle
+			s.rules.SetLabelText(rulesLabel(s.settings.Battle))
+			s.settingsChanged()
+		},
+	).SetLabelText(rulesLabel(s.settings.Battle))
+
 	s.opponentStatus = gogl.NewText(
-		fmt.Sprintf("Waiting for opponent to join \"%s\"", getIPAddr()),
+		"",
 		gogl.Vec{X: config.WinWidth / 2, Y: 510},
 		common.FontPathMedium,
 	).
@@ -126,44 +219,39 @@ func (s *MultiplayerHostScreen) Enter(_ InitData) {
 			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
 		},
 		func() {
-			s.server.Destroy()
+			s.stopServer()
 			SetScreen(MultiplayerMenu, nil)
 		},
 	).SetLabelText("Back")
 
 	s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
-		s.server.Destroy()
+		s.stopServer()
 		SetScreen(MultiplayerMenu, nil)
 	})
 
-	// Set up server
-	const maxClients = 1
-	s.server = servesyouright.NewServer(maxClients).
-		SetCallback(func(_ int, b []byte) {
-			if err := s.handleClientData(b); err != nil {
-				log.Println("Host screen failed to handle data from client:", err)
-			}
-		}).SetDisconnectCallback(func(_ int) { s.handleOpponentDisconnect() })
-
 	// Start server to allow other players to connect
-	errCh := make(chan error)
-	go func() {
-		for err := range errCh {
-			if err != nil {
-				// Exit the loop if the server dies
-				return
-			}
-		}
-	}()
-	if err := s.server.Start("0.0.0.0", serverPort, errCh); err != nil {
-		panic(err)
+	s.server = nil
+	s.agreement = nil
+	s.incompatibleUntil = time.Time{}
+	s.startServer()
+
+	// Guests on the local network can find the lobby without typing in the IP
+	// address, but can still join by IP address if it can't be advertised
+	advertiser, err := comms.StartAdvertising(s.lobby())
+	if err != nil {
+		log.Println("Failed to advertise lobby:", err)
 	}
+	s.advertiser = advertiser
 }
 
 // Exit deinitialises the screen.
 func (s *MultiplayerHostScreen) Exit() {
 	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
 	s.opponentIsInLobby = false
+	if s.advertiser != nil {
+		s.advertiser.Stop()
+		s.advertiser = nil
+	}
 }
 
 // Update updates and draws multiplayer host screen.
@@ -176,6 +264,7 @@ func (s *MultiplayerHostScreen) Update() {
 	for _, l := range []*gogl.Text{
 		s.opponentStatus,
 		s.nameHeading,
+		s.portHeading,
 	} {
 		s.win.Draw(l)
 	}
@@ -183,6 +272,9 @@ func (s *MultiplayerHostScreen) Update() {
 	for _, b := range []*gogl.Button{
 		s.start,
 		s.back,
+		s.boardSize,
+		s.target,
+		s.rules,
 	} {
 		b.Update(s.win)
 		s.win.Draw(b)
@@ -190,12 +282,96 @@ func (s *MultiplayerHostScreen) Update() {
 
 	s.win.Draw(s.nameEntry)
 	s.nameEntry.Update(s.win)
+	s.win.Draw(s.portEntry)
+	s.portEntry.Update(s.win)
+
+	// A new port is only used once the player has finished typing it
+	if !s.portEntry.TextBox.IsEditing() && s.portEntry.Text() != strconv.Itoa(int(s.port)) {
+		s.changePort()
+	}
 
 	mouseLoc := s.win.MouseLocation()
-	if s.nameEntry.TextBox.Shape.IsWithin(mouseLoc) && !s.nameEntry.TextBox.IsEditing() {
-		s.tooltip.SetPos(gogl.Vec{X: mouseLoc.X, Y: mouseLoc.Y - s.tooltip.Shape.Height()})
-		s.win.Draw(s.tooltip)
+	for _, e := range []*common.EntryBox{s.nameEntry, s.portEntry} {
+		if e.TextBox.Shape.IsWithin(mouseLoc) && !e.TextBox.IsEditing() {
+			s.tooltip.SetPos(gogl.Vec{X: mouseLoc.X, Y: mouseLoc.Y - s.tooltip.Shape.Height()})
+			s.win.Draw(s.tooltip)
+		}
+	}
+}
+
+// startServer starts the server on the chosen port, so other players can connect.
+// If the port is in use, a free port is chosen instead. The player is told if the
+// server can't be started.
+func (s *MultiplayerHostScreen) startServer() {
+	const maxClients = 1
+	server := servesyouright.NewServer(maxClients).
+		SetCallback(func(_ int, b []byte) {
+			if err := s.handleClientData(b); err != nil {
+				log.Println("Host screen failed to handle data from client:", err)
+			}
+		}).SetDisconnectCallback(func(_ int) { s.handleOpponentDisconnect() })
+
+	errCh := make(chan error)
+	go func() {
+		for err := range errCh {
+			if err != nil {
+				// Exit the loop if the server dies
+				return
+			}
+		}
+	}()
+
+	// An empty host listens on every interface, for both IPv4 and IPv6
+	err := server.Start("", s.port, errCh)
+	if err != nil {
+		log.Printf("Failed to host on port %d: %v. Trying a free port\n", s.port, err)
+		port, freeErr := comms.FreePort()
+		if freeErr == nil {
+			err = server.Start("", port, errCh)
+		}
+		if freeErr != nil || err != nil {
+			log.Println("Failed to start server:", cmp.Or(freeErr, err))
+			s.opponentStatus.SetText(fmt.Sprintf("Couldn't host on port %d. Try another port", s.port))
+			return
+		}
+		s.port = port
+	}
+
+	s.server = server
+	s.portEntry.SetText(strconv.Itoa(int(s.port)))
+	s.opponentStatus.SetText(s.waitingMessage())
+	s.advertise()
+}
+
+// stopServer stops the server, disconnecting the opponent.
+func (s *MultiplayerHostScreen) stopServer() {
+	if s.server != nil {
+		s.server.Destroy()
+		s.server = nil
+	}
+}
+
+// changePort restarts the server on the port typed in by the player, and saves it
+// as the player's preference. An invalid port is put back to the current port.
+func (s *MultiplayerHostScreen) changePort() {
+	port, err := comms.ParsePort(s.portEntry.Text())
+	if err != nil {
+		log.Println("Failed to change port:", err)
+		s.portEntry.SetText(strconv.Itoa(int(s.port)))
+		return
+	}
+
+	profile.Prefs.Port = port
+	if err := profile.Save(); err != nil {
+		log.Println("Failed to save preferences:", err)
 	}
+
+	// The opponent is disconnected, and must join again on the new port
+	s.stopServer()
+	s.opponentIsInLobby = false
+	s.agreement = nil
+	s.port = port
+	s.startServer()
 }
 
 // handleClientData handles all data received from a client.
@@ -206,6 +382,13 @@ func (s *MultiplayerHostScreen) handleClientData(data []byte) error {
 	}
 
 	switch msg.Type {
+	case comms.TypeHandshakeData:
+		data, err := comms.ParseHandshakeData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse handshake data: %w", err)
+		}
+		return s.handleHandshakeData(data)
+
 	case comms.TypePlayerData:
 		data, err := comms.ParsePlayerData(msg.Content)
 		if err != nil {
@@ -219,10 +402,52 @@ func (s *MultiplayerHostScreen) handleClientData(data []byte) error {
 	}
 }
 
+// settingsChanged saves the game settings as the player's preferences and sends
+// them to the guests.
+func (s *MultiplayerHostScreen) settingsChanged() {
+	profile.Prefs = backend.Prefs{
+		Width:  s.settings.Width,
+		Height: s.settings.Height,
+		Target: s.settings.Target,
+		Battle: s.settings.Battle,
+		Port:   profile.Prefs.Port,
+	}
+	if err := profile.Save(); err != nil {
+		log.Println("Failed to save preferences:", err)
+	}
+
+	// Update guest with new settings
+	if err := s.sendSettingsData(); err != nil {
+		log.Println("Failed to send settings update to guests:", err)
+	}
+	s.advertise()
+}
+
+// lobby returns the description of the lobby which is advertised to guests.
+func (s *MultiplayerHostScreen) lobby() comms.LobbyData {
+	return comms.LobbyData{
+		Protocol: comms.Protocol,
+		Name:     s.nameEntry.Text(),
+		Port:     s.port,
+		Open:     !s.opponentIsInLobby,
+		Settings: s.settings,
+	}
+}
+
+// advertise updates the lobby which is advertised to guests.
+func (s *MultiplayerHostScreen) advertise() {
+	if s.advertiser != nil {
+		s.advertiser.SetLobby(s.lobby())
+	}
+}
+
 // sendPlayerData sends the player data to all connected guests.
 func (s *MultiplayerHostScreen) sendPlayerData() error {
+	if s.server == nil {
+		// There are no guests without a server
+		return nil
+	}
 	msg, err := comms.PlayerData{
-		Version:  config.Version,
 		Username: s.nameEntry.Text(),
 	}.Serialise()
 	if err != nil {
@@ -239,11 +464,39 @@ func (s *MultiplayerHostScreen) sendPlayerData() error {
 	return nil
 }
 
+// sendSettingsData sends the game settings to all connected guests.
+func (s *MultiplayerHostScreen) sendSettingsData() error {
+	if s.server == nil {
+		// There are no guests without a server
+		return nil
+	}
+	msg, err := s.settings.Serialise()
+	if err != nil {
+		return fmt.Errorf("failed to serialise settings data: %w", err)
+	}
+
+	// Send data to client
+	for _, id := range s.server.GetClientIDs() {
+		if err := s.server.WriteToClient(id, msg); err != nil {
+			return fmt.Errorf("failed to send message to client: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// newSeed returns a random seed for a game. Seeds are never zero, because games
+// seeded with zero get a random seed of their own.
+func newSeed() int64 {
+	return rand.Int64N(math.MaxInt64) + 1
+}
+
 // handlePlayerData handles incoming player data.
 func (s *MultiplayerHostScreen) handlePlayerData(data comms.PlayerData) error {
-	// Make sure versions are compatible
-	if data.Version != config.Version {
-		return fmt.Errorf("incompatible versions (peer %s, local %s)", data.Version, config.Version)
+	// Guests from before the handshake existed send their player data first
+	if s.agreement == nil {
+		s.showIncompatible("an older version")
+		return errors.New("guest joined without a handshake")
 	}
 
 	s.opponentName = data.Username
@@ -251,19 +504,93 @@ func (s *MultiplayerHostScreen) handlePlayerData(data comms.PlayerData) error {
 		fmt.Sprintf("\"%s\" has joined the game. Press Start to begin", s.opponentName),
 	)
 	s.opponentIsInLobby = true
+	s.advertise()
 
-	// Send host player data to client
+	// Send host player data and game settings to client
 	if err := s.sendPlayerData(); err != nil {
 		return fmt.Errorf("failed to send player data to client: %w", err)
 	}
+	if err := s.sendSettingsData(); err != nil {
+		return fmt.Errorf("failed to send settings data to client: %w", err)
+	}
+
+	return nil
+}
+
+// handleHandshakeData agrees how to play with a guest which has just connected. The
+// host's handshake is sent back either way, so the guest can tell whether it can
+// join.
+func (s *MultiplayerHostScreen) handleHandshakeData(data comms.HandshakeData) error {
+	if err := s.sendHandshakeData(); err != nil {
+		return fmt.Errorf("failed to send handshake to client: %w", err)
+	}
 
+	agreement, err := comms.Negotiate(comms.NewHandshake(), data)
+	if err != nil {
+		s.showIncompatible("version " + data.AppVersion)
+		return err
+	}
+	s.agreement = &agreement
 	return nil
 }
 
+// sendHandshakeData sends the host's handshake to all connected guests.
+func (s *MultiplayerHostScreen) sendHandshakeData() error {
+	if s.server == nil {
+		// There are no guests without a server
+		return nil
+	}
+	msg, err := comms.NewHandshake().Serialise()
+	if err != nil {
+		return fmt.Errorf("failed to serialise handshake data: %w", err)
+	}
+	for _, id := range s.server.GetClientIDs() {
+		if err := s.server.WriteToClient(id, msg); err != nil {
+			return fmt.Errorf("failed to send message to client: %w", err)
+		}
+	}
+	return nil
+}
+
+// incompatibleMessageTime is how long the player is shown that a guest's game is
+// incompatible.
+const incompatibleMessageTime = 5 * time.Second
+
+// showIncompatible tells the player that a guest tried to join with a game which
+// can't play theirs. The message stays after the guest disconnects, so the player
+// has time to read it.
+func (s *MultiplayerHostScreen) showIncompatible(guestVersion string) {
+	s.incompatibleUntil = time.Now().Add(incompatibleMessageTime)
+	s.opponentStatus.SetText(fmt.Sprintf(
+		"Opponent couldn't join: their game (%s) is incompatible with yours (version %s)",
+		guestVersion, config.Version,
+	))
+	go func() {
+		time.Sleep(incompatibleMessageTime)
+		if !s.opponentIsInLobby {
+			s.opponentStatus.SetText(s.waitingMessage())
+		}
+	}()
+}
+
 // handleOpponentDisconnect handles the opponent disconnecting from the server.
 func (s *MultiplayerHostScreen) handleOpponentDisconnect() {
-	s.opponentStatus.SetText(fmt.Sprintf("Waiting for opponent to join \"%s\"", getIPAddr()))
+	if time.Now().After(s.incompatibleUntil) {
+		s.opponentStatus.SetText(s.waitingMessage())
+	}
 	s.opponentIsInLobby = false
+	s.agreement = nil
+	s.advertise()
+}
+
+// waitingMessage returns the status message shown whilst waiting for an opponent,
+// which tells the player the address to join.
+func (s *MultiplayerHostScreen) waitingMessage() string {
+	addr := getIPAddr()
+	if comms.IsWSL() {
+		return fmt.Sprintf("Waiting for opponent to join %s on port %d", addr, s.port)
+	}
+	return fmt.Sprintf("Waiting for opponent to join \"%s\"", net.JoinHostPort(addr, strconv.Itoa(int(s.port))))
 }
 
 // getIPAddr returns the IP address of the host.
@@ -289,6 +616,21 @@ func (s *MultiplayerHostScreen) startGame() error {
 		return errors.New("opponent is not connected")
 	}
 
+	// Both players' games must support the settings
+	if missing := s.agreement.Missing(s.settings); len(missing) > 0 {
+		s.opponentStatus.SetText(fmt.Sprintf(
+			"\"%s\" can't play with %s. Change the settings to begin",
+			s.opponentName, capabilityNames(missing),
+		))
+		return fmt.Errorf("opponent's game doesn't support %v", missing)
+	}
+
+	// Both players' games are seeded by the host, so they start with the same grids
+	s.settings.HostSeed, s.settings.GuestSeed = newSeed(), newSeed()
+	if err := s.sendSettingsData(); err != nil {
+		return fmt.Errorf("failed to send settings: %w", err)
+	}
+
 	// Inform other players that game is starting
 	msg, err := comms.EventData{
 		Event: comms.EventHostStartGame,
@@ -307,6 +649,26 @@ func (s *MultiplayerHostScreen) startGame() error {
 		serverKey:           s.server,
 		usernameKey:         s.nameEntry.Text(),
 		opponentUsernameKey: s.opponentName,
+		settingsKey:         s.settings,
 	})
 	return nil
 }
+
+// capabilityNames returns the names of capabilities which can be shown to the
+// player.
+func capabilityNames(caps []comms.Capability) string {
+	names := make([]string, len(caps))
+	for i, c := range caps {
+		switch c {
+		case comms.CapBoardSize:
+			names[i] = "this board size"
+		case comms.CapModes:
+			names[i] = "this game mode"
+		case comms.CapAttacks:
+			names[i] = "battle rules"
+		default:
+			names[i] = string(c)
+		}
+	}
+	return strings.Join(names, " or ")
+}
@@ -2,12 +2,14 @@ package screens
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/moby/moby/pkg/namesgenerator"
 	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
 	"github.com/z-riley/go-2048-battle/common/backend/store"
 	"github.com/z-riley/go-2048-battle/common/comms"
 	"github.com/z-riley/go-2048-battle/config"
@@ -27,12 +29,24 @@ type MultiplayerJoinScreen struct {
 	ipStore          *store.Store
 	ipEntry          *common.EntryBox
 	opponentName     string
+	settings         comms.SettingsData
 	opponentStatus   *gogl.Text
 	join             *gogl.Button
 	back             *gogl.Button
 	buttonBackground *gogl.CurvedRect
 
+	// Lobbies advertised on the local network are listed so they can be joined
+	// without typing in the host's IP address
+	lobbyHeading *gogl.Text
+	lobbyHint    *gogl.Text // shown when there are no lobbies to list
+	browser      *comms.Browser
+	lobbies      []comms.Lobby // the lobbies in the list
+	lobbyButtons []*gogl.Button
+
 	client      *servesyouright.Client
+	connected   bool               // whether the client has connected to a host
+	disconnect  context.CancelFunc // stops the client's connection to the host
+	handshake   chan struct{}      // closed once the host has replied to the guest's handshake
 	hostIsReady chan bool
 	done        chan struct{}
 }
@@ -51,9 +65,41 @@ func (s *MultiplayerJoinScreen) Enter(_ InitData) {
 
 	s.tooltip = common.NewTooltip()
 
+	// The list of lobbies is on the left, and the player's details are on the right
+	const (
+		leftX  = config.WinWidth/2 - 270
+		rightX = config.WinWidth/2 + 270
+	)
+
+	s.lobbyHeading = gogl.NewText(
+		"Games on your network:",
+		gogl.Vec{X: leftX, Y: 250},
+		common.FontPathMedium,
+	).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(30)
+
+	s.lobbyHint = gogl.NewText(
+		"Searching for games...",
+		gogl.Vec{X: leftX, Y: 310},
+		common.FontPathMedium,
+	).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(22)
+
+	s.lobbies, s.lobbyButtons = nil, nil
+	browser, err := comms.NewBrowser()
+	if err != nil {
+		log.Println("Failed to search for games:", err)
+		s.lobbyHint.SetText("Couldn't search for games")
+	}
+	s.browser = browser
+
 	s.nameHeading = gogl.NewText(
 		"Your name:",
-		gogl.Vec{X: config.WinWidth / 2, Y: 250},
+		gogl.Vec{X: rightX, Y: 250},
 		common.FontPathMedium,
 	).
 		SetColour(common.GreyTextColour).
@@ -62,8 +108,8 @@ func (s *MultiplayerJoinScreen) Enter(_ InitData) {
 
 	s.nameEntry = common.NewEntryBox(
 		440, 60,
-		gogl.Vec{X: config.WinWidth/2 - 440/2, Y: s.nameHeading.Pos().Y + 30},
-		namesgenerator.GetRandomName(0),
+		gogl.Vec{X: rightX - 440/2, Y: s.nameHeading.Pos().Y + 30},
+		profile.Name,
 	).
 		SetModifiedCB(func() {
 			// Update host with new username
@@ -75,15 +121,15 @@ func (s *MultiplayerJoinScreen) Enter(_ InitData) {
 		})
 
 	s.ipHeading = gogl.NewText(
-		"Host IP:",
-		gogl.Vec{X: config.WinWidth / 2, Y: 380},
+		"Or enter host IP:",
+		gogl.Vec{X: rightX, Y: 380},
 		common.FontPathMedium,
 	).
 		SetColour(common.GreyTextColour).
 		SetAlignment(gogl.AlignCentre).
 		SetSize(30)
 
-	s.ipStore = store.NewStore(".ip.bruh")
+	s.ipStore = store.NewStore(store.ConfigPath(".ip.bruh"))
 	b, err := s.ipStore.ReadBytes()
 	if err != nil {
 		log.Println("Failed to read IP address store:", err)
@@ -92,7 +138,7 @@ func (s *MultiplayerJoinScreen) Enter(_ InitData) {
 
 	s.ipEntry = common.NewEntryBox(
 		440, 60,
-		gogl.Vec{X: (config.WinWidth - 440) / 2, Y: s.ipHeading.Pos().Y + 30},
+		gogl.Vec{X: rightX - 440/2, Y: s.ipHeading.Pos().Y + 30},
 		string(b),
 	).SetModifiedCB(func() {
 		if err := s.ipStore.SaveBytes([]byte(s.ipEntry.Text())); err != nil {
@@ -124,6 +170,12 @@ func (s *MultiplayerJoinScreen) Enter(_ InitData) {
 	)
 	s.buttonBackground.SetStyle(gogl.Style{Colour: common.ArenaBackgroundColour})
 
+	s.settings = comms.SettingsData{
+		Width:  defaultBoardSize.width,
+		Height: defaultBoardSize.height,
+		Target: grid.DefaultTarget,
+	}
+
 	s.hostIsReady = make(chan bool)
 	s.join = common.NewMenuButton(
 		TileSizePx, TileSizePx,
@@ -142,6 +194,9 @@ func (s *MultiplayerJoinScreen) Enter(_ InitData) {
 		},
 		func() {
 			s.join.SetLabelText("Join")
+			if s.disconnect != nil {
+				s.disconnect()
+			}
 			s.client.Destroy()
 			SetScreen(MultiplayerMenu, nil)
 		}).SetLabelText("Back")
@@ -161,6 +216,12 @@ func (s *MultiplayerJoinScreen) Enter(_ InitData) {
 func (s *MultiplayerJoinScreen) Exit() {
 	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
 	s.done <- struct{}{}
+	if s.browser != nil {
+		if err := s.browser.Close(); err != nil {
+			log.Println("Failed to stop searching for games:", err)
+		}
+		s.browser = nil
+	}
 }
 
 // Update updates and draws multiplayer join screen.
@@ -168,6 +229,7 @@ func (s *MultiplayerJoinScreen) Update() {
 	s.win.SetBackground(common.BackgroundColour)
 
 	s.win.Draw(s.title)
+	s.win.Draw(s.lobbyHeading)
 	s.win.Draw(s.ipHeading)
 	s.win.Draw(s.nameHeading)
 	s.win.Draw(s.opponentStatus)
@@ -181,6 +243,15 @@ func (s *MultiplayerJoinScreen) Update() {
 		s.win.Draw(b)
 	}
 
+	s.updateLobbies()
+	if len(s.lobbyButtons) == 0 {
+		s.win.Draw(s.lobbyHint)
+	}
+	for _, b := range s.lobbyButtons {
+		b.Update(s.win)
+		s.win.Draw(b)
+	}
+
 	for _, e := range []*common.EntryBox{
 		s.nameEntry,
 		s.ipEntry,
@@ -201,14 +272,79 @@ func (s *MultiplayerJoinScreen) Update() {
 // clientKey is used for indentifying the server in InitData.
 const clientKey = "client"
 
+// maxLobbies is the number of lobbies which fit in the list.
+const maxLobbies = 3
+
+// updateLobbies updates the list of lobbies to match the lobbies being advertised.
+// Lobbies which are full or play an incompatible protocol can't be joined, so they
+// aren't listed.
+func (s *MultiplayerJoinScreen) updateLobbies() {
+	if s.browser == nil {
+		return
+	}
+	var lobbies []comms.Lobby
+	for _, l := range s.browser.Lobbies() {
+		_, compatible := comms.Protocol.Common(l.Protocol)
+		if l.Open && compatible && len(lobbies) < maxLobbies {
+			lobbies = append(lobbies, l)
+		}
+	}
+	if slices.Equal(lobbies, s.lobbies) {
+		return
+	}
+
+	s.lobbies = lobbies
+	s.lobbyButtons = nil
+	const (
+		width  = 440
+		height = 55
+	)
+	for i, l := range lobbies {
+		rules := ""
+		if l.Settings.Battle {
+			rules = ", BATTLE"
+		}
+		label := fmt.Sprintf("%s - %s, %d%s",
+			l.Name, boardSize{l.Settings.Width, l.Settings.Height}, l.Settings.Target, rules,
+		)
+		button := common.NewGameButton(
+			width, height,
+			gogl.Vec{X: s.lobbyHeading.Pos().X - width/2, Y: 280 + float64(i)*(height+10)},
+			func() {
+				if !s.connected {
+					s.joinHost(l.Addr, l.Port)
+				}
+			},
+		).SetLabelText(label).SetLabelSize(18)
+		s.lobbyButtons = append(s.lobbyButtons, button)
+	}
+}
+
 // joinButtonHandler handles presses of the join button.
 func (s *MultiplayerJoinScreen) joinButtonHandler() {
+	host, port, err := comms.ParseAddr(s.ipEntry.Text(), comms.DefaultPort)
+	if err != nil {
+		s.opponentStatus.SetText("Enter an address such as 192.168.1.5 or 192.168.1.5:8080")
+		go func() {
+			time.Sleep(2 * time.Second)
+			s.opponentStatus.SetText("")
+		}()
+		log.Println("Failed to join game:", err)
+		return
+	}
+	s.joinHost(host, port)
+}
+
+// joinHost joins the game hosted at an address, and waits for the host to start
+// the game.
+func (s *MultiplayerJoinScreen) joinHost(addr string, port uint16) {
 	// Handle asynchronous errors from client
 	errCh := make(chan error)
 	go func() {
 		for err := range errCh {
 			if err != nil {
 				log.Println("Client error:", err)
+				s.connected = false
 
 				// Re-enable button
 				s.join.SetCallback(
@@ -227,7 +363,7 @@ func (s *MultiplayerJoinScreen) joinButtonHandler() {
 		}
 	}()
 
-	err := s.joinGame(errCh)
+	err := s.joinGame(addr, port, errCh)
 	if err != nil {
 		s.opponentStatus.SetText("Failed to connect to host")
 		go func() {
@@ -238,7 +374,8 @@ func (s *MultiplayerJoinScreen) joinButtonHandler() {
 		return
 	}
 
-	// Disable the button so user can't connect again
+	// Disable the buttons so user can't connect again
+	s.connected = true
 	s.join.SetCallback(
 		gogl.ButtonTrigger{State: gogl.LeftClick, Behaviour: gogl.OnRelease},
 		func() {},
@@ -250,33 +387,95 @@ func (s *MultiplayerJoinScreen) joinButtonHandler() {
 				clientKey:           s.client,
 				usernameKey:         s.nameEntry.Text(),
 				opponentUsernameKey: s.opponentName,
+				settingsKey:         s.settings,
 			})
 			return
 		}
 	}()
 }
 
-// joinGame attempts to join a multiplayer game.
-func (s *MultiplayerJoinScreen) joinGame(errCh chan error) error {
-	// Connect using the user-specified IP address
-	if err := s.client.Connect(context.Background(), s.ipEntry.Text(), serverPort, errCh); err != nil {
+// handshakeTimeout is how long the guest waits for the host to reply to its
+// handshake. Hosts from before the handshake existed never reply.
+const handshakeTimeout = 3 * time.Second
+
+// joinGame attempts to join a multiplayer game. The player data is sent once the
+// host and guest have agreed how to play.
+func (s *MultiplayerJoinScreen) joinGame(addr string, port uint16, errCh chan error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := s.client.Connect(ctx, addr, port, errCh); err != nil {
+		cancel()
 		return fmt.Errorf("failed to connect to server: %w", err)
 	}
+	s.disconnect = cancel
 
+	handshake := make(chan struct{})
+	s.handshake = handshake
 	s.client.SetCallback(func(b []byte) {
 		if err := s.handleServerData(b); err != nil {
 			log.Println("Join screen failed to handle data from server:", err)
 		}
 	})
 
-	// Send player data to host
+	msg, err := comms.NewHandshake().Serialise()
+	if err != nil {
+		return fmt.Errorf("failed to serialise handshake data: %w", err)
+	}
+	if err := s.client.Write(msg); err != nil {
+		return fmt.Errorf("failed to send handshake: %w", err)
+	}
+
+	go func() {
+		select {
+		case <-handshake:
+		case <-ctx.Done():
+		case <-time.After(handshakeTimeout):
+			log.Println("Host didn't reply to handshake")
+			s.leaveHost(fmt.Sprintf("Can't join: host's game is incompatible with yours (version %s)", config.Version))
+		}
+	}()
+
+	return nil
+}
+
+// handleHandshakeData agrees how to play with the host, and joins the host's lobby
+// if the games are compatible.
+func (s *MultiplayerJoinScreen) handleHandshakeData(data comms.HandshakeData) error {
+	select {
+	case <-s.handshake:
+		return errors.New("host sent a second handshake")
+	default:
+		close(s.handshake)
+	}
+
+	if _, err := comms.Negotiate(comms.NewHandshake(), data); err != nil {
+		s.leaveHost(fmt.Sprintf(
+			"Can't join: host's game (version %s) is incompatible with yours (version %s)",
+			data.AppVersion, config.Version,
+		))
+		return err
+	}
+
 	if err := s.sendPlayerData(); err != nil {
 		return fmt.Errorf("failed to send player data: %w", err)
 	}
-
 	return nil
 }
 
+// leaveHost disconnects from the host and tells the player why, so they can join
+// another game.
+func (s *MultiplayerJoinScreen) leaveHost(reason string) {
+	if s.disconnect != nil {
+		s.disconnect()
+	}
+	s.client.Destroy()
+	s.connected = false
+	s.join.SetCallback(
+		gogl.ButtonTrigger{State: gogl.LeftClick, Behaviour: gogl.OnRelease},
+		s.joinButtonHandler,
+	)
+	s.opponentStatus.SetText(reason)
+}
+
 // handleServerData handles all data received from the server.
 func (s *MultiplayerJoinScreen) handleServerData(data []byte) error {
 	msg, err := comms.ParseMessage(data)
@@ -285,6 +484,13 @@ func (s *MultiplayerJoinScreen) handleServerData(data []byte) error {
 	}
 
 	switch msg.Type {
+	case comms.TypeHandshakeData:
+		handshakeData, err := comms.ParseHandshakeData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse handshake data: %w", err)
+		}
+		return s.handleHandshakeData(handshakeData)
+
 	case comms.TypeEventData:
 		eventData, err := comms.ParseEventData(msg.Content)
 		if err != nil {
@@ -299,6 +505,13 @@ func (s *MultiplayerJoinScreen) handleServerData(data []byte) error {
 		}
 		return s.handlePlayerData(playerData)
 
+	case comms.TypeSettingsData:
+		settingsData, err := comms.ParseSettingsData(msg.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse settings data: %w", err)
+		}
+		return s.handleSettingsData(settingsData)
+
 	default:
 		return fmt.Errorf("unsupported message type \"%s\"", msg.Type)
 	}
@@ -315,7 +528,6 @@ func (s *MultiplayerJoinScreen) handleEventData(data comms.EventData) error {
 // sendPlayerData sends the player data to the host.
 func (s *MultiplayerJoinScreen) sendPlayerData() error {
 	msg, err := comms.PlayerData{
-		Version:  config.Version,
 		Username: s.nameEntry.Text(),
 	}.Serialise()
 	if err != nil {
@@ -327,15 +539,9 @@ func (s *MultiplayerJoinScreen) sendPlayerData() error {
 
 // handleEventData handles incoming player data.
 func (s *MultiplayerJoinScreen) handlePlayerData(data comms.PlayerData) error {
-	// Make sure versions are compatible
-	if data.Version != config.Version {
-		return fmt.Errorf("incompatible versions (peer %s, local %s)", data.Version, config.Version)
-	}
-
 	// Animate status message
 	s.opponentName = data.Username
-	msg := fmt.Sprintf("Waiting for \"%s\" to start the game", s.opponentName)
-	s.opponentStatus.SetText(msg)
+	s.opponentStatus.SetText(s.waitingMessage())
 	go func() {
 		n := 0
 		for {
@@ -343,7 +549,7 @@ func (s *MultiplayerJoinScreen) handlePlayerData(data comms.PlayerData) error {
 			case <-s.done:
 				return
 			default:
-				s.opponentStatus.SetText(msg + strings.Repeat(".", n))
+				s.opponentStatus.SetText(s.waitingMessage() + strings.Repeat(".", n))
 				time.Sleep(time.Second)
 				n++
 				if n > 3 {
@@ -355,3 +561,27 @@ func (s *MultiplayerJoinScreen) handlePlayerData(data comms.PlayerData) error {
 
 	return nil
 }
+
+// handleSettingsData handles incoming settings data.
+func (s *MultiplayerJoinScreen) handleSettingsData(data comms.SettingsData) error {
+	if !grid.ValidSize(data.Width, data.Height) {
+		return fmt.Errorf("invalid board size %dx%d", data.Width, data.Height)
+	}
+	if !grid.ValidTarget(data.Target) {
+		return fmt.Errorf("invalid target tile %d", data.Target)
+	}
+	s.settings = data
+	return nil
+}
+
+// waitingMessage returns the status message shown whilst waiting for the host.
+func (s *MultiplayerJoinScreen) waitingMessage() string {
+	rules := ""
+	if s.settings.Battle {
+		rules = ", battle"
+	}
+	return fmt.Sprintf(
+		"Waiting for \"%s\" to start the game (%s board, first to %d%s)",
+		s.opponentName, boardSize{s.settings.Width, s.settings.Height}, s.settings.Target, rules,
+	)
+}
@@ -2,6 +2,8 @@ package screens
 
 import (
 	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/common/comms"
 	"github.com/z-riley/go-2048-battle/config"
 	"github.com/z-riley/gogl"
 )
@@ -15,12 +17,20 @@ type MultiplayerMenuScreen struct {
 	buttonBackground *gogl.CurvedRect
 	join             *gogl.Button
 	host             *gogl.Button
+	cpu              *gogl.Button
 	back             *gogl.Button
+	difficultyButton *gogl.Button
+	difficulty       int // index of the computer opponent's difficulty in botDifficulties
+	rulesButton      *gogl.Button
+	battle           bool // whether the game against the computer opponent has attacks
 }
 
 // NewTitle Screen constructs a new multiplayer menu screen for the given window.
 func NewMultiplayerMenuScreen(win *gogl.Window) *MultiplayerMenuScreen {
-	return &MultiplayerMenuScreen{win: win}
+	return &MultiplayerMenuScreen{
+		win:        win,
+		difficulty: defaultBotDifficulty,
+	}
 }
 
 // Enter initialises the screen.
@@ -43,7 +53,7 @@ func (s *MultiplayerMenuScreen) Enter(_ InitData) {
 	)
 
 	// Background for buttons
-	const w = TileSizePx * (3 + 4*TileBoundryFactor)
+	const w = TileSizePx * (4 + 5*TileBoundryFactor)
 	s.buttonBackground = gogl.NewCurvedRect(
 		w, TileSizePx*(1+2*TileBoundryFactor), TileCornerRadius,
 		gogl.Vec{X: (config.WinWidth - w) / 2, Y: 400},
@@ -98,12 +108,61 @@ func (s *MultiplayerMenuScreen) Enter(_ InitData) {
 		},
 	)
 
-	s.back = common.NewMenuButton(
+	s.cpu = common.NewMenuButton(
 		TileSizePx, TileSizePx,
 		gogl.Vec{
 			X: s.buttonBackground.Pos.X + TileSizePx*(2+3*TileBoundryFactor),
 			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
 		}.Round(),
+		s.playCPU,
+	).SetLabelText("CPU")
+	s.cpu.SetCallback(
+		gogl.ButtonTrigger{State: gogl.NoClick, Behaviour: gogl.OnHold},
+		func() {
+			s.cpu.Label.SetColour(common.WhiteFontColour)
+			s.cpu.Shape.(*gogl.CurvedRect).SetStyle(common.ButtonStyleHovering)
+			s.hint.SetText("Play against the computer")
+		},
+	).SetCallback(
+		gogl.ButtonTrigger{State: gogl.NoClick, Behaviour: gogl.OnRelease},
+		func() {
+			s.cpu.Label.SetColour(common.WhiteFontColour)
+			s.cpu.Shape.(*gogl.CurvedRect).SetStyle(common.ButtonStyleUnpressed)
+			s.hint.SetText("")
+		},
+	)
+
+	const (
+		settingWidth = 200
+		settingGap   = 10
+	)
+	s.difficultyButton = common.NewGameButton(
+		settingWidth, 0.5*common.TileSizePx,
+		gogl.Vec{
+			X: config.WinWidth/2 - settingWidth - settingGap/2,
+			Y: s.buttonBackground.Pos.Y + s.buttonBackground.Height() + 25,
+		},
+		func() {
+			s.setDifficulty(s.difficulty + 1)
+		},
+	)
+	s.setDifficulty(s.difficulty)
+
+	s.rulesButton = common.NewGameButton(
+		settingWidth, 0.5*common.TileSizePx,
+		gogl.Vec{
+			X: (config.WinWidth + settingGap) / 2,
+			Y: s.buttonBackground.Pos.Y + s.buttonBackground.Height() + 25,
+		},
+		s.toggleBattle,
+	).SetLabelText(rulesLabel(s.battle))
+
+	s.back = common.NewMenuButton(
+		TileSizePx, TileSizePx,
+		gogl.Vec{
+			X: s.buttonBackground.Pos.X + TileSizePx*(3+4*TileBoundryFactor),
+			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
+		}.Round(),
 		func() { SetScreen(Title, nil) },
 	).SetLabelText("Back")
 	s.back.SetCallback(
@@ -128,9 +187,14 @@ func (s *MultiplayerMenuScreen) Enter(_ InitData) {
 	s.win.RegisterKeybind(gogl.Key2, gogl.KeyRelease, func() {
 		SetScreen(MultiplayerHost, nil)
 	})
-	s.win.RegisterKeybind(gogl.Key3, gogl.KeyRelease, func() {
+	s.win.RegisterKeybind(gogl.Key3, gogl.KeyRelease, s.playCPU)
+	s.win.RegisterKeybind(gogl.Key4, gogl.KeyRelease, func() {
 		SetScreen(Title, nil)
 	})
+	s.win.RegisterKeybind(gogl.KeyD, gogl.KeyRelease, func() {
+		s.setDifficulty(s.difficulty + 1)
+	})
+	s.win.RegisterKeybind(gogl.KeyB, gogl.KeyRelease, s.toggleBattle)
 	s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
 		SetScreen(Title, nil)
 	})
@@ -141,6 +205,9 @@ func (s *MultiplayerMenuScreen) Exit() {
 	s.win.UnregisterKeybind(gogl.Key1, gogl.KeyRelease)
 	s.win.UnregisterKeybind(gogl.Key2, gogl.KeyRelease)
 	s.win.UnregisterKeybind(gogl.Key3, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.Key4, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyD, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyB, gogl.KeyRelease)
 	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
 }
 
@@ -155,9 +222,40 @@ func (s *MultiplayerMenuScreen) Update() {
 	for _, b := range []*gogl.Button{
 		s.join,
 		s.host,
+		s.cpu,
 		s.back,
+		s.difficultyButton,
+		s.rulesButton,
 	} {
 		b.Update(s.win)
 		s.win.Draw(b)
 	}
 }
+
+// playCPU starts a game against a computer opponent at the selected difficulty.
+func (s *MultiplayerMenuScreen) playCPU() {
+	SetScreen(Multiplayer, InitData{
+		botKey:              botDifficulties[s.difficulty],
+		opponentUsernameKey: "CPU",
+		settingsKey: comms.SettingsData{
+			Width:  defaultBoardSize.width,
+			Height: defaultBoardSize.height,
+			Target: grid.DefaultTarget,
+			Battle: s.battle,
+		},
+	})
+}
+
+// toggleBattle switches the game against the computer opponent between classic
+// and battle rules.
+func (s *MultiplayerMenuScreen) toggleBattle() {
+	s.battle = !s.battle
+	s.rulesButton.SetLabelText(rulesLabel(s.battle))
+}
+
+// setDifficulty sets the computer opponent's difficulty to the difficulty at index
+// i of botDifficulties.
+func (s *MultiplayerMenuScreen) setDifficulty(i int) {
+	s.difficulty = i % len(botDifficulties)
+	s.difficultyButton.SetLabelText("CPU LEVEL: " + botDifficulties[s.difficulty].name)
+}
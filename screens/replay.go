@@ -0,0 +1,407 @@
+package screens
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/log"
+	"github.com/z-riley/gogl"
+)
+
+const (
+	// minReplayDelay is the shortest time between actions when playing a replay
+	// at normal speed, so every move can be seen.
+	minReplayDelay = 150 * time.Millisecond
+	// maxReplayDelay is the longest time between actions when playing a replay
+	// at normal speed, so long pauses in the recording are skipped.
+	maxReplayDelay = 1500 * time.Millisecond
+)
+
+// replaySpeeds contains every speed which a replay can be played at.
+var replaySpeeds = []float64{0.5, 1, 2, 4}
+
+type ReplayScreen struct {
+	win *gogl.Window
+
+	paths    []string // every saved replay, most recent first
+	index    int      // index of the selected replay in paths
+	player   *backend.Player
+	playing  bool
+	speed    int       // index of the playback speed in replaySpeeds
+	nextStep time.Time // when the next action is due to be played
+	arena    *common.Arena
+	inputCh  chan func()
+
+	logo2048 *gogl.TextBox
+	score    *common.ScoreBox
+	menu     *gogl.Button
+	prev     *gogl.Button
+	next     *gogl.Button
+	guide    *gogl.Text
+	progress *gogl.Text
+	timer    *gogl.Text
+	play     *gogl.Button
+	step     *gogl.Button
+	speedBtn *gogl.Button
+	restart  *gogl.Button
+}
+
+// NewReplayScreen constructs an uninitialised new replay screen.
+func NewReplayScreen(win *gogl.Window) *ReplayScreen {
+	return &ReplayScreen{win: win}
+}
+
+// Enter initialises the screen.
+func (s *ReplayScreen) Enter(_ InitData) {
+	s.arena = common.NewArena(gogl.Vec{X: 440, Y: 300})
+	s.inputCh = make(chan func(), 100)
+	s.speed = 1
+	s.player = nil
+	s.playing = false
+
+	paths, err := backend.ListReplays()
+	if err != nil {
+		log.Println("Failed to list replays:", err)
+	}
+	s.paths = paths
+	s.index = 0
+
+	// UI components
+	{
+		// Everything is sized relative to the tile size
+		const unit = common.TileSizePx
+
+		// Everything is positioned relative to the arena grid
+		anchor := s.arena.Pos()
+
+		s.logo2048 = common.NewLogoBox(
+			1.36*unit,
+			gogl.Vec{X: anchor.X, Y: anchor.Y - 2.58*unit},
+		)
+
+		const wScore = 90
+		s.score = common.NewScoreBox(
+			wScore, wScore,
+			gogl.Vec{X: anchor.X + s.arena.Width() - wScore, Y: anchor.Y - 2.58*unit},
+			common.ArenaBackgroundColour,
+		).SetHeading("SCORE")
+
+		const buttonWidth = unit * 1.27
+		s.menu = common.NewGameButton(
+			buttonWidth, 0.4*unit,
+			gogl.Vec{X: anchor.X + s.arena.Width() - buttonWidth, Y: anchor.Y - 1.21*unit},
+			func() {
+				SetScreen(Title, nil)
+			},
+		).SetLabelText("MENU")
+
+		s.next = common.NewGameButton(
+			buttonWidth, 0.4*unit,
+			gogl.Vec{X: anchor.X + s.arena.Width() - 2.74*unit, Y: anchor.Y - 1.21*unit},
+			func() {
+				s.inputCh <- func() { s.selectReplay(s.index + 1) }
+			},
+		).SetLabelText("OLDER")
+
+		s.prev = common.NewGameButton(
+			buttonWidth, 0.4*unit,
+			gogl.Vec{X: anchor.X + s.arena.Width() - 4.21*unit, Y: anchor.Y - 1.21*unit},
+			func() {
+				s.inputCh <- func() { s.selectReplay(s.index - 1) }
+			},
+		).SetLabelText("NEWER")
+
+		s.guide = gogl.NewText(
+			"",
+			gogl.Vec{X: anchor.X, Y: anchor.Y - 0.60*unit},
+			common.FontPathBold,
+		).SetSize(16).SetColour(common.GreyTextColour)
+
+		s.progress = common.NewGameText("",
+			gogl.Vec{X: anchor.X, Y: anchor.Y + s.arena.Height()*1.1},
+		).SetSize(16).SetAlignment(gogl.AlignBottomLeft)
+
+		s.timer = common.NewGameText("",
+			gogl.Vec{X: anchor.X + s.arena.Width(), Y: anchor.Y + s.arena.Height()*1.1},
+		).SetSize(16).SetAlignment(gogl.AlignBottomRight)
+
+		// Playback controls sit underneath the arena
+		const controlWidth = unit * 0.98
+		controlY := anchor.Y + s.arena.Height()*1.14
+		s.play = common.NewGameButton(
+			controlWidth, 0.4*unit,
+			gogl.Vec{X: anchor.X, Y: controlY},
+			func() {
+				s.inputCh <- s.togglePlaying
+			},
+		).SetLabelText("PLAY")
+
+		s.step = common.NewGameButton(
+			controlWidth, 0.4*unit,
+			gogl.Vec{X: anchor.X + 1.18*unit, Y: controlY},
+			func() {
+				s.inputCh <- s.stepOnce
+			},
+		).SetLabelText("STEP")
+
+		s.speedBtn = common.NewGameButton(
+			controlWidth, 0.4*unit,
+			gogl.Vec{X: anchor.X + 2.36*unit, Y: controlY},
+			func() {
+				s.inputCh <- func() { s.setSpeed(s.speed + 1) }
+			},
+		).SetLabelText(speedLabel(s.speed))
+
+		s.restart = common.NewGameButton(
+			controlWidth, 0.4*unit,
+			gogl.Vec{X: anchor.X + s.arena.Width() - controlWidth, Y: controlY},
+			func() {
+				s.inputCh <- s.restartReplay
+			},
+		).SetLabelText("RESTART")
+	}
+
+	s.selectReplay(0)
+
+	// Set keybinds. Inputs are sent via a buffered channel so they are handled
+	// in the update loop, like the singleplayer screen
+	{
+		s.win.RegisterKeybind(gogl.KeySpace, gogl.KeyRelease, func() {
+			s.inputCh <- s.togglePlaying
+		})
+		s.win.RegisterKeybind(gogl.KeyRight, gogl.KeyPress, func() {
+			s.inputCh <- s.stepOnce
+		})
+		s.win.RegisterKeybind(gogl.KeyUp, gogl.KeyRelease, func() {
+			s.inputCh <- func() { s.setSpeed(s.speed + 1) }
+		})
+		s.win.RegisterKeybind(gogl.KeyDown, gogl.KeyRelease, func() {
+			s.inputCh <- func() { s.setSpeed(s.speed - 1) }
+		})
+		s.win.RegisterKeybind(gogl.KeyR, gogl.KeyRelease, func() {
+			s.inputCh <- s.restartReplay
+		})
+		s.win.RegisterKeybind(gogl.KeyPageup, gogl.KeyRelease, func() {
+			s.inputCh <- func() { s.selectReplay(s.index - 1) }
+		})
+		s.win.RegisterKeybind(gogl.KeyPagedown, gogl.KeyRelease, func() {
+			s.inputCh <- func() { s.selectReplay(s.index + 1) }
+		})
+		s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
+			SetScreen(Title, nil)
+		})
+	}
+}
+
+// Exit deinitialises the screen.
+func (s *ReplayScreen) Exit() {
+	s.win.UnregisterKeybind(gogl.KeySpace, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyRight, gogl.KeyPress)
+	s.win.UnregisterKeybind(gogl.KeyUp, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyDown, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyR, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyPageup, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyPagedown, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
+
+	s.arena.Destroy()
+}
+
+// Update updates and draws the replay screen.
+func (s *ReplayScreen) Update() {
+	// Only 1 input is handled per update cycle, because the arena can only
+	// animate one move at a time
+	select {
+	case inputFunc := <-s.inputCh:
+		inputFunc()
+	default:
+		if s.playing && time.Now().After(s.nextStep) {
+			s.advance()
+		}
+	}
+
+	s.win.SetBackground(common.BackgroundColour)
+
+	for _, b := range []*gogl.Button{
+		s.menu,
+		s.prev,
+		s.next,
+		s.play,
+		s.step,
+		s.speedBtn,
+		s.restart,
+	} {
+		b.Update(s.win)
+	}
+
+	for _, d := range []gogl.Drawable{
+		s.logo2048,
+		s.score,
+		s.menu,
+		s.prev,
+		s.next,
+		s.guide,
+		s.progress,
+		s.timer,
+		s.play,
+		s.step,
+		s.speedBtn,
+		s.restart,
+		s.arena,
+	} {
+		s.win.Draw(d)
+	}
+}
+
+// selectReplay loads the replay at index i of the saved replays, ready to be
+// played from the start. Does nothing if there is no replay at the index.
+func (s *ReplayScreen) selectReplay(i int) {
+	if len(s.paths) == 0 {
+		s.guide.SetText("No replays yet. Finish a solo game to record one!")
+		return
+	}
+	if i < 0 || i >= len(s.paths) {
+		return
+	}
+
+	replay, err := backend.LoadReplay(s.paths[i])
+	if err != nil {
+		log.Println("Failed to load replay:", err)
+		s.guide.SetText("Could not load " + filepath.Base(s.paths[i]))
+		s.index = i
+		s.player = nil
+		s.setPlaying(false)
+		return
+	}
+
+	s.index = i
+	s.player = backend.NewPlayer(replay)
+	s.guide.SetText(fmt.Sprintf("Replay %d of %d: %s",
+		i+1, len(s.paths), strings.TrimSuffix(filepath.Base(s.paths[i]), backend.ReplayExt)))
+	s.setPlaying(false)
+	s.showStart()
+}
+
+// restartReplay returns the current replay to its start.
+func (s *ReplayScreen) restartReplay() {
+	if s.player == nil {
+		return
+	}
+	s.player.Reset()
+	s.setPlaying(false)
+	s.showStart()
+}
+
+// showStart shows the current replay at its first frame.
+func (s *ReplayScreen) showStart() {
+	s.arena.Reset()
+	s.arena.Load(s.player.Game().Snapshot())
+	s.refresh()
+}
+
+// togglePlaying starts playback if the replay is paused and vice versa.
+func (s *ReplayScreen) togglePlaying() {
+	if s.player == nil {
+		return
+	}
+	if s.player.Done() {
+		// Start again when the end of the replay is reached
+		s.restartReplay()
+	}
+	s.setPlaying(!s.playing)
+}
+
+// setPlaying sets whether the replay is being played automatically.
+func (s *ReplayScreen) setPlaying(playing bool) {
+	s.playing = playing
+	if playing {
+		s.play.SetLabelText("PAUSE")
+		s.scheduleNext()
+	} else {
+		s.play.SetLabelText("PLAY")
+	}
+}
+
+// stepOnce pauses playback and plays the next action.
+func (s *ReplayScreen) stepOnce() {
+	s.setPlaying(false)
+	s.advance()
+}
+
+// setSpeed sets the playback speed to the speed at index i of replaySpeeds.
+func (s *ReplayScreen) setSpeed(i int) {
+	s.speed = (i + len(replaySpeeds)) % len(replaySpeeds)
+	s.speedBtn.SetLabelText(speedLabel(s.speed))
+	if s.playing {
+		s.scheduleNext()
+	}
+}
+
+// advance plays the next action of the replay and animates the arena to match.
+func (s *ReplayScreen) advance() {
+	if s.player == nil {
+		return
+	}
+	action, ok := s.player.Next()
+	if !ok {
+		s.setPlaying(false)
+		return
+	}
+
+	if err := s.player.Step(); err != nil {
+		log.Println("Failed to play replay:", err)
+		s.guide.SetText("This replay is damaged and cannot be played further")
+		s.setPlaying(false)
+		return
+	}
+
+	game := s.player.Game().Snapshot()
+	if action.Type == backend.ActionUndo {
+		s.arena.Rewind(game)
+	} else {
+		s.arena.Update(game)
+	}
+	s.refresh()
+
+	if s.player.Done() {
+		s.setPlaying(false)
+	} else if s.playing {
+		s.scheduleNext()
+	}
+}
+
+// scheduleNext sets when the next action should be played, based on when it was
+// made in the recording and the playback speed.
+func (s *ReplayScreen) scheduleNext() {
+	delay := min(max(s.player.NextDelay(), minReplayDelay), maxReplayDelay)
+	s.nextStep = time.Now().Add(time.Duration(float64(delay) / replaySpeeds[s.speed]))
+}
+
+// refresh updates the text which shows the state of the replay.
+func (s *ReplayScreen) refresh() {
+	game := s.player.Game()
+	played, total := s.player.Progress()
+
+	s.score.SetBody(strconv.Itoa(game.Score))
+	s.progress.SetText(fmt.Sprintf("Move %d/%d", played, total))
+	s.timer.SetText(game.Timer.String())
+	switch {
+	case game.Outcome() == grid.Lose:
+		s.arena.SetLose()
+	case game.Over():
+		s.arena.SetWin()
+	default:
+		s.arena.SetNormal()
+	}
+}
+
+// speedLabel returns the label for the playback speed at index i of replaySpeeds.
+func speedLabel(i int) string {
+	return strconv.FormatFloat(replaySpeeds[i], 'f', -1, 64) + "x"
+}
@@ -1,6 +1,12 @@
 package screens
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/common/backend/grid"
+	"github.com/z-riley/go-2048-battle/log"
 	"github.com/z-riley/gogl"
 )
 
@@ -26,6 +32,8 @@ const (
 	MultiplayerJoin ID = "multiplayerJoin"
 	MultiplayerHost ID = "multiplayerHost"
 	Multiplayer     ID = "multiplayer"
+	Replay          ID = "replay"
+	Stats           ID = "stats"
 )
 
 func (id ID) String() string {
@@ -35,8 +43,25 @@ func (id ID) String() string {
 // screens contains every screen.
 var screens map[ID]Screen
 
-// Init populates the internal screens variable.
+// profile is the player's profile, which is chosen on the title screen.
+var profile *backend.Profile
+
+// Init loads the player's profile and populates the internal screens variable.
 func Init(win *gogl.Window) {
+	p, err := backend.CurrentProfile()
+	if err != nil {
+		log.Println("Failed to load profile:", err)
+		p = &backend.Profile{
+			Name: backend.DefaultProfileName,
+			Prefs: backend.Prefs{
+				Width:  grid.DefaultWidth,
+				Height: grid.DefaultHeight,
+				Target: grid.DefaultTarget,
+			},
+		}
+	}
+	profile = p
+
 	screens = map[ID]Screen{
 		Title:           NewTitleScreen(win),
 		Singleplayer:    NewSingleplayerScreen(win),
@@ -44,6 +69,8 @@ func Init(win *gogl.Window) {
 		MultiplayerJoin: NewMultiplayerJoinScreen(win),
 		MultiplayerHost: NewMultiplayerHostScreen(win),
 		Multiplayer:     NewMultiplayerScreen(win),
+		Replay:          NewReplayScreen(win),
+		Stats:           NewStatsScreen(win),
 	}
 }
 
@@ -80,9 +107,78 @@ func CurrentScreen() Screen {
 // SetScreen changes the current screen to the given ID next time Update is called.
 func SetScreen(id ID, data InitData) {
 	switch id {
-	case Title, Singleplayer, MultiplayerMenu, MultiplayerJoin, MultiplayerHost, Multiplayer:
+	case Title, Singleplayer, MultiplayerMenu, MultiplayerJoin, MultiplayerHost, Multiplayer, Replay, Stats:
 		screenChangeChan <- screenChange{id, data}
 	default:
 		panic("invalid screen: " + id)
 	}
 }
+
+// boardSize contains the dimensions of a grid, in tiles.
+type boardSize struct{ width, height int }
+
+// String returns the board size in a human readable format.
+func (b boardSize) String() string {
+	return fmt.Sprintf("%dx%d", b.width, b.height)
+}
+
+// boardSizes contains every board size which the player can choose from.
+var boardSizes = []boardSize{
+	{3, 3}, {4, 4}, {5, 5}, {6, 6}, {7, 7}, {8, 8}, {4, 6}, {6, 4},
+}
+
+// defaultBoardSize is the board size used when no other size is chosen.
+var defaultBoardSize = boardSize{grid.DefaultWidth, grid.DefaultHeight}
+
+// nextBoardSize returns the board size which comes after b in boardSizes.
+func nextBoardSize(b boardSize) boardSize {
+	for i := range boardSizes {
+		if boardSizes[i] == b {
+			return boardSizes[(i+1)%len(boardSizes)]
+		}
+	}
+	return defaultBoardSize
+}
+
+// targetTiles contains every target tile which the host can choose from.
+var targetTiles = []int{256, 512, 1024, 2048, 4096, 8192}
+
+// nextTarget returns the target tile which comes after t in targetTiles.
+func nextTarget(t int) int {
+	for i := range targetTiles {
+		if targetTiles[i] == t {
+			return targetTiles[(i+1)%len(targetTiles)]
+		}
+	}
+	return grid.DefaultTarget
+}
+
+// rulesLabel returns the label of a button which chooses the rules of a versus
+// game.
+func rulesLabel(battle bool) string {
+	if battle {
+		return "RULES: BATTLE"
+	}
+	return "RULES: CLASSIC"
+}
+
+// gameModes contains every game mode which the player can choose from.
+var gameModes = []backend.Mode{
+	backend.ClassicMode(),
+	backend.TimeAttackMode(1 * time.Minute),
+	backend.TimeAttackMode(3 * time.Minute),
+	backend.RaceMode(512),
+	backend.RaceMode(2048),
+	backend.MoveLimitMode(100),
+	backend.MoveLimitMode(250),
+}
+
+// nextGameMode returns the game mode which comes after m in gameModes.
+func nextGameMode(m backend.Mode) backend.Mode {
+	for i := range gameModes {
+		if gameModes[i] == m {
+			return gameModes[(i+1)%len(gameModes)]
+		}
+	}
+	return gameModes[0]
+}
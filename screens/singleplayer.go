@@ -3,15 +3,33 @@ package screens
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
-	"github.com/brunoga/deep"
 	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/ai"
 	"github.com/z-riley/go-2048-battle/common/backend"
 	"github.com/z-riley/go-2048-battle/common/backend/grid"
 	"github.com/z-riley/go-2048-battle/config"
+	"github.com/z-riley/go-2048-battle/log"
 	"github.com/z-riley/gogl"
 )
 
+// autoPlayRates contains every rate which auto-play can make moves at, in moves
+// per second.
+var autoPlayRates = []int{1, 2, 4, 8, 16}
+
+// defaultAutoPlayRate is the index of the auto-play rate used when the screen is
+// entered.
+const defaultAutoPlayRate = 2
+
+// hintResult contains the move recommended for a grid.
+type hintResult struct {
+	tiles [][]grid.Tile // the tiles the hint was found for
+	dir   grid.Direction
+	ok    bool // false if no move is possible
+}
+
 type SingleplayerScreen struct {
 	win *gogl.Window
 
@@ -19,6 +37,13 @@ type SingleplayerScreen struct {
 	arena        *common.Arena
 	arenaInputCh chan func()
 
+	solver       *ai.Solver
+	hintCh       chan hintResult
+	searching    bool          // whether a hint is being searched for
+	autoPlay     bool          // whether moves are being made automatically
+	autoPlayRate int           // index of the rate in autoPlayRates
+	autoPlayStop chan struct{} // closed to stop the auto-play goroutine
+
 	heading    *gogl.Text
 	loseDialog *gogl.Text
 	logo2048   *gogl.TextBox
@@ -26,7 +51,10 @@ type SingleplayerScreen struct {
 	highScore  *common.ScoreBox
 	menu       *gogl.Button
 	newGame    *gogl.Button
+	boardSize  *gogl.Button
+	mode       *gogl.Button
 	guide      *gogl.Text
+	hint       *gogl.Text
 	timer      *gogl.Text
 
 	debugGrid  *gogl.Text
@@ -44,8 +72,19 @@ func (s *SingleplayerScreen) Enter(_ InitData) {
 	// Arena and supporting data structures
 	{
 		s.arena = common.NewArena(gogl.Vec{X: 440, Y: 300})
-		s.backend = backend.NewGame(nil)
+		s.backend = backend.NewGame(&backend.Opts{
+			SaveToDisk: true,
+			UndoDepth:  backend.DefaultUndoDepth,
+			Record:     true,
+			SaveFile:   profile.SaveFile(),
+			StatsFile:  profile.StatsFile(),
+		})
 		s.arenaInputCh = make(chan func(), 100)
+		s.solver = ai.NewSolver(ai.NewExpectimax(), ai.DefaultBudget)
+		s.hintCh = make(chan hintResult, 1)
+		s.searching = false
+		s.autoPlay = false
+		s.autoPlayRate = defaultAutoPlayRate
 	}
 
 	// UI components
@@ -106,12 +145,35 @@ func (s *SingleplayerScreen) Enter(_ InitData) {
 			},
 		).SetLabelText("NEW")
 
+		s.boardSize = common.NewGameButton(
+			buttonWidth, 0.4*unit,
+			gogl.Vec{X: anchor.X + s.arena.Width() - 4.21*unit, Y: anchor.Y - 1.21*unit},
+			func() {
+				s.arenaInputCh <- s.cycleBoardSize
+			},
+		).SetLabelText(s.currentBoardSize().String())
+
+		s.mode = common.NewGameButton(
+			buttonWidth, 0.4*unit,
+			gogl.Vec{
+				X: anchor.X + (s.arena.Width()-buttonWidth)/2,
+				Y: anchor.Y + s.arena.Height()*1.1 - 0.4*unit,
+			},
+			func() {
+				s.arenaInputCh <- s.cycleGameMode
+			},
+		).SetLabelText(s.backend.Mode.String())
+
 		s.guide = gogl.NewText(
-			"Join the numbers and get to the 2048 tile!",
+			modeGuide(s.backend.Mode, s.backend.Target),
 			gogl.Vec{X: anchor.X, Y: anchor.Y - 0.60*unit},
 			common.FontPathBold,
 		).SetSize(16).SetColour(common.GreyTextColour)
 
+		s.hint = common.NewGameText("",
+			gogl.Vec{X: anchor.X, Y: anchor.Y + s.arena.Height()*1.1},
+		).SetSize(16).SetAlignment(gogl.AlignBottomLeft)
+
 		s.timer = common.NewGameText("",
 			gogl.Vec{X: anchor.X + s.arena.Width(), Y: anchor.Y + s.arena.Height()*1.1},
 		).SetSize(16).SetAlignment(gogl.AlignBottomRight)
@@ -121,7 +183,7 @@ func (s *SingleplayerScreen) Enter(_ InitData) {
 	s.debugGrid = gogl.NewText("grid", gogl.Vec{X: 930, Y: 600}, common.FontPathMedium).
 		SetText(s.backend.Grid.Debug())
 	s.debugTime = gogl.NewText("time", gogl.Vec{X: 1100, Y: 550}, common.FontPathMedium).
-		SetText(s.backend.Timer.Time.String())
+		SetText(s.backend.Timer.String())
 	s.debugScore = gogl.NewText("score", gogl.Vec{X: 950, Y: 550}, common.FontPathMedium).
 		SetText(strconv.Itoa(s.backend.Score))
 
@@ -131,26 +193,22 @@ func (s *SingleplayerScreen) Enter(_ InitData) {
 	{
 		s.win.RegisterKeybind(gogl.KeyUp, gogl.KeyPress, func() {
 			s.arenaInputCh <- func() {
-				s.backend.ExecuteMove(grid.DirUp)
-				s.debugGrid.SetText(s.backend.Grid.Debug())
+				s.move(grid.DirUp)
 			}
 		})
 		s.win.RegisterKeybind(gogl.KeyDown, gogl.KeyPress, func() {
 			s.arenaInputCh <- func() {
-				s.backend.ExecuteMove(grid.DirDown)
-				s.debugGrid.SetText(s.backend.Grid.Debug())
+				s.move(grid.DirDown)
 			}
 		})
 		s.win.RegisterKeybind(gogl.KeyLeft, gogl.KeyPress, func() {
 			s.arenaInputCh <- func() {
-				s.backend.ExecuteMove(grid.DirLeft)
-				s.debugGrid.SetText(s.backend.Grid.Debug())
+				s.move(grid.DirLeft)
 			}
 		})
 		s.win.RegisterKeybind(gogl.KeyRight, gogl.KeyPress, func() {
 			s.arenaInputCh <- func() {
-				s.backend.ExecuteMove(grid.DirRight)
-				s.debugGrid.SetText(s.backend.Grid.Debug())
+				s.move(grid.DirRight)
 			}
 		})
 		s.win.RegisterKeybind(gogl.KeyR, gogl.KeyRelease, func() {
@@ -159,24 +217,217 @@ func (s *SingleplayerScreen) Enter(_ InitData) {
 				s.arena.Reset()
 			}
 		})
+		s.win.RegisterKeybind(gogl.KeyB, gogl.KeyRelease, func() {
+			s.arenaInputCh <- s.cycleBoardSize
+		})
+		s.win.RegisterKeybind(gogl.KeyM, gogl.KeyRelease, func() {
+			s.arenaInputCh <- s.cycleGameMode
+		})
+		s.win.RegisterKeybind(gogl.KeyT, gogl.KeyRelease, func() {
+			s.arenaInputCh <- s.toggleSpecials
+		})
+		s.win.RegisterKeybind(gogl.KeyZ, gogl.KeyRelease, func() {
+			s.arenaInputCh <- func() {
+				if s.backend.Undo() {
+					s.arena.Rewind(s.backend.Snapshot())
+				}
+			}
+		})
+		s.win.RegisterKeybind(gogl.KeyY, gogl.KeyRelease, func() {
+			s.arenaInputCh <- func() {
+				s.backend.Redo()
+			}
+		})
+		s.win.RegisterKeybind(gogl.KeyH, gogl.KeyRelease, func() {
+			s.arenaInputCh <- s.requestHint
+		})
+		s.win.RegisterKeybind(gogl.KeyA, gogl.KeyRelease, func() {
+			s.arenaInputCh <- func() {
+				s.setAutoPlay(!s.autoPlay)
+			}
+		})
+		s.win.RegisterKeybind(gogl.KeyMinus, gogl.KeyRelease, func() {
+			s.arenaInputCh <- func() {
+				s.setAutoPlayRate(s.autoPlayRate - 1)
+			}
+		})
+		s.win.RegisterKeybind(gogl.KeyEquals, gogl.KeyRelease, func() {
+			s.arenaInputCh <- func() {
+				s.setAutoPlayRate(s.autoPlayRate + 1)
+			}
+		})
 		s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
 			SetScreen(Title, nil)
 		})
 	}
 }
 
+// move carries out a move in the given direction.
+func (s *SingleplayerScreen) move(dir grid.Direction) {
+	s.backend.ExecuteMove(dir)
+	s.debugGrid.SetText(s.backend.Grid.Debug())
+	if !s.autoPlay {
+		// Any hint was for the previous move
+		s.hint.SetText("")
+	}
+}
+
+// requestHint searches for the best move for the current grid in the background.
+// The result is handled by the update loop once it is received via hintCh. Using
+// a hint marks the game as assisted.
+func (s *SingleplayerScreen) requestHint() {
+	if s.searching {
+		return
+	}
+	s.searching = true
+	s.backend.MarkAssisted()
+
+	g := s.backend.Grid.Clone()
+	go func() {
+		dir, ok := s.solver.BestMove(g)
+		s.hintCh <- hintResult{tiles: g.Tiles, dir: dir, ok: ok}
+	}()
+}
+
+// handleHint shows a hint to the player, or makes the move if auto-play is on.
+func (s *SingleplayerScreen) handleHint(h hintResult) {
+	s.searching = false
+	if !grid.EqualGrid(h.tiles, s.backend.Grid.Tiles) {
+		// The grid changed during the search, so the hint is out of date
+		return
+	}
+	if !h.ok {
+		s.setAutoPlay(false)
+		return
+	}
+
+	if s.autoPlay {
+		// Move straight away rather than via arenaInputCh, which Update reads
+		// from, so a full channel can't block it. This still counts as the one
+		// input for this update cycle
+		s.move(h.dir)
+	} else {
+		s.hint.SetText("HINT: " + strings.ToUpper(string(h.dir)))
+	}
+}
+
+// setAutoPlay starts or stops making moves automatically. Using auto-play marks
+// the game as assisted.
+func (s *SingleplayerScreen) setAutoPlay(on bool) {
+	if s.autoPlayStop != nil {
+		close(s.autoPlayStop)
+		s.autoPlayStop = nil
+	}
+	s.autoPlay = on
+	if !on {
+		s.hint.SetText("")
+		return
+	}
+
+	s.backend.MarkAssisted()
+	rate := autoPlayRates[s.autoPlayRate]
+	s.hint.SetText(fmt.Sprintf("AUTO-PLAY: %d/s", rate))
+
+	// Request a move at the auto-play rate. Requests are dropped if the input
+	// channel is full so they don't build up
+	stop := make(chan struct{})
+	s.autoPlayStop = stop
+	go func() {
+		ticker := time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				select {
+				case s.arenaInputCh <- s.requestHint:
+				default:
+				}
+			}
+		}
+	}()
+}
+
+// setAutoPlayRate sets the auto-play rate to the rate at index i of autoPlayRates.
+func (s *SingleplayerScreen) setAutoPlayRate(i int) {
+	s.autoPlayRate = min(max(i, 0), len(autoPlayRates)-1)
+	if s.autoPlay {
+		// Restart auto-play at the new rate
+		s.setAutoPlay(true)
+	}
+}
+
+// currentBoardSize returns the size of the current game's grid.
+func (s *SingleplayerScreen) currentBoardSize() boardSize {
+	return boardSize{s.backend.Grid.Width(), s.backend.Grid.Height()}
+}
+
+// cycleBoardSize starts a new game with the next available board size.
+func (s *SingleplayerScreen) cycleBoardSize() {
+	size := nextBoardSize(s.currentBoardSize())
+	s.backend.Resize(size.width, size.height)
+	s.arena.Reset()
+	s.boardSize.SetLabelText(size.String())
+}
+
+// cycleGameMode starts a new game with the next available game mode.
+func (s *SingleplayerScreen) cycleGameMode() {
+	mode := nextGameMode(s.backend.Mode)
+	s.backend.SetMode(mode)
+	s.arena.Reset()
+	s.mode.SetLabelText(mode.String())
+	s.guide.SetText(modeGuide(mode, s.backend.Target))
+}
+
+// toggleSpecials starts a new game with special tiles turned on or off.
+func (s *SingleplayerScreen) toggleSpecials() {
+	s.backend.SetSpecials(!s.backend.Grid.Specials)
+	s.arena.Reset()
+	if s.backend.Grid.Specials {
+		s.hint.SetText("SPECIAL TILES: ON")
+	} else {
+		s.hint.SetText("SPECIAL TILES: OFF")
+	}
+}
+
+// modeGuide returns the instructions for a game mode. Classic games are won by
+// reaching the target tile.
+func modeGuide(mode backend.Mode, target int) string {
+	switch mode.Type {
+	case backend.ModeTimeAttack:
+		return fmt.Sprintf("Score as much as you can in %v!", mode.TimeLimit)
+	case backend.ModeRace:
+		return fmt.Sprintf("Get to the %d tile as fast as you can!", mode.Target)
+	case backend.ModeMoveLimit:
+		return fmt.Sprintf("Score as much as you can in %d moves!", mode.MoveLimit)
+	default:
+		return fmt.Sprintf("Join the numbers and get to the %d tile!", target)
+	}
+}
+
 // Exit deinitialises the screen.
 func (s *SingleplayerScreen) Exit() {
+	s.setAutoPlay(false)
 	s.backend.Timer.Pause()
 
 	if err := s.backend.Save(); err != nil {
-		panic(err)
+		log.Printf("Failed to save game: %v\n", err)
 	}
 
 	s.win.UnregisterKeybind(gogl.KeyUp, gogl.KeyPress)
 	s.win.UnregisterKeybind(gogl.KeyDown, gogl.KeyPress)
 	s.win.UnregisterKeybind(gogl.KeyLeft, gogl.KeyPress)
 	s.win.UnregisterKeybind(gogl.KeyRight, gogl.KeyPress)
+	s.win.UnregisterKeybind(gogl.KeyB, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyM, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyT, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyZ, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyY, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyH, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyA, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyMinus, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.KeyEquals, gogl.KeyRelease)
 	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
 
 	s.arena.Destroy()
@@ -189,20 +440,25 @@ func (s *SingleplayerScreen) Update() {
 	select {
 	case inputFunc := <-s.arenaInputCh:
 		inputFunc()
+	case h := <-s.hintCh:
+		s.handleHint(h)
 	default:
 		// No user input; continue
 	}
 
+	// The time can run out between moves
+	s.backend.Tick()
+
 	// Deep copy so front-end has time to animate itself whilst allowing the
 	// back-end to update
-	game := deep.MustCopy(*s.backend)
+	game := s.backend.Snapshot()
 
 	// Check for win or lose
-	switch game.Grid.Outcome() {
-	case grid.Win:
+	switch {
+	case game.Over():
+		s.updateOver(game)
+	case game.Outcome() == grid.Win:
 		s.updateWin(game)
-	case grid.Lose:
-		s.updateLose(game)
 	default:
 		s.updateNormal(game)
 	}
@@ -210,7 +466,7 @@ func (s *SingleplayerScreen) Update() {
 	// Draw debug grid
 	if config.Debug {
 		s.debugGrid.SetText(s.backend.Grid.Debug())
-		s.debugTime.SetText(s.backend.Timer.Time.String())
+		s.debugTime.SetText(s.backend.Timer.String())
 		s.debugScore.SetText(
 			fmt.Sprint(s.backend.Score, "|", s.backend.HighScore),
 		)
@@ -227,9 +483,11 @@ func (s *SingleplayerScreen) updateNormal(game backend.Game) {
 
 	s.score.SetBody(strconv.Itoa(game.Score))
 	s.menu.Update(s.win)
-	s.highScore.SetBody(strconv.Itoa(game.HighScore))
-	s.timer.SetText(game.Timer.Time.String())
+	s.highScore.SetBody(bestText(game))
+	s.timer.SetText(timerText(game))
 	s.newGame.Update(s.win)
+	s.boardSize.Update(s.win)
+	s.mode.Update(s.win)
 
 	s.arena.SetNormal()
 	s.arena.Update(game)
@@ -240,7 +498,10 @@ func (s *SingleplayerScreen) updateNormal(game backend.Game) {
 		s.highScore,
 		s.menu,
 		s.newGame,
+		s.boardSize,
+		s.mode,
 		s.guide,
+		s.hint,
 		s.timer,
 		s.arena,
 	} {
@@ -248,6 +509,32 @@ func (s *SingleplayerScreen) updateNormal(game backend.Game) {
 	}
 }
 
+// bestText returns the record shown for the game's mode: the fastest time in a
+// race, or the high score otherwise.
+func bestText(game backend.Game) string {
+	if game.Mode.Type != backend.ModeRace {
+		return strconv.Itoa(game.HighScore)
+	}
+	best, ok := game.BestTime()
+	if !ok {
+		return "-"
+	}
+	return best.Truncate(100 * time.Millisecond).String()
+}
+
+// timerText returns the progress shown below the arena, which counts down in modes
+// with a limit.
+func timerText(game backend.Game) string {
+	switch game.Mode.Type {
+	case backend.ModeTimeAttack:
+		return "TIME LEFT: " + game.TimeLeft().Truncate(time.Millisecond).String()
+	case backend.ModeMoveLimit:
+		return fmt.Sprintf("MOVES LEFT: %d", game.MovesLeft())
+	default:
+		return game.Timer.String()
+	}
+}
+
 // updateWin updates and draws the singleplayer screen in a winning state.
 func (s *SingleplayerScreen) updateWin(game backend.Game) {
 	s.guide.SetText(
@@ -256,18 +543,27 @@ func (s *SingleplayerScreen) updateWin(game backend.Game) {
 	s.updateNormal(game)
 }
 
-// updateLose updates and draws the singleplayer screen in a losing state.
-func (s *SingleplayerScreen) updateLose(game backend.Game) {
+// updateOver updates and draws the singleplayer screen once the game has ended.
+func (s *SingleplayerScreen) updateOver(game backend.Game) {
+	if s.autoPlay {
+		s.setAutoPlay(false)
+	}
+
 	s.win.SetBackground(common.BackgroundColour)
-	s.arena.SetLose()
+	if game.Outcome() == grid.Win {
+		s.arena.SetWin()
+	} else {
+		s.arena.SetLose()
+	}
 
-	s.heading.SetText("Game over!")
-	s.loseDialog.SetText(fmt.Sprintf(
-		"You earned %d points in %v.", game.Score, game.Timer.Duration(),
-	))
+	heading, dialog := overText(game)
+	s.heading.SetText(heading)
+	s.loseDialog.SetText(dialog)
 
 	s.menu.Update(s.win)
 	s.newGame.Update(s.win)
+	s.boardSize.Update(s.win)
+	s.mode.Update(s.win)
 	s.arena.Update(game)
 
 	for _, d := range []gogl.Drawable{
@@ -275,8 +571,32 @@ func (s *SingleplayerScreen) updateLose(game backend.Game) {
 		s.loseDialog,
 		s.menu,
 		s.newGame,
+		s.boardSize,
+		s.mode,
 		s.arena,
 	} {
 		s.win.Draw(d)
 	}
 }
+
+// overText returns the heading and message shown once a game has ended.
+func overText(game backend.Game) (string, string) {
+	if game.Outcome() == grid.Lose {
+		return "Game over!", fmt.Sprintf("You earned %d points in %v.", game.Score, game.Timer)
+	}
+
+	switch game.Mode.Type {
+	case backend.ModeTimeAttack:
+		return "Time's up!", fmt.Sprintf("You earned %d points in %v.", game.Score, game.Mode.TimeLimit)
+	case backend.ModeRace:
+		dialog := fmt.Sprintf("You got to the %d tile in %v.", game.Mode.Target, game.Timer)
+		if best, ok := game.BestTime(); ok && best == game.Timer.Duration() && !game.Assisted {
+			dialog += " New best time!"
+		}
+		return "You win!", dialog
+	case backend.ModeMoveLimit:
+		return "Out of moves!", fmt.Sprintf("You earned %d points in %d moves.", game.Score, game.Moves)
+	default:
+		return "Game over!", fmt.Sprintf("You earned %d points in %v.", game.Score, game.Timer)
+	}
+}
@@ -0,0 +1,175 @@
+package screens
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/backend"
+	"github.com/z-riley/go-2048-battle/config"
+	"github.com/z-riley/go-2048-battle/log"
+	"github.com/z-riley/gogl"
+)
+
+const (
+	// statsTopScores is the number of games shown in the high score table.
+	statsTopScores = 10
+	// statsOpponents is the number of opponents whose records are shown.
+	statsOpponents = 5
+	// statsTiles is the number of highest tiles shown in the distribution.
+	statsTiles = 6
+)
+
+type StatsScreen struct {
+	win *gogl.Window
+
+	title *gogl.Text
+	texts []*gogl.Text       // every line of text showing the statistics
+	bars  []*gogl.CurvedRect // the bars of the highest tile distribution
+	menu  *gogl.Button
+}
+
+// NewStatsScreen constructs an uninitialised statistics screen.
+func NewStatsScreen(win *gogl.Window) *StatsScreen {
+	return &StatsScreen{win: win}
+}
+
+// Enter initialises the screen.
+func (s *StatsScreen) Enter(_ InitData) {
+	s.title = gogl.NewText("Statistics", gogl.Vec{X: config.WinWidth / 2, Y: 90}, common.FontPathMedium).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignCentre).
+		SetSize(70)
+
+	s.texts = nil
+	s.bars = nil
+
+	stats, err := backend.LoadStats(profile.StatsFile())
+	if err != nil {
+		log.Println("Failed to load statistics:", err)
+		stats = &backend.Stats{}
+	}
+
+	// High score table on the left
+	const (
+		leftX = 80
+		rowY  = 215
+		rowH  = 34
+	)
+	s.addHeading(profile.Name+"'s top scores", gogl.Vec{X: leftX, Y: 175})
+	top := stats.Top(statsTopScores)
+	if len(top) == 0 {
+		s.addText("No games finished yet", gogl.Vec{X: leftX, Y: rowY}, gogl.AlignBottomLeft)
+	}
+	for i, r := range top {
+		y := rowY + float64(i)*rowH
+		s.addText(fmt.Sprintf("%d.", i+1), gogl.Vec{X: leftX, Y: y}, gogl.AlignBottomLeft)
+		s.addText(strconv.Itoa(r.Score), gogl.Vec{X: leftX + 160, Y: y}, gogl.AlignBottomRight)
+		s.addText(strconv.Itoa(r.HighestTile), gogl.Vec{X: leftX + 250, Y: y}, gogl.AlignBottomRight)
+		s.addText(resultModeText(r), gogl.Vec{X: leftX + 280, Y: y}, gogl.AlignBottomLeft)
+		s.addText(r.Start.Format("02 Jan 2006"), gogl.Vec{X: leftX + 430, Y: y}, gogl.AlignBottomLeft)
+	}
+
+	// Summary, opponents and highest tiles on the right
+	const rightX = 700
+	s.addHeading("Summary", gogl.Vec{X: rightX, Y: 175})
+	wins := 0
+	for _, r := range stats.Results {
+		if r.Won {
+			wins++
+		}
+	}
+	s.addText(fmt.Sprintf("Games played: %d", len(stats.Results)), gogl.Vec{X: rightX, Y: 210}, gogl.AlignBottomLeft)
+	s.addText(fmt.Sprintf("Games won: %d", wins), gogl.Vec{X: rightX, Y: 240}, gogl.AlignBottomLeft)
+	s.addText(fmt.Sprintf("Average score: %.0f", stats.AverageScore()), gogl.Vec{X: rightX, Y: 270}, gogl.AlignBottomLeft)
+
+	s.addHeading("Versus", gogl.Vec{X: rightX, Y: 320})
+	opponents := stats.Opponents()
+	if len(opponents) == 0 {
+		s.addText("No versus games yet", gogl.Vec{X: rightX, Y: 355}, gogl.AlignBottomLeft)
+	}
+	for i, o := range opponents[:min(len(opponents), statsOpponents)] {
+		y := 355 + float64(i)*30
+		s.addText(o.Name, gogl.Vec{X: rightX, Y: y}, gogl.AlignBottomLeft)
+		s.addText(fmt.Sprintf("%d-%d", o.Wins, o.Losses), gogl.Vec{X: rightX + 300, Y: y}, gogl.AlignBottomRight)
+		s.addText(fmt.Sprintf("%.0f%%", 100*o.WinRate()), gogl.Vec{X: rightX + 400, Y: y}, gogl.AlignBottomRight)
+	}
+
+	s.addHeading("Highest tiles", gogl.Vec{X: rightX, Y: 540})
+	tiles := stats.HighestTiles()
+	tiles = tiles[max(len(tiles)-statsTiles, 0):]
+	most := 0
+	for _, t := range tiles {
+		most = max(most, t.Games)
+	}
+	const barWidth = 280
+	for i, t := range tiles {
+		y := 575 + float64(i)*28
+		s.addText(strconv.Itoa(t.Tile), gogl.Vec{X: rightX + 60, Y: y}, gogl.AlignBottomRight)
+		w := max(barWidth*float64(t.Games)/float64(most), 4)
+		bar := gogl.NewCurvedRect(w, 20, 3, gogl.Vec{X: rightX + 75, Y: y - 20})
+		bar.SetStyle(gogl.Style{Colour: common.ButtonOrangeColour})
+		s.bars = append(s.bars, bar)
+		s.addText(strconv.Itoa(t.Games), gogl.Vec{X: rightX + 85 + w, Y: y}, gogl.AlignBottomLeft)
+	}
+
+	s.menu = common.NewGameButton(
+		150, 0.5*common.TileSizePx,
+		gogl.Vec{X: leftX, Y: 680},
+		func() {
+			SetScreen(Title, nil)
+		},
+	).SetLabelText("MENU")
+
+	s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, func() {
+		SetScreen(Title, nil)
+	})
+}
+
+// Exit deinitialises the screen.
+func (s *StatsScreen) Exit() {
+	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
+}
+
+// Update updates and draws the statistics screen.
+func (s *StatsScreen) Update() {
+	s.win.SetBackground(common.BackgroundColour)
+
+	s.win.Draw(s.title)
+	for _, t := range s.texts {
+		s.win.Draw(t)
+	}
+	for _, b := range s.bars {
+		s.win.Draw(b)
+	}
+
+	s.menu.Update(s.win)
+	s.win.Draw(s.menu)
+}
+
+// addHeading adds a heading above a section of the statistics.
+func (s *StatsScreen) addHeading(text string, pos gogl.Vec) {
+	s.texts = append(s.texts, gogl.NewText(text, pos, common.FontPathBold).
+		SetColour(common.GreyTextColour).
+		SetAlignment(gogl.AlignBottomLeft).
+		SetSize(26),
+	)
+}
+
+// addText adds a line of the statistics.
+func (s *StatsScreen) addText(text string, pos gogl.Vec, align gogl.Alignment) {
+	s.texts = append(s.texts, gogl.NewText(text, pos, common.FontPathMedium).
+		SetColour(common.GreyTextColour).
+		SetAlignment(align).
+		SetSize(20),
+	)
+}
+
+// resultModeText returns the mode of a game in the high score table, which is
+// the opponent's name in a versus game.
+func resultModeText(r backend.Result) string {
+	if r.Opponent != "" {
+		return "VS " + r.Opponent
+	}
+	return r.Mode.String()
+}
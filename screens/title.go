@@ -1,8 +1,13 @@
 package screens
 
 import (
+	"slices"
+
+	"github.com/moby/moby/pkg/namesgenerator"
 	"github.com/z-riley/go-2048-battle/common"
+	"github.com/z-riley/go-2048-battle/common/backend"
 	"github.com/z-riley/go-2048-battle/config"
+	"github.com/z-riley/go-2048-battle/log"
 	"github.com/z-riley/gogl"
 )
 
@@ -14,7 +19,13 @@ type TitleScreen struct {
 	buttonBackground *gogl.CurvedRect
 	singleplayer     *gogl.Button
 	multiplayer      *gogl.Button
+	replays          *gogl.Button
+	stats            *gogl.Button
 	quit             *gogl.Button
+	tooltip          *gogl.TextBox
+	profileName      *common.EntryBox
+	switchProfile    *gogl.Button
+	newProfile       *gogl.Button
 }
 
 // NewTitle Screen constructs a new title screen for the given window.
@@ -42,7 +53,7 @@ func (s *TitleScreen) Enter(_ InitData) {
 	)
 
 	// Background for buttons
-	const w = TileSizePx * (3 + 4*TileBoundryFactor)
+	const w = TileSizePx * (5 + 6*TileBoundryFactor)
 	s.buttonBackground = gogl.NewCurvedRect(
 		w, TileSizePx*(1+2*TileBoundryFactor), TileCornerRadius,
 		gogl.Vec{X: (config.WinWidth - w) / 2, Y: 400},
@@ -102,12 +113,64 @@ func (s *TitleScreen) Enter(_ InitData) {
 		},
 	)
 
-	s.quit = common.NewMenuButton(
+	s.replays = common.NewMenuButton(
 		TileSizePx, TileSizePx,
 		gogl.Vec{
 			X: s.buttonBackground.Pos.X + TileSizePx*(2+3*TileBoundryFactor),
 			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
 		},
+		func() {
+			SetScreen(Replay, nil)
+		},
+	).SetLabelText("Replay")
+	s.replays.SetCallback(
+		gogl.ButtonTrigger{State: gogl.NoClick, Behaviour: gogl.OnHold},
+		func() {
+			s.replays.Label.SetColour(common.WhiteFontColour)
+			s.replays.Shape.(*gogl.CurvedRect).SetStyle(common.ButtonStyleHovering)
+			s.hint.SetText("Watch your previous games")
+		},
+	).SetCallback(
+		gogl.ButtonTrigger{State: gogl.NoClick, Behaviour: gogl.OnRelease},
+		func() {
+			s.replays.Label.SetColour(common.WhiteFontColour)
+			s.replays.Shape.(*gogl.CurvedRect).SetStyle(common.ButtonStyleUnpressed)
+			s.hint.SetText("")
+		},
+	)
+
+	s.stats = common.NewMenuButton(
+		TileSizePx, TileSizePx,
+		gogl.Vec{
+			X: s.buttonBackground.Pos.X + TileSizePx*(3+4*TileBoundryFactor),
+			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
+		},
+		func() {
+			SetScreen(Stats, nil)
+		},
+	).SetLabelText("Stats")
+	s.stats.SetCallback(
+		gogl.ButtonTrigger{State: gogl.NoClick, Behaviour: gogl.OnHold},
+		func() {
+			s.stats.Label.SetColour(common.WhiteFontColour)
+			s.stats.Shape.(*gogl.CurvedRect).SetStyle(common.ButtonStyleHovering)
+			s.hint.SetText("See your high scores and statistics")
+		},
+	).SetCallback(
+		gogl.ButtonTrigger{State: gogl.NoClick, Behaviour: gogl.OnRelease},
+		func() {
+			s.stats.Label.SetColour(common.WhiteFontColour)
+			s.stats.Shape.(*gogl.CurvedRect).SetStyle(common.ButtonStyleUnpressed)
+			s.hint.SetText("")
+		},
+	)
+
+	s.quit = common.NewMenuButton(
+		TileSizePx, TileSizePx,
+		gogl.Vec{
+			X: s.buttonBackground.Pos.X + TileSizePx*(4+5*TileBoundryFactor),
+			Y: s.buttonBackground.Pos.Y + TileSizePx*TileBoundryFactor,
+		},
 		func() {
 			s.win.Quit()
 		},
@@ -128,15 +191,62 @@ func (s *TitleScreen) Enter(_ InitData) {
 		},
 	)
 
+	// Profile picker
+	s.tooltip = common.NewTooltip()
+
+	const (
+		profileWidth = 300
+		buttonWidth  = 140
+		profileGap   = 10
+		profileY     = 655
+	)
+	profileX := (config.WinWidth - profileWidth - 2*(buttonWidth+profileGap)) / 2.0
+
+	s.profileName = common.NewEntryBox(
+		profileWidth, 50,
+		gogl.Vec{X: profileX, Y: profileY},
+		profile.Name,
+	).
+		SetModifiedCB(func() {
+			if err := profile.Rename(s.profileName.Text()); err != nil {
+				log.Println("Failed to rename profile:", err)
+			}
+		})
+
+	s.switchProfile = common.NewGameButton(
+		buttonWidth, 50,
+		gogl.Vec{X: profileX + profileWidth + profileGap, Y: profileY},
+		s.nextProfile,
+	).SetLabelText("SWITCH PLAYER")
+
+	s.newProfile = common.NewGameButton(
+		buttonWidth, 50,
+		gogl.Vec{X: profileX + profileWidth + buttonWidth + 2*profileGap, Y: profileY},
+		func() {
+			p, err := backend.NewProfile(namesgenerator.GetRandomName(0))
+			if err != nil {
+				log.Println("Failed to create profile:", err)
+				return
+			}
+			s.selectProfile(p)
+		},
+	).SetLabelText("NEW PLAYER")
+
 	// Keybinds
-	s.win.RegisterKeybind(gogl.Key1, gogl.KeyRelease, func() {
+	s.win.RegisterKeybind(gogl.Key1, gogl.KeyRelease, s.unlessEditing(func() {
 		SetScreen(Singleplayer, nil)
-	})
-	s.win.RegisterKeybind(gogl.Key2, gogl.KeyRelease, func() {
+	}))
+	s.win.RegisterKeybind(gogl.Key2, gogl.KeyRelease, s.unlessEditing(func() {
 		SetScreen(MultiplayerMenu, nil)
-	})
-	s.win.RegisterKeybind(gogl.Key3, gogl.KeyRelease, s.win.Quit)
-	s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, s.win.Quit)
+	}))
+	s.win.RegisterKeybind(gogl.Key3, gogl.KeyRelease, s.unlessEditing(func() {
+		SetScreen(Replay, nil)
+	}))
+	s.win.RegisterKeybind(gogl.Key4, gogl.KeyRelease, s.unlessEditing(func() {
+		SetScreen(Stats, nil)
+	}))
+	s.win.RegisterKeybind(gogl.Key5, gogl.KeyRelease, s.unlessEditing(s.win.Quit))
+	s.win.RegisterKeybind(gogl.KeyEscape, gogl.KeyRelease, s.unlessEditing(s.win.Quit))
 }
 
 // Exit deinitialises the screen.
@@ -144,6 +254,8 @@ func (s *TitleScreen) Exit() {
 	s.win.UnregisterKeybind(gogl.Key1, gogl.KeyRelease)
 	s.win.UnregisterKeybind(gogl.Key2, gogl.KeyRelease)
 	s.win.UnregisterKeybind(gogl.Key3, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.Key4, gogl.KeyRelease)
+	s.win.UnregisterKeybind(gogl.Key5, gogl.KeyRelease)
 	s.win.UnregisterKeybind(gogl.KeyEscape, gogl.KeyRelease)
 }
 
@@ -158,9 +270,61 @@ func (s *TitleScreen) Update() {
 	for _, b := range []*gogl.Button{
 		s.singleplayer,
 		s.multiplayer,
+		s.replays,
+		s.stats,
 		s.quit,
 	} {
 		b.Update(s.win)
 		s.win.Draw(b)
 	}
+
+	for _, b := range []*gogl.Button{
+		s.switchProfile,
+		s.newProfile,
+	} {
+		b.Update(s.win)
+		s.win.Draw(b)
+	}
+
+	s.win.Draw(s.profileName)
+	s.profileName.Update(s.win)
+
+	mouseLoc := s.win.MouseLocation()
+	if s.profileName.TextBox.Shape.IsWithin(mouseLoc) && !s.profileName.TextBox.IsEditing() {
+		s.tooltip.SetPos(gogl.Vec{X: mouseLoc.X, Y: mouseLoc.Y - s.tooltip.Shape.Height()})
+		s.win.Draw(s.tooltip)
+	}
+}
+
+// nextProfile selects the profile after the current one.
+func (s *TitleScreen) nextProfile() {
+	profiles, err := backend.ListProfiles()
+	if err != nil {
+		log.Println("Failed to list profiles:", err)
+		return
+	}
+	if len(profiles) == 0 {
+		return
+	}
+	i := slices.IndexFunc(profiles, func(p *backend.Profile) bool { return p.ID == profile.ID })
+	s.selectProfile(profiles[(i+1)%len(profiles)])
+}
+
+// selectProfile makes p the current profile.
+func (s *TitleScreen) selectProfile(p *backend.Profile) {
+	if err := backend.SetCurrentProfile(p); err != nil {
+		log.Println("Failed to select profile:", err)
+	}
+	profile = p
+	s.profileName.SetText(p.Name)
+}
+
+// unlessEditing returns a keybind callback which runs f, unless the profile name
+// is being edited.
+func (s *TitleScreen) unlessEditing(f func()) func() {
+	return func() {
+		if !s.profileName.TextBox.IsEditing() {
+			f()
+		}
+	}
 }